@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
 
 	"github.com/jnb666/deepthought/blas"
 	"github.com/jnb666/deepthought/network"
@@ -13,10 +14,10 @@ import (
 )
 
 func main() {
-	var debug bool
-	var runs, maxEpoch int
+	var debug, resume, classStats bool
+	var runs, maxEpoch, threads, every int
 	var seed int64
-	network.Init(blas.OpenCL32)
+	var impl, snapFile, onnxFile string
 	dataSets := network.DataSets()
 	model := dataSets[0]
 	flag.StringVar(&model, "model", model, "data model to run")
@@ -24,7 +25,29 @@ func main() {
 	flag.IntVar(&maxEpoch, "epochs", 0, "maximum number of epochs")
 	flag.Int64Var(&seed, "seed", 0, "random number seed")
 	flag.BoolVar(&debug, "debug", false, "enable debug output")
+	flag.StringVar(&impl, "impl", "opencl32", "matrix implementation: opencl32, native32 or native64")
+	flag.IntVar(&threads, "threads", 0, "number of threads for native matrix implementation")
+	flag.IntVar(&every, "snapshot", 0, "save snapshot every n epochs")
+	flag.StringVar(&snapFile, "snapfile", "", "snapshot file name: default is <model>.snapshot")
+	flag.BoolVar(&resume, "resume", false, "resume training from snapshot")
+	flag.StringVar(&onnxFile, "onnx", "", "import network weights from ONNX file")
+	flag.BoolVar(&classStats, "classes", false, "print precision, recall and F1 for each class")
 	flag.Parse()
+	if snapFile == "" {
+		snapFile = model + ".snapshot"
+	}
+	blas.SetThreads(threads)
+	switch impl {
+	case "opencl32":
+		network.Init(blas.OpenCL32)
+	case "native32":
+		network.Init(blas.Native32)
+	case "native64":
+		network.Init(blas.Native64)
+	default:
+		fmt.Println("unknown matrix implementation", impl)
+		return
+	}
 	cfg, net, data, err := network.Load(model, 0)
 	if err != nil {
 		fmt.Println(err)
@@ -36,26 +59,61 @@ func main() {
 	if maxEpoch > 0 {
 		cfg.MaxEpoch = maxEpoch
 	}
+	if onnxFile != "" {
+		imported, err := network.ImportONNXFile(onnxFile, data, net)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		net.Release()
+		net = imported
+	}
 	//net.Verbose = true
+	s := network.NewStats()
+	start := 0
+	if resume {
+		snap, err := loadSnapshot(snapFile, net)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		seed, start, s = snap.Seed, snap.Run, snap.Stats
+		fmt.Printf("resume from %s at run %d epoch %d\n", snapFile, start+1, s.Epoch)
+	}
+	s.ClassStats = classStats
+	if err := net.UpdateClassWeights(cfg, data); err != nil {
+		fmt.Println(err)
+		return
+	}
 	seed = blas.SeedRandom(seed)
 	fmt.Println("set random seed to", seed)
 	cfg.Print()
-	s := network.NewStats()
 	if debug {
 		net.CheckGradient(5, 1e-4, 0, 5)
 	}
-	for i := 0; i < cfg.MaxRuns; i++ {
-		net.SetRandomWeights()
+	for i := start; i < cfg.MaxRuns; i++ {
+		stop := network.StopCriteria(cfg)
+		if i > start || !resume && onnxFile == "" {
+			network.SeedEpoch(seed, i, 0)
+			net.SetRandomWeights()
+		}
+		if i > start || !resume {
+			s.StartRun()
+		}
 		if debug {
 			fmt.Println(net)
 		}
-		stop := network.StopCriteria(cfg)
-		s.StartRun()
 		var done, failed bool
 		for !done {
+			network.SeedEpoch(seed, i, s.Epoch+1)
 			net.Train(s, data, cfg)
 			s.Update(net, data)
 			done, failed = stop(s)
+			if !done && every > 0 && s.Epoch%every == 0 {
+				if err := saveSnapshot(snapFile, net, s, seed, i); err != nil {
+					fmt.Println(err)
+				}
+			}
 		}
 		if debug {
 			fmt.Println(net)
@@ -67,3 +125,28 @@ func main() {
 	data.Release()
 	blas.Release()
 }
+
+// write snapshot to a temporary file first so an interrupted save does not lose the previous one
+func saveSnapshot(file string, net *network.Network, s *network.Stats, seed int64, run int) error {
+	f, err := os.Create(file + ".tmp")
+	if err != nil {
+		return err
+	}
+	if err = network.SaveSnapshot(f, net, s, seed, run); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file+".tmp", file)
+}
+
+func loadSnapshot(file string, net *network.Network) (*network.Snapshot, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return network.LoadSnapshot(f, net)
+}
@@ -17,11 +17,13 @@ import (
 
 func main() {
 	var seed int64
+	var onnxFile string
 	network.Init(blas.OpenCL32)
 	dataSets := network.DataSets()
 	model := dataSets[0]
 	flag.StringVar(&model, "model", model, "data model to run")
 	flag.Int64Var(&seed, "seed", 0, "random number seed")
+	flag.StringVar(&onnxFile, "onnx", "", "import network weights from ONNX file")
 	flag.Parse()
 
 	cfg, net, data, err := network.Load(model, 0)
@@ -29,13 +31,24 @@ func main() {
 		fmt.Println(err)
 		return
 	}
+	if onnxFile != "" {
+		imported, err := network.ImportONNXFile(onnxFile, data, net)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		net.Release()
+		net = imported
+	}
 	seed = blas.SeedRandom(seed)
 	fmt.Println("set random seed to", seed)
 	cfg.Print()
 	s := network.NewStats()
 	plts := createPlots(s)
+	mode := getPlotMode(cfg, net)
+	mode.apply(plts, s)
 	ctrl := qml.NewCtrl(cfg, net, testData(data), dataSets, model, plts)
-	go train(cfg, net, data, s, ctrl, &statsPlot{Plot: plts[3]})
+	go train(model, cfg, net, data, s, ctrl, plts, mode, onnxFile != "")
 	qml.MainLoop(ctrl)
 	ctrl.WG.Wait()
 }
@@ -47,26 +60,79 @@ func testData(d *network.Dataset) *network.Data {
 	return d.Train
 }
 
-// create the plots
+// create the plots, the lines on the accuracy, statistics and roc plots are set by plotMode.apply
 func createPlots(s *network.Stats) []*qml.Plot {
 	p1 := qml.NewPlot("cost", "average cost vs epoch",
 		qml.NewLine(s.Train.Error, "training"),
 		qml.NewLine(s.Valid.Error, "validation"),
 		qml.NewLine(s.Test.Error, "test set"),
 	)
-	p2 := qml.NewPlot("accuracy", "classification error vs epoch",
-		qml.NewLine(s.Train.ClassError, "training"),
-		qml.NewLine(s.Valid.ClassError, "validation"),
-		qml.NewLine(s.Test.ClassError, "test set"),
+	rate := qml.NewPlot("learn rate", "learning rate vs epoch",
+		qml.NewLine(s.LearnRate, "learning rate"),
 	)
+	p2 := qml.NewPlot("accuracy", "")
 	p3 := qml.NewPlot("error hist", "histogram of cost per sample",
 		qml.NewHistogram(s.Train.ErrorHist, "training"),
 		qml.NewHistogram(s.Valid.ErrorHist, "validation"),
 		qml.NewHistogram(s.Test.ErrorHist, "test set"),
 	)
-	p4 := qml.NewPlot("statistics", "classification error vs run time in seconds")
+	p4 := qml.NewPlot("statistics", "")
 	p4.Legend = qml.TopLeft
-	return []*qml.Plot{p1, p2, p3, p4}
+	p5 := qml.NewPlot("roc", "ROC and precision-recall curves for test set")
+	return []*qml.Plot{p1, rate, p2, p3, p4, p5}
+}
+
+// lookup plot by name
+func findPlot(plts []*qml.Plot, name string) *qml.Plot {
+	for _, p := range plts {
+		if p.Name == name {
+			return p
+		}
+	}
+	panic("plot " + name + " not found")
+}
+
+// settings which determine which lines are shown on the plots
+type plotMode struct {
+	regression, topK, roc bool
+}
+
+func getPlotMode(cfg *network.Config, net *network.Network) plotMode {
+	return plotMode{regression: net.Regression(), topK: cfg.TopK > 0, roc: cfg.ROC}
+}
+
+// set the plot lines, for a regression network the root mean squared error is plotted instead of the classification error.
+// The top-k error and mean reciprocal rank for the test set are added if TopK is set in the config.
+// If ROC is set in the config then the test set ROC and precision-recall curves are shown on the roc plot.
+func (m plotMode) apply(plts []*qml.Plot, s *network.Stats) {
+	acc := findPlot(plts, "accuracy")
+	acc.Clear()
+	stats := findPlot(plts, "statistics")
+	if m.regression {
+		acc.Title = "RMSE vs epoch"
+		acc.Add(
+			qml.NewLine(s.Train.RMSE, "training"),
+			qml.NewLine(s.Valid.RMSE, "validation"),
+			qml.NewLine(s.Test.RMSE, "test set"),
+		)
+		stats.Title = "RMSE vs run time in seconds"
+	} else {
+		acc.Title = "classification error vs epoch"
+		acc.Add(
+			qml.NewLine(s.Train.ClassError, "training"),
+			qml.NewLine(s.Valid.ClassError, "validation"),
+			qml.NewLine(s.Test.ClassError, "test set"),
+		)
+		if m.topK {
+			acc.Add(qml.NewLine(s.Test.TopKError, "test top-k"), qml.NewLine(s.Test.MRR, "test MRR"))
+		}
+		stats.Title = "classification error vs run time in seconds"
+	}
+	roc := findPlot(plts, "roc")
+	roc.Clear()
+	if m.roc {
+		roc.Add(qml.NewLine(s.Test.ROC, "test ROC"), qml.NewLine(s.Test.PR, "test precision-recall"))
+	}
 }
 
 type statsPlot struct {
@@ -134,7 +200,11 @@ func (p *statsPlot) find(cfg *network.Config) (ix int, ok bool) {
 func (p *statsPlot) addPoint(s *network.Stats, cfg *network.Config) {
 	i, found := p.find(cfg)
 	p.x[i].Push(float32(s.RunTime.Mean), float32(s.RunTime.StdDev))
-	p.y[i].Push(float32(s.ClsError.Mean), float32(s.ClsError.StdDev))
+	if s.RMSError.Count > 0 {
+		p.y[i].Push(float32(s.RMSError.Mean), float32(s.RMSError.StdDev))
+	} else {
+		p.y[i].Push(float32(s.ClsError.Mean), float32(s.ClsError.StdDev))
+	}
 	if !found {
 		for i, title := range p.name[:len(p.name)-1] {
 			p.Plotters[i].SetName(title)
@@ -146,17 +216,31 @@ func (p *statsPlot) addPoint(s *network.Stats, cfg *network.Config) {
 	}
 }
 
-// train the network
-func train(cfg *network.Config, net *network.Network, data *network.Dataset, s *network.Stats, ctrl *qml.Ctrl, p *statsPlot) {
+// train the network, if keepWeights is set then the first run starts from the current weights
+func train(model string, cfg *network.Config, net *network.Network, data *network.Dataset, s *network.Stats, ctrl *qml.Ctrl,
+	plts []*qml.Plot, mode plotMode, keepWeights bool) {
 	var running, started bool
 	var stopCond func(*network.Stats) (bool, bool)
 	run := 0
+	p := &statsPlot{Plot: findPlot(plts, "statistics")}
 	p.clear()
 
+	updatePlots := func() {
+		// update the plot lines if the model or config settings have changed
+		if m := getPlotMode(cfg, net); m != mode {
+			mode = m
+			mode.apply(plts, s)
+		}
+	}
+
 	startRun := func() {
 		// start new training run
 		run++
-		net.SetRandomWeights()
+		net.SetInitialiser(cfg.Initialiser())
+		if !keepWeights {
+			net.SetRandomWeights()
+		}
+		keepWeights = false
 		stopCond = network.StopCriteria(cfg)
 		if run == 1 {
 			s.Reset()
@@ -192,6 +276,13 @@ func train(cfg *network.Config, net *network.Network, data *network.Dataset, s *
 			startRun()
 			ctrl.SetRun(run)
 		case "step": // step to next epoch
+			if err := net.UpdateClassWeights(cfg, data); err != nil {
+				fmt.Println(err)
+				running = false
+				ctrl.Done()
+				continue
+			}
+			updatePlots()
 			net.Train(s, data, cfg)
 			s.Update(net, data)
 			if done, failed := stopCond(s); done {
@@ -209,12 +300,19 @@ func train(cfg *network.Config, net *network.Network, data *network.Dataset, s *
 		case "stop": // end run
 			endRun(false)
 		case "select": // choose a new data set
+			newCfg, newNet, newData, err := network.Load(ev.Arg, 0)
+			if err != nil {
+				fmt.Println(err)
+				ctrl.Restore(model)
+				continue
+			}
 			p.clear()
 			s.Reset()
 			net.Release()
 			data.Release()
-			cfg, net, data, _ = network.Load(ev.Arg, 0)
+			cfg, net, data, model = newCfg, newNet, newData, ev.Arg
 			cfg.Print()
+			updatePlots()
 			ctrl.Refresh(cfg, net, testData(data))
 			running = false
 			run = 0
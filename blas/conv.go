@@ -0,0 +1,116 @@
+package blas
+
+import (
+	"fmt"
+)
+
+// ConvDims type has the dimensions for a 2 dimensional convolution.
+// Images are stored with one sample per row of the matrix, with the values ordered by row, column and then channel.
+// Weight matrices have one row per filter, with the kernel values in the same order followed by the bias.
+type ConvDims struct {
+	Width    int // input image width
+	Height   int // input image height
+	Channels int // number of input channels
+	Filters  int // number of output channels
+	Kernel   int // kernel width and height
+	Stride   int // step between kernel positions
+	Pad      int // zero padding added to each side of the input
+}
+
+// OutWidth method returns the width of the output image.
+func (d ConvDims) OutWidth() int {
+	return (d.Width+2*d.Pad-d.Kernel)/d.Stride + 1
+}
+
+// OutHeight method returns the height of the output image.
+func (d ConvDims) OutHeight() int {
+	return (d.Height+2*d.Pad-d.Kernel)/d.Stride + 1
+}
+
+// InSize method returns the number of input values per sample.
+func (d ConvDims) InSize() int {
+	return d.Width * d.Height * d.Channels
+}
+
+// OutSize method returns the number of output values per sample.
+func (d ConvDims) OutSize() int {
+	return d.OutWidth() * d.OutHeight() * d.Filters
+}
+
+// KernelSize method returns the number of weights per filter excluding the bias.
+func (d ConvDims) KernelSize() int {
+	return d.Kernel * d.Kernel * d.Channels
+}
+
+func (d ConvDims) String() string {
+	return fmt.Sprintf("[%dx%dx%d] => [%dx%dx%d] kernel=%d stride=%d pad=%d", d.Width, d.Height, d.Channels,
+		d.OutWidth(), d.OutHeight(), d.Filters, d.Kernel, d.Stride, d.Pad)
+}
+
+// convolution dimensions passed to OpenCL kernels
+type convArgs struct {
+	width, height, channels, filters int32
+	kernel, stride, pad, outw, outh  int32
+}
+
+func (d ConvDims) args() convArgs {
+	return convArgs{
+		width:    int32(d.Width),
+		height:   int32(d.Height),
+		channels: int32(d.Channels),
+		filters:  int32(d.Filters),
+		kernel:   int32(d.Kernel),
+		stride:   int32(d.Stride),
+		pad:      int32(d.Pad),
+		outw:     int32(d.OutWidth()),
+		outh:     int32(d.OutHeight()),
+	}
+}
+
+// check input matrix is consistent with the dimensions
+func checkConv(caller string, in Matrix, cols int) {
+	if in.Cols() != cols {
+		panic(fmt.Sprintf("%s - expected %d columns in input, got %d", caller, cols, in.Cols()))
+	}
+}
+
+// PoolDims type has the dimensions for a 2 dimensional max or average pooling operation.
+// Images are stored in the same format as for ConvDims and the number of channels is unchanged.
+type PoolDims struct {
+	Width    int // input image width
+	Height   int // input image height
+	Channels int // number of channels
+	Size     int // pooling window width and height
+	Stride   int // step between window positions
+}
+
+// convolution with same dimensions
+func (d PoolDims) conv() ConvDims {
+	return ConvDims{Width: d.Width, Height: d.Height, Channels: d.Channels, Filters: d.Channels,
+		Kernel: d.Size, Stride: d.Stride}
+}
+
+// OutWidth method returns the width of the output image.
+func (d PoolDims) OutWidth() int {
+	return d.conv().OutWidth()
+}
+
+// OutHeight method returns the height of the output image.
+func (d PoolDims) OutHeight() int {
+	return d.conv().OutHeight()
+}
+
+// InSize method returns the number of input values per sample.
+func (d PoolDims) InSize() int {
+	return d.conv().InSize()
+}
+
+// OutSize method returns the number of output values per sample.
+func (d PoolDims) OutSize() int {
+	return d.conv().OutSize()
+}
+
+func (d PoolDims) String() string {
+	return fmt.Sprintf("[%dx%dx%d] => [%dx%dx%d] size=%d stride=%d", d.Width, d.Height, d.Channels,
+		d.OutWidth(), d.OutHeight(), d.Channels, d.Size, d.Stride)
+}
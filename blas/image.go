@@ -27,15 +27,48 @@ type imagecl struct {
 	x0, y0   float32
 }
 
-// NewImage creates a new OpenCL image array
-func NewImage(width, height, nimage, channels int) Image {
-	var err cl.ErrorCode
-	if implementation != OpenCL32 {
-		panic("image only implemented for OpenCL32!")
-	}
+// Filter interface type represents a convolution filter used to generate distortion vectors.
+type Filter interface {
+	Apply(in Image, kernel, dx, dy Matrix)
+	Release()
+}
+
+// NewImage creates a new image array with the given number of channels.
+func NewImage(width, height, nimage, channels int) (img Image) {
 	if channels < 1 || channels > 2 {
 		panic("image must have 1 or 2 channels!")
 	}
+	switch implementation {
+	case Native32:
+		img = newimage32(width, height, nimage, channels)
+	case OpenCL32:
+		img = newimagecl(width, height, nimage, channels)
+	case Native64:
+		img = newimage64(width, height, nimage, channels)
+	default:
+		panic("matrix implementation is not set - call init first")
+	}
+	return
+}
+
+// NewFilter function creates a new filter with given size
+func NewFilter(size int) (f Filter) {
+	switch implementation {
+	case Native32:
+		f = filter32{size: size}
+	case OpenCL32:
+		f = newfiltercl(size)
+	case Native64:
+		f = filter64{filter32{size: size}}
+	default:
+		panic("matrix implementation is not set - call init first")
+	}
+	return
+}
+
+// create a new OpenCL image array
+func newimagecl(width, height, nimage, channels int) Image {
+	var err cl.ErrorCode
 	format := cl.ImageFormat{
 		ImageChannelOrder:    []cl.ChannelOrder{0, cl.R, cl.RG}[channels],
 		ImageChannelDataType: cl.FLOAT,
@@ -119,12 +152,12 @@ func (img *imagecl) Rotate(angle, dx, dy Matrix) {
 	k.EnqueueKernel(hw, []uint64{uint64(img.width), uint64(img.height), uint64(img.nimage)}, nil)
 }
 
-type Filter struct {
+type filtercl struct {
 	*scl.Software
 }
 
-// Create a new filter with given size
-func NewFilter(size int) Filter {
+// compile a new OpenCL filter kernel with given size
+func newfiltercl(size int) Filter {
 	src := srcHead + filterHead
 	for i := 0; i < size; i++ {
 		src += fmt.Sprintf(filterLoop, i, i)
@@ -136,11 +169,11 @@ func NewFilter(size int) Filter {
 	if err != nil {
 		panic(err)
 	}
-	return Filter{sw}
+	return filtercl{sw}
 }
 
 // Apply a convolution kernel to a distribution to generate a set of distorion vectors
-func (f *Filter) Apply(in Image, kernel, dx, dy Matrix) {
+func (f filtercl) Apply(in Image, kernel, dx, dy Matrix) {
 	kern := kernel.(*opencl32)
 	img := in.(*imagecl)
 	m1, m2 := dx.(*opencl32), dy.(*opencl32)
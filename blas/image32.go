@@ -0,0 +1,149 @@
+package blas
+
+import (
+	"math"
+)
+
+// array of images stored on the host with channel values interleaved for each pixel
+type image32 struct {
+	width    int
+	height   int
+	nimage   int
+	channels int
+	data     []float32
+	x0, y0   float32
+}
+
+// create a new native image array
+func newimage32(width, height, nimage, channels int) Image {
+	return &image32{
+		width:    width,
+		height:   height,
+		nimage:   nimage,
+		channels: channels,
+		data:     make([]float32, width*height*nimage*channels),
+		x0:       float32(width-1) / 2,
+		y0:       float32(height-1) / 2,
+	}
+}
+
+func (img *image32) Release() {}
+
+// get pixel value for given channel - points outside the image are clamped to zero
+func (img *image32) pixel(n, x, y, ch int) float32 {
+	if x < 0 || x >= img.width || y < 0 || y >= img.height {
+		return 0
+	}
+	return img.data[((n*img.height+y)*img.width+x)*img.channels+ch]
+}
+
+// Load from a buffer into the image array where each row in the buffer contains an image
+func (img *image32) Import(m ...Matrix) {
+	size := img.width * img.height
+	for ch := 0; ch < img.channels; ch++ {
+		a := m[ch].(*native32)
+		for n := 0; n < img.nimage; n++ {
+			for pos := 0; pos < size; pos++ {
+				img.data[(n*size+pos)*img.channels+ch] = a.at(n, pos)
+			}
+		}
+	}
+}
+
+// Apply 2D linear interpolation to and copy results to out matrix
+func (img *image32) Export(xv, yv, out Matrix) {
+	x, y, m := xv.(*native32), yv.(*native32), out.(*native32)
+	for n := 0; n < img.nimage; n++ {
+		for row := 0; row < img.height; row++ {
+			for col := 0; col < img.width; col++ {
+				xy := row*img.width + col
+				m.set(n, xy, img.bilinear(n, float32(col)+x.at(n, xy), float32(row)+y.at(n, xy)))
+			}
+		}
+	}
+}
+
+// interpolate between the four nearest pixels to point (x, y) on the first channel
+func (img *image32) bilinear(n int, x, y float32) float32 {
+	xf, yf := math.Floor(float64(x)), math.Floor(float64(y))
+	a, b := x-float32(xf), y-float32(yf)
+	i, j := int(xf), int(yf)
+	return (1-a)*(1-b)*img.pixel(n, i, j, 0) + a*(1-b)*img.pixel(n, i+1, j, 0) +
+		(1-a)*b*img.pixel(n, i, j+1, 0) + a*b*img.pixel(n, i+1, j+1, 0)
+}
+
+// Set the image center point for scaling and rotation
+func (img *image32) SetOrigin(x, y float32) Image {
+	img.x0, img.y0 = x, y
+	return img
+}
+
+// Generate distortion vectors for a scaling transformation
+func (img *image32) Scale(xscale, yscale, dx, dy Matrix) {
+	sx, sy := xscale.(*native32), yscale.(*native32)
+	xd, yd := dx.(*native32), dy.(*native32)
+	for n := 0; n < img.nimage; n++ {
+		for row := 0; row < img.height; row++ {
+			for col := 0; col < img.width; col++ {
+				xy := row*img.width + col
+				xd.set(n, xy, xd.at(n, xy)+(float32(col)-img.x0)*sx.at(0, n))
+				yd.set(n, xy, yd.at(n, xy)+(float32(row)-img.y0)*sy.at(0, n))
+			}
+		}
+	}
+}
+
+// Generate distortion vectors for rotation transformation
+func (img *image32) Rotate(angle, dx, dy Matrix) {
+	ang := angle.(*native32)
+	xd, yd := dx.(*native32), dy.(*native32)
+	for n := 0; n < img.nimage; n++ {
+		s, c := math.Sincos(float64(ang.at(0, n)))
+		sina, cosa := float32(s), float32(c)
+		for row := 0; row < img.height; row++ {
+			for col := 0; col < img.width; col++ {
+				xy := row*img.width + col
+				x, y := float32(col)-img.x0, float32(row)-img.y0
+				xd.set(n, xy, xd.at(n, xy)+(cosa-1)*x-sina*y)
+				yd.set(n, xy, yd.at(n, xy)+(cosa-1)*y+sina*x)
+			}
+		}
+	}
+}
+
+// convolution filter for native images
+type filter32 struct {
+	size int
+}
+
+func (f filter32) Release() {}
+
+// Apply a convolution kernel to a distribution to generate a set of distorion vectors
+func (f filter32) Apply(in Image, kernel, dx, dy Matrix) {
+	kern := kernel.(*native32)
+	img := in.(*image32)
+	m1, m2 := dx.(*native32), dy.(*native32)
+	m1.Reshape(img.nimage, img.width*img.height, false)
+	m2.Reshape(img.nimage, img.width*img.height, false)
+	half := f.size / 2
+	for n := 0; n < img.nimage; n++ {
+		for row := 0; row < img.height; row++ {
+			for col := 0; col < img.width; col++ {
+				var convx, convy float32
+				for y := 0; y < f.size; y++ {
+					yy := row + y - half
+					for x := 0; x < f.size; x++ {
+						k := kern.at(y, x)
+						convx += img.pixel(n, col+x-half, yy, 0) * k
+						if img.channels > 1 {
+							convy += img.pixel(n, col+x-half, yy, 1) * k
+						}
+					}
+				}
+				xy := row*img.width + col
+				m1.set(n, xy, convx)
+				m2.set(n, xy, convy)
+			}
+		}
+	}
+}
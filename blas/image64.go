@@ -0,0 +1,75 @@
+package blas
+
+// image array for the Native64 implementation: the pixels are stored at single precision in an image32
+// and the matrix arguments are converted to and from native32 matrices on each call
+type image64 struct {
+	*image32
+}
+
+// create a new image array for the Native64 implementation
+func newimage64(width, height, nimage, channels int) Image {
+	return image64{newimage32(width, height, nimage, channels).(*image32)}
+}
+
+// copy a matrix to a new native32 matrix
+func to32(m Matrix) *native32 {
+	return newnative32(m.Rows(), m.Cols()).Load(RowMajor, m.Data(RowMajor)...).(*native32)
+}
+
+// copy the data from a native32 matrix back to m
+func from32(m Matrix, a *native32) {
+	m.Reshape(a.Rows(), a.Cols(), false).Load(RowMajor, a.Data(RowMajor)...)
+}
+
+// Load from a buffer into the image array where each row in the buffer contains an image
+func (img image64) Import(m ...Matrix) {
+	in := make([]Matrix, len(m))
+	for i, a := range m {
+		in[i] = to32(a)
+	}
+	img.image32.Import(in...)
+}
+
+// Apply 2D linear interpolation to and copy results to out matrix
+func (img image64) Export(xv, yv, out Matrix) {
+	m := to32(out)
+	img.image32.Export(to32(xv), to32(yv), m)
+	from32(out, m)
+}
+
+// Set the image center point for scaling and rotation
+func (img image64) SetOrigin(x, y float32) Image {
+	img.image32.SetOrigin(x, y)
+	return img
+}
+
+// Generate distortion vectors for a scaling transformation
+func (img image64) Scale(xscale, yscale, dx, dy Matrix) {
+	xd, yd := to32(dx), to32(dy)
+	img.image32.Scale(to32(xscale), to32(yscale), xd, yd)
+	from32(dx, xd)
+	from32(dy, yd)
+}
+
+// Generate distortion vectors for rotation transformation
+func (img image64) Rotate(angle, dx, dy Matrix) {
+	xd, yd := to32(dx), to32(dy)
+	img.image32.Rotate(to32(angle), xd, yd)
+	from32(dx, xd)
+	from32(dy, yd)
+}
+
+// convolution filter for Native64 images
+type filter64 struct {
+	filter32
+}
+
+// Apply a convolution kernel to a distribution to generate a set of distorion vectors
+func (f filter64) Apply(in Image, kernel, dx, dy Matrix) {
+	img := in.(image64).image32
+	xd := to32(dx.Reshape(img.nimage, img.width*img.height, false))
+	yd := to32(dy.Reshape(img.nimage, img.width*img.height, false))
+	f.filter32.Apply(img, to32(kernel), xd, yd)
+	from32(dx, xd)
+	from32(dy, yd)
+}
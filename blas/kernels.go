@@ -5,6 +5,7 @@ const ()
 const (
 	copyKernel = iota
 	copyIxKernel
+	addRowsKernel
 	setKernel
 	scaleKernel
 	addKernel
@@ -12,6 +13,7 @@ const (
 	sumKernel
 	sumRowsKernel
 	maxColKernel
+	topKKernel
 	normKernel
 	histKernel
 	mulElemKernel
@@ -26,12 +28,23 @@ const (
 	scaleImageKernel
 	rotateKernel
 	randomKernel
+	convKernel
+	convGradInputKernel
+	convGradWeightsKernel
+	im2colKernel
+	col2imKernel
+	maxPoolKernel
+	maxPoolGradKernel
+	avgPoolKernel
+	avgPoolGradKernel
 	numKernels
 )
 
-var name = []string{"copy", "copyIx", "set", "scale", "add", "cmp", "sum", "sumrows", "maxcol", "norm",
-	"histogram", "mulelem", "transpose", "mul", "mulAT", "mulBT", "mulABT",
-	"loadImage", "loadImage2", "approx", "scaleImage", "rotateImage", "random"}
+var name = []string{"copy", "copyIx", "addRows", "set", "scale", "add", "cmp", "sum", "sumrows", "maxcol", "topk",
+	"norm", "histogram", "mulelem", "transpose", "mul", "mulAT", "mulBT", "mulABT",
+	"loadImage", "loadImage2", "approx", "scaleImage", "rotateImage", "random",
+	"conv", "convGradInput", "convGradWeights", "im2col", "col2im",
+	"maxPool", "maxPoolGrad", "avgPool", "avgPoolGrad"}
 
 var srcHead = `
 // Matrix header structure
@@ -73,6 +86,27 @@ var mulHead = `
 #define OPOS int2 pos = oTrans ? (int2)(gx+tx, gy+ty) : (int2)(gy+ty, gx+tx);
 `
 
+var convHead = `
+// Convolution dimensions
+typedef struct {
+	int width, height, channels, filters;
+	int kernel, stride, pad, outw, outh;
+} ConvDims;
+
+// loop over kernel positions which overlap input pixel (ix, iy)
+#define FOREACH_OUTPUT(cd, ix, iy) \
+	for (int ky = 0; ky < cd.kernel; ky++) { \
+		int oy = iy + cd.pad - ky; \
+		if (oy < 0 || oy % cd.stride != 0 || oy / cd.stride >= cd.outh) continue; \
+		oy /= cd.stride; \
+		for (int kx = 0; kx < cd.kernel; kx++) { \
+			int ox = ix + cd.pad - kx; \
+			if (ox < 0 || ox % cd.stride != 0 || ox / cd.stride >= cd.outw) continue; \
+			ox /= cd.stride;
+
+#define END_FOREACH }}
+`
+
 var unarySrc = `
 __kernel void unary(const Dims ad, const __global float* a, const Dims md, __global float* m) {
 	ARG float x = a[P(ad,row,col)];
@@ -107,10 +141,18 @@ var source = []string{
 	`__kernel void copy(const Dims ad, const __global float* a, const Dims md, __global float* m) {
 	ARG m[P(md,row,col)] = a[P(ad,row,col)];
 }`,
-	`__kernel void copyIx(const Dims ad, const __global float* a, const Dims id, __global float* ix, 
+	`__kernel void copyIx(const Dims ad, const __global float* a, const Dims id, __global float* idx, 
 		const Dims md, __global float* m) {
-	ARG int irow = ix[P(id,row,0)];
+	ARG int irow = idx[P(id,row,0)];
 	m[P(md,row,col)] = a[P(ad,irow,col)];
+}`,
+	`__kernel void addRows(const Dims ad, const __global float* a, const Dims id, const __global float* idx, 
+		const Dims md, __global float* m) {
+	ARG float sum = 0.f;
+	for (int r = 0; r < ad.rows; r++) {
+		if ((int)idx[P(id,r,0)] == row) sum += a[P(ad,r,col)];
+	}
+	m[P(md,row,col)] += sum;
 }`,
 	`__kernel void set(const float val, const Dims md, __global float* m) {
 	ARG	m[P(md,row,col)] = val;
@@ -162,6 +204,14 @@ var source = []string{
 		}
 	}
 	m[P(md,row,0)] = (float)maxcol;
+}`,
+	`__kernel void topk(const Dims ad, const __global float* a, const Dims md, __global float* m) {
+	ARG float v = a[P(ad,row,col)]; float x;
+	int rank = 0;
+	for (int c = 0; c < ad.cols; c++) {
+		if ((x = a[P(ad,row,c)]) > v || (x == v && c < col)) rank++;
+	}
+	if (rank < md.cols) m[P(md,row,rank)] = (float)col;
 }`,
 	`__kernel void norm(const Dims ad, const __global float* a, const Dims md, __global float* m) {
 	const int row = get_global_id(0);
@@ -335,6 +385,159 @@ __kernel void random(const Dims md, __global float* m, const float rmin, const f
 	uint2 state = seedsIn[id];
 	m[P(md, pos/md.cols, pos%md.cols)] = rmin + range * rnd(&state);
 	seedsOut[id] = state;
+}`,
+	`__kernel void conv(const ConvDims cd, const Dims ad, const __global float* a, const Dims wd, const __global float* w,
+		const Dims md, __global float* m) {
+	ARG
+	const int f = col % cd.filters;
+	const int ox = (col / cd.filters) % cd.outw;
+	const int oy = (col / cd.filters) / cd.outw;
+	float sum = w[P(wd, f, cd.kernel*cd.kernel*cd.channels)];
+	for (int ky = 0; ky < cd.kernel; ky++) {
+		const int iy = oy*cd.stride - cd.pad + ky;
+		if (iy < 0 || iy >= cd.height) continue;
+		for (int kx = 0; kx < cd.kernel; kx++) {
+			const int ix = ox*cd.stride - cd.pad + kx;
+			if (ix < 0 || ix >= cd.width) continue;
+			const int ipos = (iy*cd.width + ix)*cd.channels;
+			const int wpos = (ky*cd.kernel + kx)*cd.channels;
+			for (int c = 0; c < cd.channels; c++) {
+				sum += a[P(ad, row, ipos+c)] * w[P(wd, f, wpos+c)];
+			}
+		}
+	}
+	m[P(md,row,col)] = sum;
+}`,
+	`__kernel void convGradInput(const ConvDims cd, const Dims gd, const __global float* g, const Dims wd, const __global float* w,
+		const Dims md, __global float* m) {
+	ARG
+	const int c = col % cd.channels;
+	const int ix = (col / cd.channels) % cd.width;
+	const int iy = (col / cd.channels) / cd.width;
+	float sum = 0.f;
+	FOREACH_OUTPUT(cd, ix, iy)
+		const int opos = (oy*cd.outw + ox)*cd.filters;
+		const int wpos = (ky*cd.kernel + kx)*cd.channels + c;
+		for (int f = 0; f < cd.filters; f++) {
+			sum += g[P(gd, row, opos+f)] * w[P(wd, f, wpos)];
+		}
+	END_FOREACH
+	m[P(md,row,col)] = sum;
+}`,
+	`__kernel void convGradWeights(const ConvDims cd, const Dims gd, const __global float* g, const Dims ad, const __global float* a,
+		const Dims md, __global float* m) {
+	ARG
+	const int f = row;
+	const int kkc = cd.kernel*cd.kernel*cd.channels;
+	float sum = 0.f;
+	if (col == kkc) {
+		for (int n = 0; n < gd.rows; n++) {
+			for (int pos = 0; pos < cd.outw*cd.outh; pos++) {
+				sum += g[P(gd, n, pos*cd.filters+f)];
+			}
+		}
+	} else {
+		const int c = col % cd.channels;
+		const int kx = (col / cd.channels) % cd.kernel;
+		const int ky = (col / cd.channels) / cd.kernel;
+		for (int n = 0; n < gd.rows; n++) {
+			for (int oy = 0; oy < cd.outh; oy++) {
+				const int iy = oy*cd.stride - cd.pad + ky;
+				if (iy < 0 || iy >= cd.height) continue;
+				for (int ox = 0; ox < cd.outw; ox++) {
+					const int ix = ox*cd.stride - cd.pad + kx;
+					if (ix < 0 || ix >= cd.width) continue;
+					sum += g[P(gd, n, (oy*cd.outw+ox)*cd.filters+f)] * a[P(ad, n, (iy*cd.width+ix)*cd.channels+c)];
+				}
+			}
+		}
+	}
+	m[P(md,row,col)] = sum;
+}`,
+	`__kernel void im2col(const ConvDims cd, const Dims ad, const __global float* a, const Dims md, __global float* m) {
+	ARG
+	const int n = row / (cd.outw*cd.outh);
+	const int ox = (row % (cd.outw*cd.outh)) % cd.outw;
+	const int oy = (row % (cd.outw*cd.outh)) / cd.outw;
+	const int c = col % cd.channels;
+	const int kx = (col / cd.channels) % cd.kernel;
+	const int ky = (col / cd.channels) / cd.kernel;
+	const int iy = oy*cd.stride - cd.pad + ky;
+	const int ix = ox*cd.stride - cd.pad + kx;
+	if (iy >= 0 && iy < cd.height && ix >= 0 && ix < cd.width) {
+		m[P(md,row,col)] = a[P(ad, n, (iy*cd.width+ix)*cd.channels+c)];
+	} else {
+		m[P(md,row,col)] = 0.f;
+	}
+}`,
+	`__kernel void col2im(const ConvDims cd, const Dims ad, const __global float* a, const Dims md, __global float* m) {
+	ARG
+	const int c = col % cd.channels;
+	const int ix = (col / cd.channels) % cd.width;
+	const int iy = (col / cd.channels) / cd.width;
+	float sum = 0.f;
+	FOREACH_OUTPUT(cd, ix, iy)
+		sum += a[P(ad, row*cd.outw*cd.outh + oy*cd.outw + ox, (ky*cd.kernel + kx)*cd.channels + c)];
+	END_FOREACH
+	m[P(md,row,col)] = sum;
+}`,
+	`__kernel void maxPool(const ConvDims cd, const Dims ad, const __global float* a, const Dims id, __global float* idx,
+		const Dims md, __global float* m) {
+	ARG
+	const int c = col % cd.channels;
+	const int ox = (col / cd.channels) % cd.outw;
+	const int oy = (col / cd.channels) / cd.outw;
+	int imax = ((oy*cd.stride)*cd.width + ox*cd.stride)*cd.channels + c;
+	float vmax = a[P(ad,row,imax)];
+	for (int ky = 0; ky < cd.kernel; ky++) {
+		for (int kx = 0; kx < cd.kernel; kx++) {
+			int ipos = ((oy*cd.stride + ky)*cd.width + ox*cd.stride + kx)*cd.channels + c;
+			float val = a[P(ad,row,ipos)];
+			if (val > vmax) {
+				vmax = val;
+				imax = ipos;
+			}
+		}
+	}
+	m[P(md,row,col)] = vmax;
+	idx[P(id,row,col)] = imax;
+}`,
+	`__kernel void maxPoolGrad(const ConvDims cd, const Dims gd, const __global float* g, const Dims id, const __global float* idx,
+		const Dims md, __global float* m) {
+	ARG
+	const int c = col % cd.channels;
+	const int ix = (col / cd.channels) % cd.width;
+	const int iy = (col / cd.channels) / cd.width;
+	float sum = 0.f;
+	FOREACH_OUTPUT(cd, ix, iy)
+		int opos = (oy*cd.outw + ox)*cd.channels + c;
+		if ((int)idx[P(id,row,opos)] == col) sum += g[P(gd,row,opos)];
+	END_FOREACH
+	m[P(md,row,col)] = sum;
+}`,
+	`__kernel void avgPool(const ConvDims cd, const Dims ad, const __global float* a, const Dims md, __global float* m) {
+	ARG
+	const int c = col % cd.channels;
+	const int ox = (col / cd.channels) % cd.outw;
+	const int oy = (col / cd.channels) / cd.outw;
+	float sum = 0.f;
+	for (int ky = 0; ky < cd.kernel; ky++) {
+		for (int kx = 0; kx < cd.kernel; kx++) {
+			sum += a[P(ad,row,((oy*cd.stride + ky)*cd.width + ox*cd.stride + kx)*cd.channels + c)];
+		}
+	}
+	m[P(md,row,col)] = sum / (cd.kernel*cd.kernel);
+}`,
+	`__kernel void avgPoolGrad(const ConvDims cd, const Dims gd, const __global float* g, const Dims md, __global float* m) {
+	ARG
+	const int c = col % cd.channels;
+	const int ix = (col / cd.channels) % cd.width;
+	const int iy = (col / cd.channels) / cd.width;
+	float sum = 0.f;
+	FOREACH_OUTPUT(cd, ix, iy)
+		sum += g[P(gd,row,(oy*cd.outw + ox)*cd.channels + c)];
+	END_FOREACH
+	m[P(md,row,col)] = sum / (cd.kernel*cd.kernel);
 }`,
 }
 
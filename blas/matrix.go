@@ -15,6 +15,7 @@ const (
 	none Impl = iota
 	Native32
 	OpenCL32
+	Native64
 )
 
 // Switch for column major or row major data represntation
@@ -32,6 +33,7 @@ type Matrix interface {
 	Size() int
 	Release()
 	Copy(m, ix Matrix) Matrix
+	AddRows(m, ix Matrix) Matrix
 	Transpose(m Matrix) Matrix
 	Reshape(rows, cols int, shrink bool) Matrix
 	Set(val float32) Matrix
@@ -48,8 +50,18 @@ type Matrix interface {
 	Sum() float32
 	SumRows(a Matrix) Matrix
 	MaxCol(m Matrix) Matrix
+	TopK(m Matrix, k int) Matrix
 	Norm(m Matrix) Matrix
 	Histogram(m Matrix, bins int, min, max float32) Matrix
+	Conv(in, weights Matrix, d ConvDims) Matrix
+	ConvGradInput(delta, weights Matrix, d ConvDims) Matrix
+	ConvGradWeights(delta, in Matrix, d ConvDims) Matrix
+	Im2Col(in Matrix, d ConvDims) Matrix
+	Col2Im(in Matrix, d ConvDims) Matrix
+	MaxPool(in, index Matrix, d PoolDims) Matrix
+	MaxPoolGrad(delta, index Matrix, d PoolDims) Matrix
+	AvgPool(in Matrix, d PoolDims) Matrix
+	AvgPoolGrad(delta Matrix, d PoolDims) Matrix
 	SetFormat(string)
 	String() string
 }
@@ -86,6 +98,8 @@ func New(rows, cols int) (m Matrix) {
 		m = newnative32(rows, cols)
 	case OpenCL32:
 		m = newopencl32(rows, cols)
+	case Native64:
+		m = newnative64(rows, cols)
 	default:
 		panic("matrix implementation is not set - call init first")
 	}
@@ -112,6 +126,24 @@ func (fn Unary32) Apply(in, out Matrix) Matrix {
 	switch m := out.(type) {
 	case *native32:
 		m.apply(in, fn)
+	case *native64:
+		m.apply(in, func(x float64) float64 { return float64(fn(float32(x))) })
+	default:
+		panic("invalid type for apply")
+	}
+	return out
+}
+
+// Unary64 represents a float64 function of one variable
+type Unary64 func(float64) float64
+
+// Apply method applies a function to each element in a matrix
+func (fn Unary64) Apply(in, out Matrix) Matrix {
+	switch m := out.(type) {
+	case *native32:
+		m.apply(in, func(x float32) float32 { return float32(fn(float64(x))) })
+	case *native64:
+		m.apply(in, fn)
 	default:
 		panic("invalid type for apply")
 	}
@@ -131,6 +163,24 @@ func (fn Binary32) Apply(m1, m2, out Matrix) Matrix {
 	switch m := out.(type) {
 	case *native32:
 		m.apply2(m1, m2, fn)
+	case *native64:
+		m.apply2(m1, m2, func(a, b float64) float64 { return float64(fn(float32(a), float32(b))) })
+	default:
+		panic("invalid type for apply")
+	}
+	return out
+}
+
+// Binary64 represents a float64 function of two variables
+type Binary64 func(a, b float64) float64
+
+// Apply method applies a function to each element in a matrix
+func (fn Binary64) Apply(m1, m2, out Matrix) Matrix {
+	switch m := out.(type) {
+	case *native32:
+		m.apply2(m1, m2, func(a, b float32) float32 { return float32(fn(float64(a), float64(b))) })
+	case *native64:
+		m.apply2(m1, m2, fn)
 	default:
 		panic("invalid type for apply")
 	}
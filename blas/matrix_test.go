@@ -2,6 +2,7 @@ package blas
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
 	"reflect"
 	"testing"
@@ -49,6 +50,34 @@ func TestCopy(t *testing.T) {
 	m.Release()
 }
 
+func TestAddRows(t *testing.T) {
+	m := New(4, 2).Load(RowMajor, 1, 2, 3, 4, 5, 6, 7, 8)
+	ix := New(4, 1).Load(ColMajor, 2, 0, 2, 1)
+	m2 := New(3, 3).Load(RowMajor, 1)
+	m2.Col(0, 2).AddRows(m, ix)
+	m2.SetFormat("%3.0f")
+	t.Logf("m2\n%s\n", m2)
+	expect := []float32{4, 5, 1, 8, 9, 1, 7, 9, 1}
+	if !reflect.DeepEqual(m2.Data(RowMajor), expect) {
+		t.Error("expected", expect)
+	}
+	// out of range index should panic for the native implementations - OpenCL does not read back the indices
+	if implementation != OpenCL32 {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Error("expected panic for index out of range")
+				}
+			}()
+			ix.Load(ColMajor, 2, 0, 3, 1)
+			m2.Col(0, 2).AddRows(m, ix)
+		}()
+	}
+	ix.Release()
+	m2.Release()
+	m.Release()
+}
+
 func TestTranspose(t *testing.T) {
 	expect := []float32{1, 2, 3, 4, 5, 6}
 	m := New(2, 3).Load(RowMajor, expect...)
@@ -352,6 +381,21 @@ func TestMaxCol(t *testing.T) {
 	c.Release()
 }
 
+func TestTopK(t *testing.T) {
+	m := New(3, 4).Load(RowMajor, 1, 4, 2, 3, 0.5, 0.5, 0.2, 0.9, 3, 2, 1, 0)
+	m.SetFormat("%4.1f")
+	t.Logf("\n%s\n", m)
+	c := New(3, 4).TopK(m, 3)
+	c.SetFormat("%3.0f")
+	t.Logf("\n%s\n", c)
+	expect := []float32{1, 3, 2, 3, 0, 1, 0, 1, 2}
+	if c.Rows() != 3 || c.Cols() != 3 || !reflect.DeepEqual(c.Data(RowMajor), expect) {
+		t.Error("expected", expect)
+	}
+	m.Release()
+	c.Release()
+}
+
 func TestNorm(t *testing.T) {
 	m := New(3, 3).Load(RowMajor, 2, 1, 1, 3, 0, 0, 5, 2.5, -2.5)
 	m.SetFormat("%5.2f")
@@ -416,6 +460,188 @@ func TestImage(t *testing.T) {
 	ya.Release()
 }
 
+func TestImageShift(t *testing.T) {
+	size := 4
+	img := NewImage(size, size, 1, 1)
+	data := New(1, size*size).Load(RowMajor, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16)
+	img.Import(data)
+	out := New(1, size*size)
+	xa := New(1, size*size).Set(1)
+	ya := New(1, size*size).Set(0.5)
+	img.Export(xa, ya, out)
+	out.SetFormat("%5.2f")
+	t.Logf("out\n%s\n", out)
+	expect := []float32{4, 5, 6, 0, 8, 9, 10, 0, 12, 13, 14, 0, 7, 7.5, 8, 0}
+	checkClose(t, out.Data(RowMajor), expect, 1e-3)
+	// scale by factor of 2 about the origin and rotate by 90 degrees
+	xa.Set(0)
+	ya.Set(0)
+	img.SetOrigin(0, 0)
+	img.Scale(New(1, 1).Set(1), New(1, 1).Set(0), xa, ya)
+	img.Export(xa, ya, out)
+	t.Logf("scaled\n%s\n", out)
+	expect = []float32{1, 3, 0, 0, 5, 7, 0, 0, 9, 11, 0, 0, 13, 15, 0, 0}
+	checkClose(t, out.Data(RowMajor), expect, 1e-3)
+	xa.Set(0)
+	ya.Set(0)
+	img.Rotate(New(1, 1).Set(math.Pi/2), xa, ya)
+	img.Export(xa, ya, out)
+	t.Logf("rotated\n%s\n", out)
+	expect = []float32{1, 5, 9, 13, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	checkClose(t, out.Data(RowMajor), expect, 1e-3)
+	img.Release()
+	data.Release()
+	out.Release()
+	xa.Release()
+	ya.Release()
+}
+
+func TestFilterImpulse(t *testing.T) {
+	size := 9
+	ksize := 5
+	kernel := GaussianKernel(ksize, ksize, 1.0)
+	filter := NewFilter(ksize)
+	in := New(1, size*size).Set(0)
+	in.Col(size*size/2, size*size/2+1).Set(1)
+	img := NewImage(size, size, 1, 2)
+	img.Import(in, in)
+	outx := New(1, size*size)
+	outy := New(1, size*size)
+	filter.Apply(img, kernel, outx, outy)
+	// response to an impulse at the center is the kernel itself
+	kdata := kernel.Data(RowMajor)
+	expect := make([]float32, size*size)
+	off := (size - ksize) / 2
+	for y := 0; y < ksize; y++ {
+		for x := 0; x < ksize; x++ {
+			expect[(y+off)*size+x+off] = kdata[y*ksize+x]
+		}
+	}
+	checkClose(t, outx.Data(RowMajor), expect, 1e-5)
+	checkClose(t, outy.Data(RowMajor), expect, 1e-5)
+	in.Release()
+	outx.Release()
+	outy.Release()
+	kernel.Release()
+	img.Release()
+	filter.Release()
+}
+
+func checkClose(t *testing.T, got, expect []float32, eps float32) {
+	for i := range expect {
+		if fabs(got[i]-expect[i]) > eps {
+			t.Errorf("expected %v got %v", expect, got)
+			return
+		}
+	}
+}
+
+func dot(a, b Matrix) float64 {
+	da, db := a.Data(RowMajor), b.Data(RowMajor)
+	sum := 0.0
+	for i := range da {
+		sum += float64(da[i]) * float64(db[i])
+	}
+	return sum
+}
+
+func TestConv(t *testing.T) {
+	rand.Seed(1)
+	for _, d := range []ConvDims{
+		{Width: 5, Height: 4, Channels: 2, Filters: 3, Kernel: 3, Stride: 1, Pad: 1},
+		{Width: 7, Height: 7, Channels: 1, Filters: 2, Kernel: 3, Stride: 2, Pad: 0},
+		{Width: 6, Height: 5, Channels: 3, Filters: 4, Kernel: 2, Stride: 2, Pad: 1},
+	} {
+		t.Log(d)
+		samples, kkc := 3, d.KernelSize()
+		in := New(samples, d.InSize()).Load(RowMajor, rand32(samples*d.InSize())...)
+		w := New(d.Filters, kkc+1).Load(RowMajor, rand32(d.Filters*(kkc+1))...)
+		out := New(samples, d.OutSize()).Conv(in, w, d)
+		// compare with im2col followed by matrix multiply for the first sample
+		cols := New(samples*d.OutWidth()*d.OutHeight(), kkc).Im2Col(in.Row(0, 1), d)
+		ref := New(d.OutWidth()*d.OutHeight(), d.Filters).Mul(cols, w.Col(0, kkc), false, true, false)
+		refData := ref.Data(RowMajor)
+		bias := w.Col(kkc, kkc+1).Data(ColMajor)
+		for i := range refData {
+			refData[i] += bias[i%d.Filters]
+		}
+		checkClose(t, out.Row(0, 1).Data(RowMajor), refData, 1e-5)
+		// gradients are the adjoint of the convolution: <delta, conv(x, w)> = <grad_x, x> = <grad_w, w>
+		delta := New(samples, d.OutSize()).Load(RowMajor, rand32(samples*d.OutSize())...)
+		w.Col(kkc, kkc+1).Set(0)
+		out.Conv(in, w, d)
+		expect := dot(delta, out)
+		gradIn := New(samples, d.InSize()).ConvGradInput(delta, w, d)
+		gradW := New(d.Filters, kkc+1).ConvGradWeights(delta, in, d)
+		t.Logf("dot products: %.5f %.5f %.5f", expect, dot(gradIn, in), dot(gradW, w))
+		if math.Abs(dot(gradIn, in)-expect) > 1e-4 || math.Abs(dot(gradW, w)-expect) > 1e-4 {
+			t.Error("convolution gradients are inconsistent")
+		}
+		biasGrad := gradW.Col(kkc, kkc+1).Data(ColMajor)
+		for f := 0; f < d.Filters; f++ {
+			sum := 0.0
+			for i, val := range delta.Data(RowMajor) {
+				if i%d.Filters == f {
+					sum += float64(val)
+				}
+			}
+			if math.Abs(float64(biasGrad[f])-sum) > 1e-4 {
+				t.Errorf("bias gradient: expected %g got %g", sum, biasGrad[f])
+			}
+		}
+		// col2im is the adjoint of im2col
+		cols.Im2Col(in, d)
+		y := New(cols.Rows(), cols.Cols()).Load(RowMajor, rand32(cols.Rows()*cols.Cols())...)
+		img := New(samples, d.InSize()).Col2Im(y, d)
+		if math.Abs(dot(cols, y)-dot(in, img)) > 1e-4 {
+			t.Errorf("col2im: expected %g got %g", dot(cols, y), dot(in, img))
+		}
+		for _, m := range []Matrix{in, w, out, cols, ref, delta, gradIn, gradW, y, img} {
+			m.Release()
+		}
+	}
+}
+
+func TestPool(t *testing.T) {
+	d := PoolDims{Width: 4, Height: 4, Channels: 1, Size: 2, Stride: 2}
+	t.Log(d)
+	in := New(1, d.InSize()).Load(RowMajor,
+		1, 2, 3, 4,
+		8, 7, 6, 5,
+		0, 0, 1, -1,
+		-2, 3, 2, 2)
+	index := New(1, d.OutSize())
+	out := New(1, d.OutSize()).MaxPool(in, index, d)
+	checkClose(t, out.Data(RowMajor), []float32{8, 6, 3, 2}, 1e-6)
+	checkClose(t, index.Data(RowMajor), []float32{4, 6, 13, 14}, 1e-6)
+	delta := New(1, d.OutSize()).Load(RowMajor, 1, 2, 3, 4)
+	grad := New(1, d.InSize()).MaxPoolGrad(delta, index, d)
+	checkClose(t, grad.Data(RowMajor), []float32{0, 0, 0, 0, 1, 0, 2, 0, 0, 0, 0, 0, 0, 3, 4, 0}, 1e-6)
+	out.AvgPool(in, d)
+	checkClose(t, out.Data(RowMajor), []float32{4.5, 4.5, 0.25, 1}, 1e-6)
+	// average pooling gradient is the adjoint of the forward pass with overlapping windows
+	rand.Seed(1)
+	d = PoolDims{Width: 7, Height: 6, Channels: 3, Size: 3, Stride: 2}
+	samples := 3
+	in2 := New(samples, d.InSize()).Load(RowMajor, rand32(samples*d.InSize())...)
+	out2 := New(samples, d.OutSize()).AvgPool(in2, d)
+	delta2 := New(samples, d.OutSize()).Load(RowMajor, rand32(samples*d.OutSize())...)
+	grad2 := New(samples, d.InSize()).AvgPoolGrad(delta2, d)
+	if math.Abs(dot(delta2, out2)-dot(grad2, in2)) > 1e-4 {
+		t.Errorf("avgPoolGrad: expected %g got %g", dot(delta2, out2), dot(grad2, in2))
+	}
+	// max pooling gradient sums to the same total as delta
+	index2 := New(samples, d.OutSize())
+	out2.MaxPool(in2, index2, d)
+	grad2.MaxPoolGrad(delta2, index2, d)
+	if math.Abs(float64(grad2.Sum()-delta2.Sum())) > 1e-4 {
+		t.Errorf("maxPoolGrad: expected sum %g got %g", delta2.Sum(), grad2.Sum())
+	}
+	for _, m := range []Matrix{in, index, out, delta, grad, in2, out2, delta2, grad2, index2} {
+		m.Release()
+	}
+}
+
 func printImg(title string, size int, m Matrix, t *testing.T) {
 	data := m.Data(RowMajor)
 	img := New(size, size).Load(RowMajor, data...)
@@ -126,6 +126,25 @@ func (m *native32) Copy(in, ix Matrix) Matrix {
 	return m
 }
 
+// AddRows method adds each row of the input matrix to the row of m given by the ix column vector.
+// This is the reverse of Copy with an index: rows with the same index are summed.
+func (m *native32) AddRows(in, ix Matrix) Matrix {
+	a, b := in.(*native32), ix.(*native32)
+	if a.cols != m.cols || b.rows != a.rows {
+		panic("blas:AddRows - mismatch in no. of rows and columns in input matrices")
+	}
+	for row := 0; row < a.rows; row++ {
+		ixrow := int(b.at(row, 0))
+		if ixrow < 0 || ixrow >= m.rows {
+			panic("blas:AddRows - index out of range")
+		}
+		for col := 0; col < m.cols; col++ {
+			m.set(ixrow, col, m.at(ixrow, col)+a.at(row, col))
+		}
+	}
+	return m
+}
+
 // Transpose method returns a transposed copy of the input matrix
 func (m *native32) Transpose(in Matrix) Matrix {
 	a := in.(*native32)
@@ -174,21 +193,25 @@ func (m *native32) Col(c1, c2 int) Matrix {
 
 // Set method sets all elements of the matrix to the given value
 func (m *native32) Set(val float32) Matrix {
-	for row := 0; row < m.rows; row++ {
-		for col := 0; col < m.cols; col++ {
-			m.set(row, col, val)
+	parallel(m.rows, rowGrain(m.cols), func(_, r1, r2 int) {
+		for row := r1; row < r2; row++ {
+			for col := 0; col < m.cols; col++ {
+				m.set(row, col, val)
+			}
 		}
-	}
+	})
 	return m
 }
 
 // Scale method muliplies each element of the matrix by a scalar.
 func (m *native32) Scale(s float32) Matrix {
-	for row := 0; row < m.rows; row++ {
-		for col := 0; col < m.cols; col++ {
-			m.set(row, col, m.at(row, col)*s)
+	parallel(m.rows, rowGrain(m.cols), func(_, r1, r2 int) {
+		for row := r1; row < r2; row++ {
+			for col := 0; col < m.cols; col++ {
+				m.set(row, col, m.at(row, col)*s)
+			}
 		}
-	}
+	})
 	return m
 }
 
@@ -196,11 +219,13 @@ func (m *native32) Scale(s float32) Matrix {
 func (m *native32) Add(m1, m2 Matrix, sc float32) Matrix {
 	checkEqualSize("blas:Add", m1, m2, m)
 	a, b := m1.(*native32), m2.(*native32)
-	for row := 0; row < m.rows; row++ {
-		for col := 0; col < m.cols; col++ {
-			m.set(row, col, a.at(row, col)+sc*b.at(row, col))
+	parallel(m.rows, rowGrain(m.cols), func(_, r1, r2 int) {
+		for row := r1; row < r2; row++ {
+			for col := 0; col < m.cols; col++ {
+				m.set(row, col, a.at(row, col)+sc*b.at(row, col))
+			}
 		}
-	}
+	})
 	return m
 }
 
@@ -208,16 +233,18 @@ func (m *native32) Add(m1, m2 Matrix, sc float32) Matrix {
 func (m *native32) Cmp(m1, m2 Matrix, eps float32) Matrix {
 	checkEqualSize("blas:Cmp", m1, m2, m)
 	a, b := m1.(*native32), m2.(*native32)
-	for row := 0; row < m.rows; row++ {
-		for col := 0; col < m.cols; col++ {
-			ax, bx := a.at(row, col), b.at(row, col)
-			if ax < bx-eps || ax > bx+eps {
-				m.set(row, col, 1)
-			} else {
-				m.set(row, col, 0)
+	parallel(m.rows, rowGrain(m.cols), func(_, r1, r2 int) {
+		for row := r1; row < r2; row++ {
+			for col := 0; col < m.cols; col++ {
+				ax, bx := a.at(row, col), b.at(row, col)
+				if ax < bx-eps || ax > bx+eps {
+					m.set(row, col, 1)
+				} else {
+					m.set(row, col, 0)
+				}
 			}
 		}
-	}
+	})
 	return m
 }
 
@@ -226,65 +253,97 @@ func (m *native32) Cmp(m1, m2 Matrix, eps float32) Matrix {
 func (m *native32) MulElem(m1, m2 Matrix) Matrix {
 	checkEqualSize("blas:Cmp", m1, m2, m)
 	a, b := m1.(*native32), m2.(*native32)
-	for row := 0; row < a.rows; row++ {
-		for col := 0; col < a.cols; col++ {
-			m.set(row, col, a.at(row, col)*b.at(row, col))
+	parallel(a.rows, rowGrain(a.cols), func(_, r1, r2 int) {
+		for row := r1; row < r2; row++ {
+			for col := 0; col < a.cols; col++ {
+				m.set(row, col, a.at(row, col)*b.at(row, col))
+			}
 		}
-	}
+	})
 	return m
 }
 
 // Mul method multiplies two matrices using regular matrix multiplication and puts the output in m.
+// Uses a cache blocked algorithm where the transpose flags are handled by swapping the strides
+// and blocks of output rows are processed in parallel. Each output element is always summed in order.
 func (m *native32) Mul(m1, m2 Matrix, aTrans, bTrans, oTrans bool) Matrix {
 	a, b := m1.(*native32), m2.(*native32)
 	ar, ac, br, bc := a.rows, a.cols, b.rows, b.cols
-	aget := func(r, c int) float32 { return a.at(r, c) }
-	bget := func(r, c int) float32 { return b.at(r, c) }
-
+	// strides to step along the rows and inner dimension of a, inner dimension and cols of b
+	ai, ak := a.stride, 1
+	bk, bj := b.stride, 1
 	if aTrans {
 		ar, ac = ac, ar
-		aget = func(r, c int) float32 { return a.at(c, r) }
+		ai, ak = 1, a.stride
 	}
 	if bTrans {
 		br, bc = bc, br
-		bget = func(r, c int) float32 { return b.at(c, r) }
+		bk, bj = 1, b.stride
 	}
 	if ac != br {
 		panic("blas:Mul - mismatch in no. of rows and columns in input matrices")
 	}
+	var oi, oj int
 	if oTrans {
 		m.Reshape(bc, ar, true)
-		for row := 0; row < ar; row++ {
-			for col := 0; col < bc; col++ {
-				sum := float32(0)
-				for k := 0; k < ac; k++ {
-					sum += aget(row, k) * bget(k, col)
-				}
-				m.set(col, row, sum)
-			}
-		}
+		oi, oj = 1, m.stride
 	} else {
 		m.Reshape(ar, bc, true)
-		for row := 0; row < ar; row++ {
-			for col := 0; col < bc; col++ {
-				sum := float32(0)
-				for k := 0; k < ac; k++ {
-					sum += aget(row, k) * bget(k, col)
+		oi, oj = m.stride, 1
+	}
+	parallel(ar, 1+(minWork-1)/(ac*bc+1), func(_, r1, r2 int) {
+		for i := r1; i < r2; i++ {
+			for j := 0; j < bc; j++ {
+				m.data[i*oi+j*oj] = 0
+			}
+		}
+		for k0 := 0; k0 < ac; k0 += natBlock {
+			k1 := imin(k0+natBlock, ac)
+			for j0 := 0; j0 < bc; j0 += natBlock {
+				j1 := imin(j0+natBlock, bc)
+				for i := r1; i < r2; i++ {
+					out := i * oi
+					for k := k0; k < k1; k++ {
+						aik := a.data[i*ai+k*ak]
+						bpos := k * bk
+						if oj == 1 && bj == 1 {
+							mrow := m.data[out+j0 : out+j1]
+							brow := b.data[bpos+j0 : bpos+j1]
+							for j, bkj := range brow {
+								mrow[j] += aik * bkj
+							}
+						} else {
+							for j := j0; j < j1; j++ {
+								m.data[out+j*oj] += aik * b.data[bpos+j*bj]
+							}
+						}
+					}
 				}
-				m.set(row, col, sum)
 			}
 		}
-	}
+	})
 	return m
 }
 
 // Sum method calculates the sum of the values in the matrix
 func (m *native32) Sum() float32 {
-	var sum float32
-	for row := 0; row < m.rows; row++ {
-		for col := 0; col < m.cols; col++ {
-			sum += m.at(row, col)
+	// use the same pool for the partition and the run in case the number of threads is changed
+	p := startPool()
+	defer p.release()
+	nc := p.chunks(m.rows, rowGrain(m.cols))
+	partial := make([]float32, nc)
+	p.run(m.rows, nc, func(chunk, r1, r2 int) {
+		var sum float32
+		for row := r1; row < r2; row++ {
+			for col := 0; col < m.cols; col++ {
+				sum += m.at(row, col)
+			}
 		}
+		partial[chunk] = sum
+	})
+	var sum float32
+	for _, val := range partial {
+		sum += val
 	}
 	return sum
 }
@@ -293,13 +352,15 @@ func (m *native32) Sum() float32 {
 func (m *native32) SumRows(in Matrix) Matrix {
 	a := in.(*native32)
 	m.Reshape(a.rows, 1, false)
-	for row := 0; row < a.rows; row++ {
-		sum := float32(0)
-		for col := 0; col < a.cols; col++ {
-			sum += a.at(row, col)
+	parallel(a.rows, rowGrain(a.cols), func(_, r1, r2 int) {
+		for row := r1; row < r2; row++ {
+			sum := float32(0)
+			for col := 0; col < a.cols; col++ {
+				sum += a.at(row, col)
+			}
+			m.set(row, 0, sum)
 		}
-		m.set(row, 0, sum)
-	}
+	})
 	return m
 }
 
@@ -322,19 +383,48 @@ func (v *native32) MaxCol(in Matrix) Matrix {
 	return v
 }
 
+// TopK method gets the column numbers of the k largest values in each row of the input matrix in
+// descending order of value. Where values are equal the lower column number comes first.
+func (v *native32) TopK(in Matrix, k int) Matrix {
+	m := in.(*native32)
+	if k < 1 || k > m.cols {
+		panic("blas:TopK - k is out of range")
+	}
+	v.Reshape(m.rows, k, false)
+	used := make([]bool, m.cols)
+	for row := 0; row < m.rows; row++ {
+		for col := range used {
+			used[col] = false
+		}
+		for i := 0; i < k; i++ {
+			max, maxcol := float32(0), -1
+			for col := 0; col < m.cols; col++ {
+				if val := m.at(row, col); !used[col] && (maxcol < 0 || val > max) {
+					max, maxcol = val, col
+				}
+			}
+			used[maxcol] = true
+			v.set(row, i, float32(maxcol))
+		}
+	}
+	return v
+}
+
 // Norm method divides each element by the sum of the values in that row.
 func (m *native32) Norm(in Matrix) Matrix {
 	a := in.(*native32)
 	m.Reshape(a.rows, a.cols, false)
-	for row := 0; row < m.rows; row++ {
-		sum := float32(0)
-		for col := 0; col < m.cols; col++ {
-			sum += a.at(row, col)
-		}
-		for col := 0; col < m.cols; col++ {
-			m.set(row, col, a.at(row, col)/sum)
+	parallel(m.rows, rowGrain(m.cols), func(_, r1, r2 int) {
+		for row := r1; row < r2; row++ {
+			sum := float32(0)
+			for col := 0; col < m.cols; col++ {
+				sum += a.at(row, col)
+			}
+			for col := 0; col < m.cols; col++ {
+				m.set(row, col, a.at(row, col)/sum)
+			}
 		}
-	}
+	})
 	return m
 }
 
@@ -359,22 +449,316 @@ func (m *native32) Histogram(in Matrix, bins int, min, max float32) Matrix {
 func (m *native32) apply(in Matrix, fn Unary32) {
 	a := in.(*native32)
 	m.Reshape(a.rows, a.cols, false)
-	for row := 0; row < m.rows; row++ {
-		for col := 0; col < m.cols; col++ {
-			val := a.at(row, col)
-			m.set(row, col, fn(val))
+	parallel(m.rows, rowGrain(m.cols), func(_, r1, r2 int) {
+		for row := r1; row < r2; row++ {
+			for col := 0; col < m.cols; col++ {
+				val := a.at(row, col)
+				m.set(row, col, fn(val))
+			}
 		}
-	}
+	})
 }
 
 func (m *native32) apply2(m1, m2 Matrix, fn Binary32) {
 	checkEqualSize("binary32:Apply", m1, m2, m)
 	a, b := m1.(*native32), m2.(*native32)
-	for row := 0; row < m.rows; row++ {
-		for col := 0; col < m.cols; col++ {
-			v1 := a.at(row, col)
-			v2 := b.at(row, col)
-			m.set(row, col, fn(v1, v2))
+	parallel(m.rows, rowGrain(m.cols), func(_, r1, r2 int) {
+		for row := r1; row < r2; row++ {
+			for col := 0; col < m.cols; col++ {
+				v1 := a.at(row, col)
+				v2 := b.at(row, col)
+				m.set(row, col, fn(v1, v2))
+			}
 		}
-	}
+	})
+}
+
+// Conv method performs a 2D convolution of each input image with the filters in the weight matrix.
+// Input is [samples, InSize], weights are [Filters, KernelSize+1] and output is [samples, OutSize].
+func (m *native32) Conv(input, weights Matrix, d ConvDims) Matrix {
+	checkConv("blas:Conv", input, d.InSize())
+	a, w := input.(*native32), weights.(*native32)
+	m.Reshape(a.rows, d.OutSize(), false)
+	ow, oh, kkc := d.OutWidth(), d.OutHeight(), d.KernelSize()
+	parallel(a.rows, rowGrain(m.cols*kkc), func(_, r1, r2 int) {
+		for n := r1; n < r2; n++ {
+			for oy := 0; oy < oh; oy++ {
+				for ox := 0; ox < ow; ox++ {
+					for f := 0; f < d.Filters; f++ {
+						sum := w.at(f, kkc)
+						for ky := 0; ky < d.Kernel; ky++ {
+							iy := oy*d.Stride - d.Pad + ky
+							if iy < 0 || iy >= d.Height {
+								continue
+							}
+							for kx := 0; kx < d.Kernel; kx++ {
+								ix := ox*d.Stride - d.Pad + kx
+								if ix < 0 || ix >= d.Width {
+									continue
+								}
+								ipos, wpos := (iy*d.Width+ix)*d.Channels, (ky*d.Kernel+kx)*d.Channels
+								for c := 0; c < d.Channels; c++ {
+									sum += a.at(n, ipos+c) * w.at(f, wpos+c)
+								}
+							}
+						}
+						m.set(n, (oy*ow+ox)*d.Filters+f, sum)
+					}
+				}
+			}
+		}
+	})
+	return m
+}
+
+// ConvGradInput method back propagates the gradient through a convolution.
+// Delta is [samples, OutSize], weights are [Filters, KernelSize+1] and output is [samples, InSize].
+func (m *native32) ConvGradInput(delta, weights Matrix, d ConvDims) Matrix {
+	checkConv("blas:ConvGradInput", delta, d.OutSize())
+	g, w := delta.(*native32), weights.(*native32)
+	m.Reshape(g.rows, d.InSize(), false)
+	ow, oh := d.OutWidth(), d.OutHeight()
+	parallel(g.rows, rowGrain(m.cols*d.Kernel*d.Kernel*d.Filters), func(_, r1, r2 int) {
+		for n := r1; n < r2; n++ {
+			for iy := 0; iy < d.Height; iy++ {
+				for ix := 0; ix < d.Width; ix++ {
+					for c := 0; c < d.Channels; c++ {
+						var sum float32
+						for ky := 0; ky < d.Kernel; ky++ {
+							oy := iy + d.Pad - ky
+							if oy < 0 || oy%d.Stride != 0 || oy/d.Stride >= oh {
+								continue
+							}
+							oy /= d.Stride
+							for kx := 0; kx < d.Kernel; kx++ {
+								ox := ix + d.Pad - kx
+								if ox < 0 || ox%d.Stride != 0 || ox/d.Stride >= ow {
+									continue
+								}
+								ox /= d.Stride
+								opos, wpos := (oy*ow+ox)*d.Filters, (ky*d.Kernel+kx)*d.Channels+c
+								for f := 0; f < d.Filters; f++ {
+									sum += g.at(n, opos+f) * w.at(f, wpos)
+								}
+							}
+						}
+						m.set(n, (iy*d.Width+ix)*d.Channels+c, sum)
+					}
+				}
+			}
+		}
+	})
+	return m
+}
+
+// ConvGradWeights method calculates the gradient of the convolution weights summed over all samples.
+// Delta is [samples, OutSize], input is [samples, InSize] and output is [Filters, KernelSize+1].
+func (m *native32) ConvGradWeights(delta, input Matrix, d ConvDims) Matrix {
+	checkConv("blas:ConvGradWeights", delta, d.OutSize())
+	checkConv("blas:ConvGradWeights", input, d.InSize())
+	g, a := delta.(*native32), input.(*native32)
+	kkc := d.KernelSize()
+	m.Reshape(d.Filters, kkc+1, false)
+	ow, oh := d.OutWidth(), d.OutHeight()
+	parallel(d.Filters, 1, func(_, f1, f2 int) {
+		for f := f1; f < f2; f++ {
+			for col := 0; col <= kkc; col++ {
+				m.set(f, col, 0)
+			}
+			for n := 0; n < g.rows; n++ {
+				for oy := 0; oy < oh; oy++ {
+					for ox := 0; ox < ow; ox++ {
+						gval := g.at(n, (oy*ow+ox)*d.Filters+f)
+						m.set(f, kkc, m.at(f, kkc)+gval)
+						for ky := 0; ky < d.Kernel; ky++ {
+							iy := oy*d.Stride - d.Pad + ky
+							if iy < 0 || iy >= d.Height {
+								continue
+							}
+							for kx := 0; kx < d.Kernel; kx++ {
+								ix := ox*d.Stride - d.Pad + kx
+								if ix < 0 || ix >= d.Width {
+									continue
+								}
+								ipos, wpos := (iy*d.Width+ix)*d.Channels, (ky*d.Kernel+kx)*d.Channels
+								for c := 0; c < d.Channels; c++ {
+									m.set(f, wpos+c, m.at(f, wpos+c)+gval*a.at(n, ipos+c))
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	})
+	return m
+}
+
+// Im2Col method expands each kernel sized patch of the input images into a row of the output matrix.
+// Input is [samples, InSize] and output is [samples*OutWidth*OutHeight, KernelSize]. Padding is filled with zeros.
+func (m *native32) Im2Col(input Matrix, d ConvDims) Matrix {
+	checkConv("blas:Im2Col", input, d.InSize())
+	a := input.(*native32)
+	ow, oh := d.OutWidth(), d.OutHeight()
+	m.Reshape(a.rows*ow*oh, d.KernelSize(), false)
+	parallel(m.rows, rowGrain(m.cols), func(_, r1, r2 int) {
+		for row := r1; row < r2; row++ {
+			n, pos := row/(ow*oh), row%(ow*oh)
+			oy, ox := pos/ow, pos%ow
+			for ky := 0; ky < d.Kernel; ky++ {
+				iy := oy*d.Stride - d.Pad + ky
+				for kx := 0; kx < d.Kernel; kx++ {
+					ix := ox*d.Stride - d.Pad + kx
+					inside := iy >= 0 && iy < d.Height && ix >= 0 && ix < d.Width
+					for c := 0; c < d.Channels; c++ {
+						col := (ky*d.Kernel+kx)*d.Channels + c
+						if inside {
+							m.set(row, col, a.at(n, (iy*d.Width+ix)*d.Channels+c))
+						} else {
+							m.set(row, col, 0)
+						}
+					}
+				}
+			}
+		}
+	})
+	return m
+}
+
+// Col2Im method is the inverse of Im2Col: values from overlapping patches are summed.
+// Input is [samples*OutWidth*OutHeight, KernelSize] and output is [samples, InSize].
+func (m *native32) Col2Im(input Matrix, d ConvDims) Matrix {
+	checkConv("blas:Col2Im", input, d.KernelSize())
+	a := input.(*native32)
+	ow, oh := d.OutWidth(), d.OutHeight()
+	m.Reshape(a.rows/(ow*oh), d.InSize(), false)
+	parallel(m.rows, rowGrain(m.cols*d.Kernel*d.Kernel), func(_, r1, r2 int) {
+		for n := r1; n < r2; n++ {
+			for iy := 0; iy < d.Height; iy++ {
+				for ix := 0; ix < d.Width; ix++ {
+					for c := 0; c < d.Channels; c++ {
+						var sum float32
+						for ky := 0; ky < d.Kernel; ky++ {
+							oy := iy + d.Pad - ky
+							if oy < 0 || oy%d.Stride != 0 || oy/d.Stride >= oh {
+								continue
+							}
+							oy /= d.Stride
+							for kx := 0; kx < d.Kernel; kx++ {
+								ox := ix + d.Pad - kx
+								if ox < 0 || ox%d.Stride != 0 || ox/d.Stride >= ow {
+									continue
+								}
+								ox /= d.Stride
+								sum += a.at(n*ow*oh+oy*ow+ox, (ky*d.Kernel+kx)*d.Channels+c)
+							}
+						}
+						m.set(n, (iy*d.Width+ix)*d.Channels+c, sum)
+					}
+				}
+			}
+		}
+	})
+	return m
+}
+
+// MaxPool method downsamples each input image by taking the maximum value in each window.
+// Input is [samples, InSize] and output is [samples, OutSize]. The index matrix is set to the input column of each max value.
+func (m *native32) MaxPool(input, index Matrix, d PoolDims) Matrix {
+	checkConv("blas:MaxPool", input, d.InSize())
+	a, ix := input.(*native32), index.(*native32)
+	m.Reshape(a.rows, d.OutSize(), false)
+	ix.Reshape(a.rows, d.OutSize(), false)
+	ow := d.OutWidth()
+	parallel(a.rows, rowGrain(m.cols*d.Size*d.Size), func(_, r1, r2 int) {
+		for n := r1; n < r2; n++ {
+			for col := 0; col < m.cols; col++ {
+				c, ox, oy := col%d.Channels, (col/d.Channels)%ow, (col/d.Channels)/ow
+				imax := ((oy*d.Stride)*d.Width+ox*d.Stride)*d.Channels + c
+				vmax := a.at(n, imax)
+				for ky := 0; ky < d.Size; ky++ {
+					for kx := 0; kx < d.Size; kx++ {
+						ipos := ((oy*d.Stride+ky)*d.Width+ox*d.Stride+kx)*d.Channels + c
+						if val := a.at(n, ipos); val > vmax {
+							vmax, imax = val, ipos
+						}
+					}
+				}
+				m.set(n, col, vmax)
+				ix.set(n, col, float32(imax))
+			}
+		}
+	})
+	return m
+}
+
+// MaxPoolGrad method back propagates the gradient through a max pooling operation using the index from MaxPool.
+// Delta and index are [samples, OutSize] and output is [samples, InSize].
+func (m *native32) MaxPoolGrad(delta, index Matrix, d PoolDims) Matrix {
+	checkConv("blas:MaxPoolGrad", delta, d.OutSize())
+	g, ix := delta.(*native32), index.(*native32)
+	m.Reshape(g.rows, d.InSize(), false)
+	parallel(g.rows, rowGrain(m.cols), func(_, r1, r2 int) {
+		for n := r1; n < r2; n++ {
+			for col := 0; col < m.cols; col++ {
+				m.set(n, col, 0)
+			}
+			for col := 0; col < g.cols; col++ {
+				ipos := int(ix.at(n, col))
+				m.set(n, ipos, m.at(n, ipos)+g.at(n, col))
+			}
+		}
+	})
+	return m
+}
+
+// AvgPool method downsamples each input image by taking the mean value in each window.
+// Input is [samples, InSize] and output is [samples, OutSize].
+func (m *native32) AvgPool(input Matrix, d PoolDims) Matrix {
+	checkConv("blas:AvgPool", input, d.InSize())
+	a := input.(*native32)
+	m.Reshape(a.rows, d.OutSize(), false)
+	ow, scale := d.OutWidth(), 1/float32(d.Size*d.Size)
+	parallel(a.rows, rowGrain(m.cols*d.Size*d.Size), func(_, r1, r2 int) {
+		for n := r1; n < r2; n++ {
+			for col := 0; col < m.cols; col++ {
+				c, ox, oy := col%d.Channels, (col/d.Channels)%ow, (col/d.Channels)/ow
+				var sum float32
+				for ky := 0; ky < d.Size; ky++ {
+					for kx := 0; kx < d.Size; kx++ {
+						sum += a.at(n, ((oy*d.Stride+ky)*d.Width+ox*d.Stride+kx)*d.Channels+c)
+					}
+				}
+				m.set(n, col, sum*scale)
+			}
+		}
+	})
+	return m
+}
+
+// AvgPoolGrad method back propagates the gradient through an average pooling operation.
+// Delta is [samples, OutSize] and output is [samples, InSize].
+func (m *native32) AvgPoolGrad(delta Matrix, d PoolDims) Matrix {
+	checkConv("blas:AvgPoolGrad", delta, d.OutSize())
+	g := delta.(*native32)
+	m.Reshape(g.rows, d.InSize(), false)
+	ow, scale := d.OutWidth(), 1/float32(d.Size*d.Size)
+	parallel(g.rows, rowGrain(g.cols*d.Size*d.Size), func(_, r1, r2 int) {
+		for n := r1; n < r2; n++ {
+			for col := 0; col < m.cols; col++ {
+				m.set(n, col, 0)
+			}
+			for col := 0; col < g.cols; col++ {
+				c, ox, oy := col%d.Channels, (col/d.Channels)%ow, (col/d.Channels)/ow
+				val := g.at(n, col) * scale
+				for ky := 0; ky < d.Size; ky++ {
+					for kx := 0; kx < d.Size; kx++ {
+						ipos := ((oy*d.Stride+ky)*d.Width+ox*d.Stride+kx)*d.Channels + c
+						m.set(n, ipos, m.at(n, ipos)+val)
+					}
+				}
+			}
+		}
+	})
+	return m
 }
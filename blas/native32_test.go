@@ -0,0 +1,128 @@
+package blas
+
+import (
+	"math/rand"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// reference matrix multiply with double precision accumulator
+func mulRef(a, b []float32, ar, ac, bc int) []float32 {
+	res := make([]float32, ar*bc)
+	for i := 0; i < ar; i++ {
+		for j := 0; j < bc; j++ {
+			sum := 0.0
+			for k := 0; k < ac; k++ {
+				sum += float64(a[i*ac+k]) * float64(b[k*bc+j])
+			}
+			res[i*bc+j] = float32(sum)
+		}
+	}
+	return res
+}
+
+func TestParallelMul(t *testing.T) {
+	defer SetThreads(SetThreads(4))
+	rand.Seed(1)
+	ar, ac, bc := 130, 170, 90
+	adata, bdata := rand32(ar*ac), rand32(ac*bc)
+	a := newnative32(ar, ac).Load(RowMajor, adata...)
+	b := newnative32(ac, bc).Load(RowMajor, bdata...)
+	at := newnative32(ac, ar).Transpose(a)
+	bt := newnative32(bc, ac).Transpose(b)
+	expect := mulRef(adata, bdata, ar, ac, bc)
+	var first []float32
+	for _, nthread := range []int{4, 1, 3} {
+		SetThreads(nthread)
+		for flags := 0; flags < 8; flags++ {
+			aTrans, bTrans, oTrans := flags&1 != 0, flags&2 != 0, flags&4 != 0
+			m1, m2 := a, b
+			if aTrans {
+				m1 = at
+			}
+			if bTrans {
+				m2 = bt
+			}
+			order := RowMajor
+			if oTrans {
+				order = ColMajor
+			}
+			m := newnative32(ar, bc).Mul(m1, m2, aTrans, bTrans, oTrans)
+			data := m.Data(order)
+			checkClose(t, data, expect, 1e-4)
+			if first == nil {
+				first = data
+			} else if !reflect.DeepEqual(data, first) {
+				t.Errorf("threads=%d flags=%d: result differs", nthread, flags)
+			}
+		}
+	}
+}
+
+func TestParallelSum(t *testing.T) {
+	defer SetThreads(SetThreads(4))
+	rand.Seed(1)
+	rows, cols := 500, 300
+	m := newnative32(rows, cols).Load(RowMajor, rand32(rows*cols)...)
+	sum := m.Sum()
+	for i := 0; i < 10; i++ {
+		if s := m.Sum(); s != sum {
+			t.Fatalf("sum is not deterministic: %g != %g", s, sum)
+		}
+	}
+	SetThreads(1)
+	t.Log("sum with 4 threads =", sum, "1 thread =", m.Sum())
+	if fabs(m.Sum()-sum) > 1e-3 {
+		t.Error("sum mismatch")
+	}
+	rows2 := newnative32(rows, 1).SumRows(m)
+	SetThreads(4)
+	if !reflect.DeepEqual(rows2.Data(RowMajor), newnative32(rows, 1).SumRows(m).Data(RowMajor)) {
+		t.Error("SumRows depends on number of threads")
+	}
+}
+
+func TestParallelSetThreads(t *testing.T) {
+	defer SetThreads(SetThreads(4))
+	rows, cols := 500, 300
+	m := newnative32(rows, cols).Load(RowMajor, rand32(rows*cols)...)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				m.Sum()
+			}
+		}()
+	}
+	for j := 0; j < 50; j++ {
+		SetThreads(1 + j%4)
+	}
+	wg.Wait()
+}
+
+func TestParallelNested(t *testing.T) {
+	defer SetThreads(SetThreads(2))
+	var count int32
+	done := make(chan bool)
+	go func() {
+		parallel(4, 1, func(_, r1, r2 int) {
+			parallel(4, 1, func(_, s1, s2 int) {
+				atomic.AddInt32(&count, int32((r2-r1)*(s2-s1)))
+			})
+		})
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("nested parallel call deadlocked")
+	}
+	if count != 16 {
+		t.Error("expecting 16 calls, got", count)
+	}
+}
@@ -0,0 +1,720 @@
+package blas
+
+import (
+	"math/rand"
+)
+
+// matrix of float64 stored internally in row major order.
+// The public interface uses float32 values, but all calculations are done at double precision.
+type native64 struct {
+	rows   int
+	cols   int
+	stride int
+	data   []float64
+	format string
+}
+
+// constructor
+func newnative64(rows, cols int) Matrix {
+	return &native64{
+		rows:   rows,
+		cols:   cols,
+		stride: cols,
+		data:   make([]float64, rows*cols),
+		format: "%8.4f",
+	}
+}
+
+func (m *native64) Release() {}
+
+// accessors
+func (m *native64) Rows() int { return m.rows }
+
+func (m *native64) Cols() int { return m.cols }
+
+func (m *native64) Size() int { return len(m.data) }
+
+func (m *native64) SetFormat(f string) { m.format = f }
+
+func (m *native64) String() string {
+	return format(m.format, m.rows, m.cols, m.Data(RowMajor))
+}
+
+func (m *native64) at(row, col int) float64 {
+	return m.data[row*m.stride+col]
+}
+
+func (m *native64) set(row, col int, val float64) {
+	m.data[row*m.stride+col] = val
+}
+
+// Load method initialises a matrix with data from a list of float32 values.
+// If the number of values is less than the size then they are repeated to fill the matrix.
+func (m *native64) Load(order Ordering, vals ...float32) Matrix {
+	if len(vals) == 0 {
+		panic("blas:Load - no data provided")
+	}
+	next := getNext(vals)
+	if order == RowMajor {
+		for row := 0; row < m.rows; row++ {
+			for col := 0; col < m.cols; col++ {
+				m.set(row, col, float64(next()))
+			}
+		}
+	} else {
+		for col := 0; col < m.cols; col++ {
+			for row := 0; row < m.rows; row++ {
+				m.set(row, col, float64(next()))
+			}
+		}
+	}
+	return m
+}
+
+// Data method returns a copy of the matrix data as a slice, rounded to single precision.
+func (m *native64) Data(order Ordering) []float32 {
+	data := make([]float32, m.rows*m.cols)
+	i := 0
+	if order == RowMajor {
+		for row := 0; row < m.rows; row++ {
+			for col := 0; col < m.cols; col++ {
+				data[i] = float32(m.at(row, col))
+				i++
+			}
+		}
+	} else {
+		for col := 0; col < m.cols; col++ {
+			for row := 0; row < m.rows; row++ {
+				data[i] = float32(m.at(row, col))
+				i++
+			}
+		}
+	}
+	return data
+}
+
+// Random method initialises a matrix with random values in range min to max.
+func (m *native64) Random(min, max float32) Matrix {
+	lo, hi := float64(min), float64(max)
+	for row := 0; row < m.rows; row++ {
+		for col := 0; col < m.cols; col++ {
+			m.set(row, col, lo+(hi-lo)*rand.Float64())
+		}
+	}
+	return m
+}
+
+// Copy method returns a copy of the input matrix
+// if ix matrix is non-nil then this provides a column vector with the rows to copy.
+func (m *native64) Copy(in, ix Matrix) Matrix {
+	a := in.(*native64)
+	if ix == nil {
+		m.Reshape(a.rows, a.cols, false)
+		for row := 0; row < m.rows; row++ {
+			for col := 0; col < m.cols; col++ {
+				m.set(row, col, a.at(row, col))
+			}
+		}
+	} else {
+		b := ix.(*native64)
+		m.Reshape(b.rows, a.cols, false)
+		for row := 0; row < b.rows; row++ {
+			ixrow := int(b.at(row, 0))
+			for col := 0; col < m.cols; col++ {
+				m.set(row, col, a.at(ixrow, col))
+			}
+		}
+	}
+	return m
+}
+
+// AddRows method adds each row of the input matrix to the row of m given by the ix column vector.
+// This is the reverse of Copy with an index: rows with the same index are summed.
+func (m *native64) AddRows(in, ix Matrix) Matrix {
+	a, b := in.(*native64), ix.(*native64)
+	if a.cols != m.cols || b.rows != a.rows {
+		panic("blas:AddRows - mismatch in no. of rows and columns in input matrices")
+	}
+	for row := 0; row < a.rows; row++ {
+		ixrow := int(b.at(row, 0))
+		if ixrow < 0 || ixrow >= m.rows {
+			panic("blas:AddRows - index out of range")
+		}
+		for col := 0; col < m.cols; col++ {
+			m.set(ixrow, col, m.at(ixrow, col)+a.at(row, col))
+		}
+	}
+	return m
+}
+
+// Transpose method returns a transposed copy of the input matrix
+func (m *native64) Transpose(in Matrix) Matrix {
+	a := in.(*native64)
+	m.Reshape(a.cols, a.rows, true)
+	for row := 0; row < m.rows; row++ {
+		for col := 0; col < m.cols; col++ {
+			m.set(row, col, a.at(col, row))
+		}
+	}
+	return m
+}
+
+// Reshape method changes the dimensions without altering the data
+func (m *native64) Reshape(rows, cols int, shrink bool) Matrix {
+	if m.Size() < rows*cols {
+		panic("blas:Reshape - matrix is too small")
+	}
+	m.rows, m.cols = rows, cols
+	if shrink || m.stride < m.cols {
+		m.stride = m.cols
+	}
+	return m
+}
+
+// Row method returns a view on the matrix with rows [r1:r2] exclusive.
+func (m *native64) Row(r1, r2 int) Matrix {
+	return &native64{
+		rows:   r2 - r1,
+		cols:   m.cols,
+		stride: m.stride,
+		data:   m.data[r1*m.stride:],
+		format: m.format,
+	}
+}
+
+// Col method returns a view on the matrix with columns [c1:c2] exclusive.
+func (m *native64) Col(c1, c2 int) Matrix {
+	return &native64{
+		rows:   m.rows,
+		cols:   c2 - c1,
+		stride: m.stride,
+		data:   m.data[c1:],
+		format: m.format,
+	}
+}
+
+// Set method sets all elements of the matrix to the given value
+func (m *native64) Set(val float32) Matrix {
+	for row := 0; row < m.rows; row++ {
+		for col := 0; col < m.cols; col++ {
+			m.set(row, col, float64(val))
+		}
+	}
+	return m
+}
+
+// Scale method muliplies each element of the matrix by a scalar.
+func (m *native64) Scale(s float32) Matrix {
+	sc := float64(s)
+	for row := 0; row < m.rows; row++ {
+		for col := 0; col < m.cols; col++ {
+			m.set(row, col, m.at(row, col)*sc)
+		}
+	}
+	return m
+}
+
+// Add method evaluates a + sc * b and puts the result in m.
+func (m *native64) Add(m1, m2 Matrix, s float32) Matrix {
+	checkEqualSize("blas:Add", m1, m2, m)
+	a, b := m1.(*native64), m2.(*native64)
+	sc := float64(s)
+	for row := 0; row < m.rows; row++ {
+		for col := 0; col < m.cols; col++ {
+			m.set(row, col, a.at(row, col)+sc*b.at(row, col))
+		}
+	}
+	return m
+}
+
+// Cmp method compares a and b and returns a matrix with 0 where they are equal else 1.
+func (m *native64) Cmp(m1, m2 Matrix, epsilon float32) Matrix {
+	checkEqualSize("blas:Cmp", m1, m2, m)
+	a, b := m1.(*native64), m2.(*native64)
+	eps := float64(epsilon)
+	for row := 0; row < m.rows; row++ {
+		for col := 0; col < m.cols; col++ {
+			ax, bx := a.at(row, col), b.at(row, col)
+			if ax < bx-eps || ax > bx+eps {
+				m.set(row, col, 1)
+			} else {
+				m.set(row, col, 0)
+			}
+		}
+	}
+	return m
+}
+
+// MulElem method performs element wise multiplication of the two input matrices and puts the output in m.
+func (m *native64) MulElem(m1, m2 Matrix) Matrix {
+	checkEqualSize("blas:Cmp", m1, m2, m)
+	a, b := m1.(*native64), m2.(*native64)
+	for row := 0; row < a.rows; row++ {
+		for col := 0; col < a.cols; col++ {
+			m.set(row, col, a.at(row, col)*b.at(row, col))
+		}
+	}
+	return m
+}
+
+// Mul method multiplies two matrices using regular matrix multiplication and puts the output in m.
+func (m *native64) Mul(m1, m2 Matrix, aTrans, bTrans, oTrans bool) Matrix {
+	a, b := m1.(*native64), m2.(*native64)
+	ar, ac, br, bc := a.rows, a.cols, b.rows, b.cols
+	aget := func(r, c int) float64 { return a.at(r, c) }
+	bget := func(r, c int) float64 { return b.at(r, c) }
+
+	if aTrans {
+		ar, ac = ac, ar
+		aget = func(r, c int) float64 { return a.at(c, r) }
+	}
+	if bTrans {
+		br, bc = bc, br
+		bget = func(r, c int) float64 { return b.at(c, r) }
+	}
+	if ac != br {
+		panic("blas:Mul - mismatch in no. of rows and columns in input matrices")
+	}
+	if oTrans {
+		m.Reshape(bc, ar, true)
+		for row := 0; row < ar; row++ {
+			for col := 0; col < bc; col++ {
+				sum := 0.0
+				for k := 0; k < ac; k++ {
+					sum += aget(row, k) * bget(k, col)
+				}
+				m.set(col, row, sum)
+			}
+		}
+	} else {
+		m.Reshape(ar, bc, true)
+		for row := 0; row < ar; row++ {
+			for col := 0; col < bc; col++ {
+				sum := 0.0
+				for k := 0; k < ac; k++ {
+					sum += aget(row, k) * bget(k, col)
+				}
+				m.set(row, col, sum)
+			}
+		}
+	}
+	return m
+}
+
+// Sum method calculates the sum of the values in the matrix
+func (m *native64) Sum() float32 {
+	var sum float64
+	for row := 0; row < m.rows; row++ {
+		for col := 0; col < m.cols; col++ {
+			sum += m.at(row, col)
+		}
+	}
+	return float32(sum)
+}
+
+// SumRows method returns a column vector with the sum of each row.
+func (m *native64) SumRows(in Matrix) Matrix {
+	a := in.(*native64)
+	m.Reshape(a.rows, 1, false)
+	for row := 0; row < a.rows; row++ {
+		sum := 0.0
+		for col := 0; col < a.cols; col++ {
+			sum += a.at(row, col)
+		}
+		m.set(row, 0, sum)
+	}
+	return m
+}
+
+// MaxCol method gets the column number with the maximim value for each row of the input matrix.
+func (v *native64) MaxCol(in Matrix) Matrix {
+	m := in.(*native64)
+	if v.Size() < m.rows {
+		panic("blas:MaxCol - output matrix is too small")
+	}
+	v.rows, v.cols = m.rows, 1
+	for row := 0; row < m.rows; row++ {
+		max, maxcol := -1e308, 0
+		for col := 0; col < m.cols; col++ {
+			if val := m.at(row, col); val > max {
+				max, maxcol = val, col
+			}
+		}
+		v.data[row] = float64(maxcol)
+	}
+	return v
+}
+
+// TopK method gets the column numbers of the k largest values in each row of the input matrix in
+// descending order of value. Where values are equal the lower column number comes first.
+func (v *native64) TopK(in Matrix, k int) Matrix {
+	m := in.(*native64)
+	if k < 1 || k > m.cols {
+		panic("blas:TopK - k is out of range")
+	}
+	v.Reshape(m.rows, k, false)
+	used := make([]bool, m.cols)
+	for row := 0; row < m.rows; row++ {
+		for col := range used {
+			used[col] = false
+		}
+		for i := 0; i < k; i++ {
+			max, maxcol := 0.0, -1
+			for col := 0; col < m.cols; col++ {
+				if val := m.at(row, col); !used[col] && (maxcol < 0 || val > max) {
+					max, maxcol = val, col
+				}
+			}
+			used[maxcol] = true
+			v.set(row, i, float64(maxcol))
+		}
+	}
+	return v
+}
+
+// Norm method divides each element by the sum of the values in that row.
+func (m *native64) Norm(in Matrix) Matrix {
+	a := in.(*native64)
+	m.Reshape(a.rows, a.cols, false)
+	for row := 0; row < m.rows; row++ {
+		sum := 0.0
+		for col := 0; col < m.cols; col++ {
+			sum += a.at(row, col)
+		}
+		for col := 0; col < m.cols; col++ {
+			m.set(row, col, a.at(row, col)/sum)
+		}
+	}
+	return m
+}
+
+// Histogram method adds bins the values from the input column vector and adds to the histogram.
+func (m *native64) Histogram(in Matrix, bins int, min, max float32) Matrix {
+	a := in.(*native64)
+	m.Reshape(bins, 1, false)
+	scale := float64(bins) / float64(max-min)
+	for row := 0; row < a.rows; row++ {
+		bin := int(scale * (a.at(row, 0) - float64(min)))
+		if bin < 0 {
+			bin = 0
+		}
+		if bin >= bins {
+			bin = bins - 1
+		}
+		m.data[bin*m.stride]++
+	}
+	return m
+}
+
+func (m *native64) apply(in Matrix, fn Unary64) {
+	a := in.(*native64)
+	m.Reshape(a.rows, a.cols, false)
+	for row := 0; row < m.rows; row++ {
+		for col := 0; col < m.cols; col++ {
+			val := a.at(row, col)
+			m.set(row, col, fn(val))
+		}
+	}
+}
+
+func (m *native64) apply2(m1, m2 Matrix, fn Binary64) {
+	checkEqualSize("binary64:Apply", m1, m2, m)
+	a, b := m1.(*native64), m2.(*native64)
+	for row := 0; row < m.rows; row++ {
+		for col := 0; col < m.cols; col++ {
+			v1 := a.at(row, col)
+			v2 := b.at(row, col)
+			m.set(row, col, fn(v1, v2))
+		}
+	}
+}
+
+// Conv method performs a 2D convolution of each input image with the filters in the weight matrix.
+// Input is [samples, InSize], weights are [Filters, KernelSize+1] and output is [samples, OutSize].
+func (m *native64) Conv(input, weights Matrix, d ConvDims) Matrix {
+	checkConv("blas:Conv", input, d.InSize())
+	a, w := input.(*native64), weights.(*native64)
+	m.Reshape(a.rows, d.OutSize(), false)
+	ow, oh, kkc := d.OutWidth(), d.OutHeight(), d.KernelSize()
+	parallel(a.rows, rowGrain(m.cols*kkc), func(_, r1, r2 int) {
+		for n := r1; n < r2; n++ {
+			for oy := 0; oy < oh; oy++ {
+				for ox := 0; ox < ow; ox++ {
+					for f := 0; f < d.Filters; f++ {
+						sum := w.at(f, kkc)
+						for ky := 0; ky < d.Kernel; ky++ {
+							iy := oy*d.Stride - d.Pad + ky
+							if iy < 0 || iy >= d.Height {
+								continue
+							}
+							for kx := 0; kx < d.Kernel; kx++ {
+								ix := ox*d.Stride - d.Pad + kx
+								if ix < 0 || ix >= d.Width {
+									continue
+								}
+								ipos, wpos := (iy*d.Width+ix)*d.Channels, (ky*d.Kernel+kx)*d.Channels
+								for c := 0; c < d.Channels; c++ {
+									sum += a.at(n, ipos+c) * w.at(f, wpos+c)
+								}
+							}
+						}
+						m.set(n, (oy*ow+ox)*d.Filters+f, sum)
+					}
+				}
+			}
+		}
+	})
+	return m
+}
+
+// ConvGradInput method back propagates the gradient through a convolution.
+// Delta is [samples, OutSize], weights are [Filters, KernelSize+1] and output is [samples, InSize].
+func (m *native64) ConvGradInput(delta, weights Matrix, d ConvDims) Matrix {
+	checkConv("blas:ConvGradInput", delta, d.OutSize())
+	g, w := delta.(*native64), weights.(*native64)
+	m.Reshape(g.rows, d.InSize(), false)
+	ow, oh := d.OutWidth(), d.OutHeight()
+	parallel(g.rows, rowGrain(m.cols*d.Kernel*d.Kernel*d.Filters), func(_, r1, r2 int) {
+		for n := r1; n < r2; n++ {
+			for iy := 0; iy < d.Height; iy++ {
+				for ix := 0; ix < d.Width; ix++ {
+					for c := 0; c < d.Channels; c++ {
+						var sum float64
+						for ky := 0; ky < d.Kernel; ky++ {
+							oy := iy + d.Pad - ky
+							if oy < 0 || oy%d.Stride != 0 || oy/d.Stride >= oh {
+								continue
+							}
+							oy /= d.Stride
+							for kx := 0; kx < d.Kernel; kx++ {
+								ox := ix + d.Pad - kx
+								if ox < 0 || ox%d.Stride != 0 || ox/d.Stride >= ow {
+									continue
+								}
+								ox /= d.Stride
+								opos, wpos := (oy*ow+ox)*d.Filters, (ky*d.Kernel+kx)*d.Channels+c
+								for f := 0; f < d.Filters; f++ {
+									sum += g.at(n, opos+f) * w.at(f, wpos)
+								}
+							}
+						}
+						m.set(n, (iy*d.Width+ix)*d.Channels+c, sum)
+					}
+				}
+			}
+		}
+	})
+	return m
+}
+
+// ConvGradWeights method calculates the gradient of the convolution weights summed over all samples.
+// Delta is [samples, OutSize], input is [samples, InSize] and output is [Filters, KernelSize+1].
+func (m *native64) ConvGradWeights(delta, input Matrix, d ConvDims) Matrix {
+	checkConv("blas:ConvGradWeights", delta, d.OutSize())
+	checkConv("blas:ConvGradWeights", input, d.InSize())
+	g, a := delta.(*native64), input.(*native64)
+	kkc := d.KernelSize()
+	m.Reshape(d.Filters, kkc+1, false)
+	ow, oh := d.OutWidth(), d.OutHeight()
+	parallel(d.Filters, 1, func(_, f1, f2 int) {
+		for f := f1; f < f2; f++ {
+			for col := 0; col <= kkc; col++ {
+				m.set(f, col, 0)
+			}
+			for n := 0; n < g.rows; n++ {
+				for oy := 0; oy < oh; oy++ {
+					for ox := 0; ox < ow; ox++ {
+						gval := g.at(n, (oy*ow+ox)*d.Filters+f)
+						m.set(f, kkc, m.at(f, kkc)+gval)
+						for ky := 0; ky < d.Kernel; ky++ {
+							iy := oy*d.Stride - d.Pad + ky
+							if iy < 0 || iy >= d.Height {
+								continue
+							}
+							for kx := 0; kx < d.Kernel; kx++ {
+								ix := ox*d.Stride - d.Pad + kx
+								if ix < 0 || ix >= d.Width {
+									continue
+								}
+								ipos, wpos := (iy*d.Width+ix)*d.Channels, (ky*d.Kernel+kx)*d.Channels
+								for c := 0; c < d.Channels; c++ {
+									m.set(f, wpos+c, m.at(f, wpos+c)+gval*a.at(n, ipos+c))
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	})
+	return m
+}
+
+// Im2Col method expands each kernel sized patch of the input images into a row of the output matrix.
+// Input is [samples, InSize] and output is [samples*OutWidth*OutHeight, KernelSize]. Padding is filled with zeros.
+func (m *native64) Im2Col(input Matrix, d ConvDims) Matrix {
+	checkConv("blas:Im2Col", input, d.InSize())
+	a := input.(*native64)
+	ow, oh := d.OutWidth(), d.OutHeight()
+	m.Reshape(a.rows*ow*oh, d.KernelSize(), false)
+	parallel(m.rows, rowGrain(m.cols), func(_, r1, r2 int) {
+		for row := r1; row < r2; row++ {
+			n, pos := row/(ow*oh), row%(ow*oh)
+			oy, ox := pos/ow, pos%ow
+			for ky := 0; ky < d.Kernel; ky++ {
+				iy := oy*d.Stride - d.Pad + ky
+				for kx := 0; kx < d.Kernel; kx++ {
+					ix := ox*d.Stride - d.Pad + kx
+					inside := iy >= 0 && iy < d.Height && ix >= 0 && ix < d.Width
+					for c := 0; c < d.Channels; c++ {
+						col := (ky*d.Kernel+kx)*d.Channels + c
+						if inside {
+							m.set(row, col, a.at(n, (iy*d.Width+ix)*d.Channels+c))
+						} else {
+							m.set(row, col, 0)
+						}
+					}
+				}
+			}
+		}
+	})
+	return m
+}
+
+// Col2Im method is the inverse of Im2Col: values from overlapping patches are summed.
+// Input is [samples*OutWidth*OutHeight, KernelSize] and output is [samples, InSize].
+func (m *native64) Col2Im(input Matrix, d ConvDims) Matrix {
+	checkConv("blas:Col2Im", input, d.KernelSize())
+	a := input.(*native64)
+	ow, oh := d.OutWidth(), d.OutHeight()
+	m.Reshape(a.rows/(ow*oh), d.InSize(), false)
+	parallel(m.rows, rowGrain(m.cols*d.Kernel*d.Kernel), func(_, r1, r2 int) {
+		for n := r1; n < r2; n++ {
+			for iy := 0; iy < d.Height; iy++ {
+				for ix := 0; ix < d.Width; ix++ {
+					for c := 0; c < d.Channels; c++ {
+						var sum float64
+						for ky := 0; ky < d.Kernel; ky++ {
+							oy := iy + d.Pad - ky
+							if oy < 0 || oy%d.Stride != 0 || oy/d.Stride >= oh {
+								continue
+							}
+							oy /= d.Stride
+							for kx := 0; kx < d.Kernel; kx++ {
+								ox := ix + d.Pad - kx
+								if ox < 0 || ox%d.Stride != 0 || ox/d.Stride >= ow {
+									continue
+								}
+								ox /= d.Stride
+								sum += a.at(n*ow*oh+oy*ow+ox, (ky*d.Kernel+kx)*d.Channels+c)
+							}
+						}
+						m.set(n, (iy*d.Width+ix)*d.Channels+c, sum)
+					}
+				}
+			}
+		}
+	})
+	return m
+}
+
+// MaxPool method downsamples each input image by taking the maximum value in each window.
+// Input is [samples, InSize] and output is [samples, OutSize]. The index matrix is set to the input column of each max value.
+func (m *native64) MaxPool(input, index Matrix, d PoolDims) Matrix {
+	checkConv("blas:MaxPool", input, d.InSize())
+	a, ix := input.(*native64), index.(*native64)
+	m.Reshape(a.rows, d.OutSize(), false)
+	ix.Reshape(a.rows, d.OutSize(), false)
+	ow := d.OutWidth()
+	parallel(a.rows, rowGrain(m.cols*d.Size*d.Size), func(_, r1, r2 int) {
+		for n := r1; n < r2; n++ {
+			for col := 0; col < m.cols; col++ {
+				c, ox, oy := col%d.Channels, (col/d.Channels)%ow, (col/d.Channels)/ow
+				imax := ((oy*d.Stride)*d.Width+ox*d.Stride)*d.Channels + c
+				vmax := a.at(n, imax)
+				for ky := 0; ky < d.Size; ky++ {
+					for kx := 0; kx < d.Size; kx++ {
+						ipos := ((oy*d.Stride+ky)*d.Width+ox*d.Stride+kx)*d.Channels + c
+						if val := a.at(n, ipos); val > vmax {
+							vmax, imax = val, ipos
+						}
+					}
+				}
+				m.set(n, col, vmax)
+				ix.set(n, col, float64(imax))
+			}
+		}
+	})
+	return m
+}
+
+// MaxPoolGrad method back propagates the gradient through a max pooling operation using the index from MaxPool.
+// Delta and index are [samples, OutSize] and output is [samples, InSize].
+func (m *native64) MaxPoolGrad(delta, index Matrix, d PoolDims) Matrix {
+	checkConv("blas:MaxPoolGrad", delta, d.OutSize())
+	g, ix := delta.(*native64), index.(*native64)
+	m.Reshape(g.rows, d.InSize(), false)
+	parallel(g.rows, rowGrain(m.cols), func(_, r1, r2 int) {
+		for n := r1; n < r2; n++ {
+			for col := 0; col < m.cols; col++ {
+				m.set(n, col, 0)
+			}
+			for col := 0; col < g.cols; col++ {
+				ipos := int(ix.at(n, col))
+				m.set(n, ipos, m.at(n, ipos)+g.at(n, col))
+			}
+		}
+	})
+	return m
+}
+
+// AvgPool method downsamples each input image by taking the mean value in each window.
+// Input is [samples, InSize] and output is [samples, OutSize].
+func (m *native64) AvgPool(input Matrix, d PoolDims) Matrix {
+	checkConv("blas:AvgPool", input, d.InSize())
+	a := input.(*native64)
+	m.Reshape(a.rows, d.OutSize(), false)
+	ow, scale := d.OutWidth(), 1/float64(d.Size*d.Size)
+	parallel(a.rows, rowGrain(m.cols*d.Size*d.Size), func(_, r1, r2 int) {
+		for n := r1; n < r2; n++ {
+			for col := 0; col < m.cols; col++ {
+				c, ox, oy := col%d.Channels, (col/d.Channels)%ow, (col/d.Channels)/ow
+				var sum float64
+				for ky := 0; ky < d.Size; ky++ {
+					for kx := 0; kx < d.Size; kx++ {
+						sum += a.at(n, ((oy*d.Stride+ky)*d.Width+ox*d.Stride+kx)*d.Channels+c)
+					}
+				}
+				m.set(n, col, sum*scale)
+			}
+		}
+	})
+	return m
+}
+
+// AvgPoolGrad method back propagates the gradient through an average pooling operation.
+// Delta is [samples, OutSize] and output is [samples, InSize].
+func (m *native64) AvgPoolGrad(delta Matrix, d PoolDims) Matrix {
+	checkConv("blas:AvgPoolGrad", delta, d.OutSize())
+	g := delta.(*native64)
+	m.Reshape(g.rows, d.InSize(), false)
+	ow, scale := d.OutWidth(), 1/float64(d.Size*d.Size)
+	parallel(g.rows, rowGrain(g.cols*d.Size*d.Size), func(_, r1, r2 int) {
+		for n := r1; n < r2; n++ {
+			for col := 0; col < m.cols; col++ {
+				m.set(n, col, 0)
+			}
+			for col := 0; col < g.cols; col++ {
+				c, ox, oy := col%d.Channels, (col/d.Channels)%ow, (col/d.Channels)/ow
+				val := g.at(n, col) * scale
+				for ky := 0; ky < d.Size; ky++ {
+					for kx := 0; kx < d.Size; kx++ {
+						ipos := ((oy*d.Stride+ky)*d.Width+ox*d.Stride+kx)*d.Channels + c
+						m.set(n, ipos, m.at(n, ipos)+val)
+					}
+				}
+			}
+		}
+	})
+	return m
+}
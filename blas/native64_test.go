@@ -0,0 +1,61 @@
+package blas
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestNative64Mul(t *testing.T) {
+	rand.Seed(1)
+	a32 := newnative32(40, 30).Load(RowMajor, randSlice(40*30)...)
+	b32 := newnative32(20, 30).Load(RowMajor, randSlice(20*30)...)
+	a64 := newnative64(40, 30).Load(RowMajor, a32.Data(RowMajor)...)
+	b64 := newnative64(20, 30).Load(RowMajor, b32.Data(RowMajor)...)
+	for _, trans := range []bool{false, true} {
+		m32 := newnative32(40, 20).Mul(a32, b32, false, true, trans)
+		m64 := newnative64(40, 20).Mul(a64, b64, false, true, trans)
+		if m64.Rows() != m32.Rows() || m64.Cols() != m32.Cols() {
+			t.Fatalf("size mismatch: %dx%d vs %dx%d", m64.Rows(), m64.Cols(), m32.Rows(), m32.Cols())
+		}
+		checkClose(t, m64.Data(RowMajor), m32.Data(RowMajor), 0)
+	}
+}
+
+func TestNative64Precision(t *testing.T) {
+	n := 1000000
+	m := newnative64(n, 1).Set(0.1)
+	sum := m.Sum()
+	t.Log("sum =", sum)
+	if math.Abs(float64(sum)-1e5) > 1e-3 {
+		t.Error("expected 100000, got", sum)
+	}
+	fn := Unary64(func(x float64) float64 { return x * 1e-10 })
+	fn.Apply(m, m)
+	m.Scale(1e10)
+	checkClose(t, m.Row(0, 4).Data(RowMajor), []float32{0.1, 0.1, 0.1, 0.1}, 0)
+}
+
+func TestNative64Image(t *testing.T) {
+	size, ksize := 9, 5
+	rand.Seed(1)
+	data := randSlice(2 * size * size)
+	kernel := newnative32(ksize, ksize).Load(RowMajor, GaussianKernel(ksize, ksize, 1.0).Data(RowMajor)...)
+	var out [2][]float32
+	for i, img := range []Image{newimage32(size, size, 2, 2), newimage64(size, size, 2, 2)} {
+		newm, filter := newnative32, Filter(filter32{size: ksize})
+		if i == 1 {
+			newm, filter = newnative64, filter64{filter32{size: ksize}}
+		}
+		in := newm(2, size*size).Load(RowMajor, data...)
+		img.Import(in, in)
+		dx, dy := newm(2, size*size), newm(2, size*size)
+		filter.Apply(img, newm(ksize, ksize).Load(RowMajor, kernel.Data(RowMajor)...), dx, dy)
+		img.Rotate(newm(1, 2).Load(RowMajor, 0.1, -0.2), dx, dy)
+		img.Scale(newm(1, 2).Load(RowMajor, 0.9, 1.1), newm(1, 2).Load(RowMajor, 1.2, 0.8), dx, dy)
+		res := newm(2, size*size)
+		img.Export(dx, dy, res)
+		out[i] = res.Data(RowMajor)
+	}
+	checkClose(t, out[1], out[0], 1e-6)
+}
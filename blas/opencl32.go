@@ -56,9 +56,12 @@ func initCL() {
 	opts := fmt.Sprintf("-D TRBLK=%d -D TS=%d -D MWC_A=%dU", trBlock, mulBlock, mwc_A)
 	var src string
 	for i := range sw {
-		if i >= mulKernel {
+		switch {
+		case i >= convKernel:
+			src = srcHead + convHead + source[i]
+		case i >= mulKernel:
 			src = srcHead + mulHead + source[i]
-		} else {
+		default:
 			src = srcHead + source[i]
 		}
 		if sw[i], err = scl.Compile(hw, src, name[i], opts); err != nil {
@@ -252,6 +255,22 @@ func (m *opencl32) Copy(in, ix Matrix) Matrix {
 	return m
 }
 
+// AddRows method adds each row of the input matrix to the row of m given by the ix column vector.
+// This is the reverse of Copy with an index: rows with the same index are summed. Each output element
+// is summed by a single work item so the result does not depend on the order of execution.
+func (m *opencl32) AddRows(in, ix Matrix) Matrix {
+	a, mix := in.(*opencl32), ix.(*opencl32)
+	if a.cols != m.cols || mix.rows != a.rows {
+		panic("blas:AddRows - mismatch in no. of rows and columns in input matrices")
+	}
+	k := sw[addRowsKernel]
+	setArgMatrix(k, 0, a)
+	setArgMatrix(k, 2, mix)
+	setArgMatrix(k, 4, m)
+	k.EnqueueKernel(hw, globalWG(m), nil)
+	return m
+}
+
 // Transpose method returns a transposed copy of the input matrix
 func (m *opencl32) Transpose(in Matrix) Matrix {
 	a := in.(*opencl32)
@@ -401,6 +420,21 @@ func (m *opencl32) MaxCol(in Matrix) Matrix {
 	return m
 }
 
+// TopK method gets the column numbers of the k largest values in each row of the input matrix in
+// descending order of value. Where values are equal the lower column number comes first.
+func (m *opencl32) TopK(in Matrix, k int) Matrix {
+	a := in.(*opencl32)
+	if k < 1 || k > int(a.cols) {
+		panic("blas:TopK - k is out of range")
+	}
+	m.reshape(a.rows, int32(k), false)
+	kern := sw[topKKernel]
+	setArgMatrix(kern, 0, a)
+	setArgMatrix(kern, 2, m)
+	kern.EnqueueKernel(hw, globalWG(a), nil)
+	return m
+}
+
 // Norm method divides each element by the sum of the values in that row.
 func (m *opencl32) Norm(in Matrix) Matrix {
 	a := in.(*opencl32)
@@ -429,6 +463,105 @@ func (m *opencl32) Histogram(in Matrix, bins int, min, max float32) Matrix {
 	return m
 }
 
+// Conv method performs a 2D convolution of each input image with the filters in the weight matrix.
+// Input is [samples, InSize], weights are [Filters, KernelSize+1] and output is [samples, OutSize].
+func (m *opencl32) Conv(input, weights Matrix, d ConvDims) Matrix {
+	checkConv("blas:Conv", input, d.InSize())
+	a, w := input.(*opencl32), weights.(*opencl32)
+	m.reshape(a.rows, int32(d.OutSize()), false)
+	return m.convKernel(convKernel, d, a, w)
+}
+
+// ConvGradInput method back propagates the gradient through a convolution.
+// Delta is [samples, OutSize], weights are [Filters, KernelSize+1] and output is [samples, InSize].
+func (m *opencl32) ConvGradInput(delta, weights Matrix, d ConvDims) Matrix {
+	checkConv("blas:ConvGradInput", delta, d.OutSize())
+	g, w := delta.(*opencl32), weights.(*opencl32)
+	m.reshape(g.rows, int32(d.InSize()), false)
+	return m.convKernel(convGradInputKernel, d, g, w)
+}
+
+// ConvGradWeights method calculates the gradient of the convolution weights summed over all samples.
+// Delta is [samples, OutSize], input is [samples, InSize] and output is [Filters, KernelSize+1].
+func (m *opencl32) ConvGradWeights(delta, input Matrix, d ConvDims) Matrix {
+	checkConv("blas:ConvGradWeights", delta, d.OutSize())
+	checkConv("blas:ConvGradWeights", input, d.InSize())
+	g, a := delta.(*opencl32), input.(*opencl32)
+	m.reshape(int32(d.Filters), int32(d.KernelSize()+1), false)
+	return m.convKernel(convGradWeightsKernel, d, g, a)
+}
+
+// Im2Col method expands each kernel sized patch of the input images into a row of the output matrix.
+// Input is [samples, InSize] and output is [samples*OutWidth*OutHeight, KernelSize]. Padding is filled with zeros.
+func (m *opencl32) Im2Col(input Matrix, d ConvDims) Matrix {
+	checkConv("blas:Im2Col", input, d.InSize())
+	a := input.(*opencl32)
+	m.reshape(a.rows*int32(d.OutWidth()*d.OutHeight()), int32(d.KernelSize()), false)
+	return m.convKernel(im2colKernel, d, a, nil)
+}
+
+// Col2Im method is the inverse of Im2Col: values from overlapping patches are summed.
+// Input is [samples*OutWidth*OutHeight, KernelSize] and output is [samples, InSize].
+func (m *opencl32) Col2Im(input Matrix, d ConvDims) Matrix {
+	checkConv("blas:Col2Im", input, d.KernelSize())
+	a := input.(*opencl32)
+	m.reshape(a.rows/int32(d.OutWidth()*d.OutHeight()), int32(d.InSize()), false)
+	return m.convKernel(col2imKernel, d, a, nil)
+}
+
+// MaxPool method downsamples each input image by taking the maximum value in each window.
+// Input is [samples, InSize] and output is [samples, OutSize]. The index matrix is set to the input column of each max value.
+func (m *opencl32) MaxPool(input, index Matrix, d PoolDims) Matrix {
+	checkConv("blas:MaxPool", input, d.InSize())
+	a, ix := input.(*opencl32), index.(*opencl32)
+	m.reshape(a.rows, int32(d.OutSize()), false)
+	ix.reshape(a.rows, int32(d.OutSize()), false)
+	return m.convKernel(maxPoolKernel, d.conv(), a, ix)
+}
+
+// MaxPoolGrad method back propagates the gradient through a max pooling operation using the index from MaxPool.
+// Delta and index are [samples, OutSize] and output is [samples, InSize].
+func (m *opencl32) MaxPoolGrad(delta, index Matrix, d PoolDims) Matrix {
+	checkConv("blas:MaxPoolGrad", delta, d.OutSize())
+	g, ix := delta.(*opencl32), index.(*opencl32)
+	m.reshape(g.rows, int32(d.InSize()), false)
+	return m.convKernel(maxPoolGradKernel, d.conv(), g, ix)
+}
+
+// AvgPool method downsamples each input image by taking the mean value in each window.
+// Input is [samples, InSize] and output is [samples, OutSize].
+func (m *opencl32) AvgPool(input Matrix, d PoolDims) Matrix {
+	checkConv("blas:AvgPool", input, d.InSize())
+	a := input.(*opencl32)
+	m.reshape(a.rows, int32(d.OutSize()), false)
+	return m.convKernel(avgPoolKernel, d.conv(), a, nil)
+}
+
+// AvgPoolGrad method back propagates the gradient through an average pooling operation.
+// Delta is [samples, OutSize] and output is [samples, InSize].
+func (m *opencl32) AvgPoolGrad(delta Matrix, d PoolDims) Matrix {
+	checkConv("blas:AvgPoolGrad", delta, d.OutSize())
+	g := delta.(*opencl32)
+	m.reshape(g.rows, int32(d.InSize()), false)
+	return m.convKernel(avgPoolGradKernel, d.conv(), g, nil)
+}
+
+// run one of the convolution kernels with one work item per output value
+func (m *opencl32) convKernel(kernel int, d ConvDims, a, b *opencl32) Matrix {
+	args := d.args()
+	k := sw[kernel]
+	k.SetArg(0, uint64(unsafe.Sizeof(args)), unsafe.Pointer(&args))
+	setArgMatrix(k, 1, a)
+	if b != nil {
+		setArgMatrix(k, 3, b)
+		setArgMatrix(k, 5, m)
+	} else {
+		setArgMatrix(k, 3, m)
+	}
+	k.EnqueueKernel(hw, globalWG(m), nil)
+	return m
+}
+
 // UnaryCL type represents a user function of one variable.
 type UnaryCL struct {
 	*scl.Software
@@ -0,0 +1,145 @@
+package blas
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	minWork  = 16384 // minimum no. of elements to process in each goroutine
+	natBlock = 64    // block size for native matrix multiply
+)
+
+var (
+	threads   = runtime.NumCPU()
+	pool      *workerPool
+	poolMutex sync.Mutex
+)
+
+// pool of worker goroutines which run tasks from a channel. The pool is replaced when the number of threads
+// changes, the old channel is closed when the last parallel call using it returns so its workers can exit.
+type workerPool struct {
+	tasks   chan func()
+	threads int
+	users   int   // number of parallel calls using this pool, protected by poolMutex
+	busy    int32 // number of workers running a task
+}
+
+// SetThreads function sets the number of worker goroutines used by the native implementations.
+// If n is less than 1 then the number of CPUs is used. Returns the previous setting.
+// Results are deterministic for a given number of threads.
+func SetThreads(n int) int {
+	poolMutex.Lock()
+	defer poolMutex.Unlock()
+	prev := threads
+	if n < 1 {
+		n = runtime.NumCPU()
+	}
+	if n != threads && pool != nil {
+		if pool.users == 0 {
+			close(pool.tasks)
+		}
+		pool = nil
+	}
+	threads = n
+	return prev
+}
+
+// Threads function returns the number of worker goroutines.
+func Threads() int {
+	poolMutex.Lock()
+	defer poolMutex.Unlock()
+	return threads
+}
+
+// start the worker pool if it is not already running and return it with the number of users incremented.
+// release must be called when done.
+func startPool() *workerPool {
+	poolMutex.Lock()
+	defer poolMutex.Unlock()
+	if pool == nil {
+		pool = &workerPool{tasks: make(chan func(), threads), threads: threads}
+		for i := 0; i < threads; i++ {
+			go func(p *workerPool) {
+				for fn := range p.tasks {
+					atomic.AddInt32(&p.busy, 1)
+					fn()
+					atomic.AddInt32(&p.busy, -1)
+				}
+			}(pool)
+		}
+	}
+	pool.users++
+	return pool
+}
+
+// release the pool, closing the task channel if it has been replaced and this is the last user
+func (p *workerPool) release() {
+	poolMutex.Lock()
+	defer poolMutex.Unlock()
+	p.users--
+	if p != pool && p.users == 0 {
+		close(p.tasks)
+	}
+}
+
+// chunks method returns the number of chunks that n items will be split into, where each chunk
+// has at least grain items.
+func (p *workerPool) chunks(n, grain int) int {
+	if grain < 1 {
+		grain = 1
+	}
+	nc := n / grain
+	if nc > p.threads {
+		nc = p.threads
+	}
+	if nc < 1 {
+		nc = 1
+	}
+	return nc
+}
+
+// run method splits the range [0, n) into nc chunks and calls fn for each chunk. If every worker is busy,
+// as when run is called from a task on the pool, the chunks are processed inline to avoid deadlock.
+func (p *workerPool) run(n, nc int, fn func(chunk, start, end int)) {
+	size := (n + nc - 1) / nc
+	inline := nc == 1 || int(atomic.LoadInt32(&p.busy)) >= p.threads
+	var wg sync.WaitGroup
+	for c := 0; c < nc; c++ {
+		start, end := c*size, (c+1)*size
+		if end > n {
+			end = n
+		}
+		if start >= end {
+			continue
+		}
+		if inline {
+			fn(c, start, end)
+			continue
+		}
+		wg.Add(1)
+		chunk := c
+		p.tasks <- func() {
+			fn(chunk, start, end)
+			wg.Done()
+		}
+	}
+	wg.Wait()
+}
+
+// parallel function splits the range [0, n) into chunks and calls fn for each chunk using the worker pool.
+// The partitioning only depends on n, grain and the number of threads.
+func parallel(n, grain int, fn func(chunk, start, end int)) {
+	p := startPool()
+	defer p.release()
+	p.run(n, p.chunks(n, grain), fn)
+}
+
+// rowGrain function returns the minimum number of rows to process in each chunk for a matrix with given no. of columns.
+func rowGrain(cols int) int {
+	if cols < 1 {
+		return minWork
+	}
+	return 1 + (minWork-1)/cols
+}
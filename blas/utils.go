@@ -72,6 +72,13 @@ func checkEqualSize(caller string, a, b, out Matrix) {
 	out.Reshape(rows, cols, false)
 }
 
+func imin(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
 func fmin(a, b float32) float32 {
 	if a < b {
 		return a
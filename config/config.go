@@ -63,15 +63,34 @@ func Load(cfg interface{}, name string) error {
 	}
 	file := fileName(name)
 	fmt.Println("load config from", file)
+	return loadJSON(cfg, file)
+}
+
+// LoadNetwork reads the network architecture for a model from the .net file in the config directory.
+// Returns false if the file does not exist.
+func LoadNetwork(arch interface{}, name string) (found bool, err error) {
+	file := ConfigDir + "/" + name + ".net"
+	if _, err = os.Stat(file); os.IsNotExist(err) {
+		return false, nil
+	}
+	fmt.Println("load network from", file)
+	if err = loadJSON(arch, file); err != nil {
+		return true, fmt.Errorf("%s: %s", file, err)
+	}
+	return true, nil
+}
+
+func loadJSON(v interface{}, file string) error {
 	r, err := os.Open(file)
 	if err != nil {
 		return err
 	}
+	defer r.Close()
 	buf, err := ioutil.ReadAll(r)
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(buf, cfg)
+	return json.Unmarshal(buf, v)
 }
 
 // Return an array of strings with the struct keys
@@ -0,0 +1,237 @@
+package network
+
+import (
+	"fmt"
+	"github.com/jnb666/deepthought/config"
+)
+
+// Architecture type describes the network layers. It is read from the <model>.net JSON file in the
+// config directory, if present, and used in place of the network defined by the data loader.
+//
+// Example:
+//
+//	{
+//	  "Input":  [28, 28],
+//	  "Layers": [
+//	    {"Type": "conv", "Filters": 20, "Kernel": 5},
+//	    {"Type": "maxpool", "Size": 2, "Activation": "relu"},
+//	    {"Type": "dense", "Nodes": 100, "Init": "he"},
+//	    {"Type": "dropout", "Rate": 0.5, "Activation": "relu"},
+//	    {"Type": "dense"}
+//	  ],
+//	  "Output": {"Cost": "crossentropy"}
+//	}
+type Architecture struct {
+	Input  []int      // input dimensions: default is from the dataset
+	Layers []LayerDef // input and hidden layers
+	Output OutputDef  // output layer
+}
+
+// LayerDef type describes one layer. The activation function is applied to the inputs to the layer.
+type LayerDef struct {
+	Type       string  // dense, conv, maxpool, avgpool, dropout, batchnorm, rnn, lstm or embedding
+	Activation string  // linear, sigmoid, tanh, relu or softmax: default is linear
+	Nodes      int     // number of outputs for dense or recurrent layer or embedding size: default for last layer is number of dataset outputs
+	Filters    int     // number of convolution filters
+	Kernel     int     // convolution kernel size
+	Stride     int     // convolution or pooling stride: default is 1 for convolution and Size for pooling
+	Pad        int     // convolution padding
+	Size       int     // pooling window size
+	Rate       float32 // dropout rate
+	Init       string  // weight initialiser for dense and convolution layers: default from config
+	Bias       float32 // initial bias if Init is set
+	Sequence   bool    // recurrent layer outputs the hidden state at every step
+	Truncate   int     // recurrent layer back propagation through time limit
+	Clip       float32 // recurrent layer gradient clipping
+	Vocab      int     // number of distinct input values for embedding layer
+}
+
+// OutputDef type describes the output layer.
+type OutputDef struct {
+	Cost       string  // quadratic, crossentropy, binarycrossentropy, huber, hinge, squaredhinge, logcosh or mae
+	Activation string  // activation for costs other than cross entropy: default is linear
+	Delta      float32 // threshold for huber cost: default is 1
+}
+
+// LoadArchitecture function reads the architecture for the named model. Returns nil if there is no file.
+func LoadArchitecture(name string) (*Architecture, error) {
+	arch := new(Architecture)
+	found, err := config.LoadNetwork(arch, name)
+	if !found || err != nil {
+		return nil, err
+	}
+	return arch, nil
+}
+
+// Build method creates a new network with the given batch size for dataset d.
+func (a *Architecture) Build(batchSize int, d *Dataset) (n *Network, err error) {
+	if len(a.Layers) == 0 {
+		return nil, fmt.Errorf("network architecture: no layers defined")
+	}
+	dims := a.Input
+	if len(dims) == 0 {
+		dims = d.InputDims
+	}
+	if len(dims) == 0 {
+		dims = []int{d.NumInputs}
+	}
+	if dimSize(dims) != d.NumInputs {
+		return nil, fmt.Errorf("network architecture: input dims %v do not match dataset with %d inputs", dims, d.NumInputs)
+	}
+	n = New(batchSize, d.OutputToClass)
+	for i, l := range a.Layers {
+		if dims, err = n.addDef(l, dims, i == len(a.Layers)-1, d.NumOutputs); err != nil {
+			n.Release()
+			return nil, fmt.Errorf("network architecture: layer %d (%s): %s", i, l.Type, err)
+		}
+	}
+	if dimSize(dims) != d.NumOutputs {
+		n.Release()
+		return nil, fmt.Errorf("network architecture: last layer has %d outputs, expecting %d", dimSize(dims), d.NumOutputs)
+	}
+	if err = n.addOutputDef(a.Output, d.NumOutputs); err != nil {
+		n.Release()
+		return nil, fmt.Errorf("network architecture: output layer: %s", err)
+	}
+	for _, set := range []*Data{d.Train, d.Valid, d.Test} {
+		if err = n.CheckInputs(set); err != nil {
+			n.Release()
+			return nil, fmt.Errorf("network architecture: %s", err)
+		}
+	}
+	return n, nil
+}
+
+func dimSize(dims []int) int {
+	n := 1
+	for _, d := range dims {
+		n *= d
+	}
+	return n
+}
+
+// add layer to the network and return the output dimensions
+func (n *Network) addDef(l LayerDef, dims []int, last bool, nout int) (outDims []int, err error) {
+	if l.Activation == "" {
+		l.Activation = "linear"
+	}
+	a, err := activation(l.Activation)
+	if err != nil {
+		return nil, err
+	}
+	var init []Initialiser
+	if l.Init != "" {
+		if _, ok := initialisers[l.Init]; !ok {
+			return nil, fmt.Errorf("initialiser %q not found", l.Init)
+		}
+		init = append(init, Initialiser{Weights: l.Init, Bias: l.Bias})
+	}
+	// the layer functions panic if the dimensions are not valid
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	switch l.Type {
+	case "dense":
+		if l.Nodes == 0 && last {
+			l.Nodes = nout
+		}
+		if l.Nodes < 1 {
+			return nil, fmt.Errorf("number of nodes must be set")
+		}
+		n.AddLayer(dims, l.Nodes, a, init...)
+		return []int{l.Nodes}, nil
+	case "conv":
+		if l.Filters < 1 || l.Kernel < 1 {
+			return nil, fmt.Errorf("number of filters and kernel size must be set")
+		}
+		if l.Stride == 0 {
+			l.Stride = 1
+		}
+		return n.AddConvLayer(dims, l.Filters, l.Kernel, l.Stride, l.Pad, a, init...), nil
+	case "maxpool", "avgpool":
+		if l.Size < 1 {
+			return nil, fmt.Errorf("pooling size must be set")
+		}
+		if l.Stride == 0 {
+			l.Stride = l.Size
+		}
+		if l.Type == "maxpool" {
+			return n.AddMaxPoolLayer(dims, l.Size, l.Stride, a), nil
+		}
+		return n.AddAvgPoolLayer(dims, l.Size, l.Stride, a), nil
+	case "rnn", "lstm":
+		if l.Nodes == 0 && last && !l.Sequence {
+			l.Nodes = nout
+		}
+		if l.Nodes < 1 {
+			return nil, fmt.Errorf("number of nodes must be set")
+		}
+		opts := RecurrentOpts{Sequence: l.Sequence, Truncate: l.Truncate, Clip: l.Clip}
+		if l.Type == "lstm" {
+			return n.AddLSTMLayer(dims, l.Nodes, opts, a, init...), nil
+		}
+		return n.AddRNNLayer(dims, l.Nodes, opts, a, init...), nil
+	case "embedding":
+		if l.Nodes < 1 || l.Vocab < 1 {
+			return nil, fmt.Errorf("number of nodes and vocab size must be set")
+		}
+		if l.Activation != Linear.Name {
+			return nil, fmt.Errorf("activation is not supported")
+		}
+		return n.AddEmbeddingLayer(dimSize(dims), l.Vocab, l.Nodes, init...), nil
+	case "dropout":
+		n.AddDropoutLayer(dims, l.Rate, a)
+		return dims, nil
+	case "batchnorm":
+		n.AddBatchNormLayer(dims, a)
+		return dims, nil
+	case "":
+		return nil, fmt.Errorf("layer type must be set")
+	}
+	return nil, fmt.Errorf("unknown layer type")
+}
+
+func (n *Network) addOutputDef(o OutputDef, nout int) error {
+	switch o.Cost {
+	case "quadratic":
+		if o.Activation == "" {
+			o.Activation = "linear"
+		}
+		a, err := activation(o.Activation)
+		if err != nil {
+			return err
+		}
+		n.AddQuadraticOutput(nout, a)
+	case "crossentropy":
+		if o.Activation != "" && o.Activation != "softmax" {
+			return fmt.Errorf("cross entropy output must use softmax activation")
+		}
+		n.AddCrossEntropyOutput(nout)
+	case "binarycrossentropy":
+		if o.Activation != "" && o.Activation != "sigmoid" {
+			return fmt.Errorf("binary cross entropy output must use sigmoid activation")
+		}
+		n.AddBinaryCrossEntropyOutput(nout)
+	case "":
+		return fmt.Errorf("cost must be set")
+	default:
+		if o.Delta == 0 {
+			o.Delta = 1
+		}
+		c, err := costFunction(o.Cost, o.Delta)
+		if err != nil {
+			return err
+		}
+		if o.Activation == "" {
+			o.Activation = "linear"
+		}
+		a, err := activation(o.Activation)
+		if err != nil {
+			return err
+		}
+		n.AddOutput(nout, a, c)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package network
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var testArch = `{
+	"Layers": [
+		{"Type": "conv", "Filters": 4, "Kernel": 3, "Pad": 1},
+		{"Type": "maxpool", "Size": 2, "Activation": "relu"},
+		{"Type": "batchnorm"},
+		{"Type": "dense", "Nodes": 10, "Init": "he", "Bias": 0.1},
+		{"Type": "dropout", "Rate": 0.5, "Activation": "relu"},
+		{"Type": "dense"}
+	],
+	"Output": {"Cost": "crossentropy"}
+}`
+
+func TestArchitecture(t *testing.T) {
+	d := &Dataset{NumInputs: 64, NumOutputs: 3, InputDims: []int{8, 8}}
+	arch := new(Architecture)
+	if err := json.Unmarshal([]byte(testArch), arch); err != nil {
+		t.Fatal(err)
+	}
+	n, err := arch.Build(5, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Release()
+	if n.Layers != 7 || n.BatchSize != 5 {
+		t.Fatalf("expected 7 layers with batch size 5, got %d %d", n.Layers, n.BatchSize)
+	}
+	expect := [][]int{{8, 8}, {8, 8, 4}, {4, 4, 4}, {4, 4, 4}, {10}, {10}, {3}}
+	for i, layer := range n.Nodes {
+		if dims := layer.Dims(); !equalDims(dims, expect[i]) {
+			t.Errorf("layer %d: expected dims %v got %v", i, expect[i], dims)
+		}
+	}
+	if init, ok := n.layerInit[3]; !ok || init.Weights != "he" || init.Bias != 0.1 {
+		t.Error("layer initialiser not set")
+	}
+	if _, ok := n.Nodes[6].(*outLayer); !ok || n.Nodes[6].(*outLayer).cost.Name() != "crossentropy" {
+		t.Error("expected cross entropy output layer")
+	}
+}
+
+func equalDims(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestArchitectureErrors(t *testing.T) {
+	d := &Dataset{NumInputs: 64, NumOutputs: 3}
+	tests := []struct{ arch, err string }{
+		{`{"Layers": [{"Type": "conv", "Filters": 4, "Kernel": 3}], "Output": {"Cost": "quadratic"}}`,
+			"layer 0 (conv): convolution layer: invalid input dims [64]"},
+		{`{"Input": [8, 8], "Layers": [{"Type": "dense", "Nodes": 5}, {"Type": "pool", "Size": 2}], "Output": {"Cost": "quadratic"}}`,
+			"layer 1 (pool): unknown layer type"},
+		{`{"Layers": [{"Type": "dense", "Nodes": 5, "Activation": "foo"}, {"Type": "dense"}], "Output": {"Cost": "quadratic"}}`,
+			"layer 0 (dense): activation function \"foo\" not found"},
+		{`{"Layers": [{"Type": "dense"}, {"Type": "dense"}], "Output": {"Cost": "quadratic"}}`,
+			"layer 0 (dense): number of nodes must be set"},
+		{`{"Layers": [{"Type": "dropout", "Rate": 1.5}, {"Type": "dense"}], "Output": {"Cost": "quadratic"}}`,
+			"layer 0 (dropout): dropout layer: rate 1.5 must be in range [0,1)"},
+		{`{"Layers": [{"Type": "dense", "Nodes": 5}], "Output": {"Cost": "quadratic"}}`,
+			"last layer has 5 outputs, expecting 3"},
+		{`{"Layers": [{"Type": "dense"}], "Output": {"Cost": "crossentropy", "Activation": "relu"}}`,
+			"output layer: cross entropy output must use softmax activation"},
+		{`{"Layers": [{"Type": "dense"}], "Output": {"Cost": "huber", "Delta": -1}}`,
+			"output layer: huber cost delta must be greater than zero"},
+		{`{"Layers": [{"Type": "dense"}], "Output": {"Cost": "foo"}}`,
+			"output layer: cost function \"foo\" not found"},
+		{`{"Layers": [{"Type": "lstm", "Nodes": 5}, {"Type": "dense"}], "Output": {"Cost": "quadratic"}}`,
+			"layer 0 (lstm): recurrent layer: invalid input dims [64]"},
+		{`{"Input": [10], "Layers": [{"Type": "dense"}], "Output": {"Cost": "quadratic"}}`,
+			"input dims [10] do not match dataset with 64 inputs"},
+	}
+	for _, test := range tests {
+		arch := new(Architecture)
+		if err := json.Unmarshal([]byte(test.arch), arch); err != nil {
+			t.Fatal(err)
+		}
+		_, err := arch.Build(4, d)
+		t.Log(err)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("expected error %q", test.err)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package network
+
+import (
+	"github.com/jnb666/deepthought/blas"
+	"math"
+)
+
+const (
+	bnEpsilon  = 1e-5 // added to variance to avoid divide by zero
+	bnMomentum = 0.9  // decay factor for running mean and variance
+)
+
+// layers which initialise their own weights
+type weightInitLayer interface {
+	initWeights()
+}
+
+type batchNormLayer struct {
+	dims      []int
+	training  bool
+	fixed     bool
+	activ     Activation
+	invSqrt   blas.UnaryFunction
+	input     blas.Matrix // Z matrix of value at each node [samples, nin]
+	output    blas.Matrix // return value at each node [samples, nin]
+	xhat      blas.Matrix // normalised input [samples, nin]
+	scale     blas.Matrix // 1/std dev for each column [samples, nin]
+	temp      blas.Matrix // work space [samples, nin]
+	ones      blas.Matrix // column vector of ones [samples, 1]
+	mean      blas.Matrix // batch mean [nin, 1]
+	variance  blas.Matrix // batch variance [nin, 1]
+	invStd    blas.Matrix // 1/sqrt(variance+epsilon) [nin, 1]
+	runMean   blas.Matrix // running mean used for inference [nin, 1]
+	runVar    blas.Matrix // running variance used for inference [nin, 1]
+	gradGamma blas.Matrix // gradient of scale factor [nin, 1]
+	gradBeta  blas.Matrix // gradient of shift [nin, 1]
+	weights   blas.Matrix // W scale in first column and shift in last column [nin, 2]
+	gradient  blas.Matrix // G gradient of weight matrix [nin, 2]
+	deriv     blas.Matrix // Fp matrix of derivative of activation fn [samples, nin]
+	delta     blas.Matrix // D matrix of errors at each node [samples, nin]
+}
+
+// AddBatchNormLayer method adds a batch normalisation layer to the network. Each input is normalised
+// using the mean and variance over the minibatch and then multiplied by a learned scale and added to a learned shift.
+// A running average of the mean and variance is used in inference mode. Output dimensions are the same as the input.
+func (n *Network) AddBatchNormLayer(dims []int, a Activation) {
+	batch := n.BatchSize
+	nin := 1
+	for _, n := range dims {
+		nin *= n
+	}
+	l := &batchNormLayer{
+		dims:      dims,
+		activ:     a,
+		input:     blas.New(batch, nin),
+		output:    blas.New(batch, nin),
+		xhat:      blas.New(batch, nin),
+		scale:     blas.New(batch, nin),
+		temp:      blas.New(batch, nin),
+		ones:      blas.New(batch, 1),
+		mean:      blas.New(nin, 1),
+		variance:  blas.New(nin, 1),
+		invStd:    blas.New(nin, 1),
+		runMean:   blas.New(nin, 1),
+		runVar:    blas.New(nin, 1),
+		gradGamma: blas.New(nin, 1),
+		gradBeta:  blas.New(nin, 1),
+		weights:   blas.New(nin, 2),
+		gradient:  blas.New(nin, 2),
+	}
+	if blas.Implementation() == blas.OpenCL32 {
+		l.invSqrt = blas.NewUnaryCL("float y = rsqrt(x + 1e-5f);")
+	} else if blas.Implementation() == blas.Native64 {
+		l.invSqrt = blas.Unary64(func(x float64) float64 { return 1 / math.Sqrt(x+bnEpsilon) })
+	} else {
+		l.invSqrt = blas.Unary32(func(x float32) float32 { return float32(1 / math.Sqrt(float64(x)+bnEpsilon)) })
+	}
+	if a.Deriv != nil {
+		l.deriv = blas.New(batch, nin)
+	}
+	if n.Layers > 0 {
+		l.delta = blas.New(batch, nin)
+	}
+	l.initWeights()
+	n.add(l)
+}
+
+func (l *batchNormLayer) Dims() []int {
+	return l.dims
+}
+
+func (l *batchNormLayer) Values() blas.Matrix {
+	return l.input
+}
+
+func (l *batchNormLayer) Release() {
+	for _, m := range []blas.Matrix{l.input, l.output, l.xhat, l.scale, l.temp, l.ones, l.mean, l.variance,
+		l.invStd, l.runMean, l.runVar, l.gradGamma, l.gradBeta, l.weights, l.gradient} {
+		m.Release()
+	}
+	if l.deriv != nil {
+		l.deriv.Release()
+	}
+	if l.delta != nil {
+		l.delta.Release()
+	}
+}
+
+func (l *batchNormLayer) Weights() blas.Matrix { return l.weights }
+
+func (l *batchNormLayer) Gradient() blas.Matrix { return l.gradient }
+
+func (l *batchNormLayer) Cost(t blas.Matrix) blas.Matrix {
+	panic("no cost for batch normalisation layer!")
+}
+
+func (l *batchNormLayer) setTraining(on, fixed bool) {
+	l.training = on
+	l.fixed = fixed
+}
+
+// scale is set to one and shift to zero, running stats are reset
+func (l *batchNormLayer) initWeights() {
+	l.weights.Col(0, 1).Set(1)
+	l.weights.Col(1, 2).Set(0)
+	l.gradient.Set(0)
+	l.runMean.Set(0)
+	l.runVar.Set(1)
+}
+
+// broadcast column vector v [nin, 1] to each row of out [samples, nin]
+func (l *batchNormLayer) broadcast(v, out blas.Matrix) blas.Matrix {
+	return out.Mul(l.ones, v, false, true, false)
+}
+
+// sum each column of m [samples, nin] to out [nin, 1]
+func (l *batchNormLayer) sumCols(m, out blas.Matrix) blas.Matrix {
+	return out.Mul(m, l.ones, true, false, false)
+}
+
+func (l *batchNormLayer) FeedForward(in blas.Matrix) blas.Matrix {
+	l.activ.Func.Apply(in, l.input)
+	if l.activ.Deriv != nil {
+		l.activ.Deriv.Apply(in, l.deriv)
+	}
+	rows := float32(in.Rows())
+	l.ones.Reshape(in.Rows(), 1, false).Set(1)
+	if l.training {
+		// normalise using batch statistics
+		l.sumCols(l.input, l.mean).Scale(1 / rows)
+		l.xhat.Add(l.input, l.broadcast(l.mean, l.temp), -1)
+		l.temp.MulElem(l.xhat, l.xhat)
+		l.sumCols(l.temp, l.variance).Scale(1 / rows)
+		if !l.fixed {
+			l.runMean.Scale(bnMomentum).Add(l.runMean, l.mean, 1-bnMomentum)
+			l.runVar.Scale(bnMomentum).Add(l.runVar, l.variance, 1-bnMomentum)
+		}
+		l.invSqrt.Apply(l.variance, l.invStd)
+	} else {
+		// normalise using running averages
+		l.xhat.Add(l.input, l.broadcast(l.runMean, l.temp), -1)
+		l.invSqrt.Apply(l.runVar, l.invStd)
+	}
+	l.broadcast(l.invStd, l.scale)
+	l.xhat.MulElem(l.xhat, l.scale)
+	// apply learned scale and shift
+	l.output.MulElem(l.xhat, l.broadcast(l.weights.Col(0, 1), l.temp))
+	return l.output.Add(l.output, l.broadcast(l.weights.Col(1, 2), l.temp), 1)
+}
+
+func (l *batchNormLayer) BackProp(err blas.Matrix) blas.Matrix {
+	// calculate the gradient
+	l.sumCols(err, l.gradBeta)
+	l.sumCols(l.temp.MulElem(err, l.xhat), l.gradGamma)
+	l.gradient.Col(0, 1).Copy(l.gradGamma, nil)
+	l.gradient.Col(1, 2).Copy(l.gradBeta, nil)
+	// propagate error backward
+	if l.delta != nil {
+		if l.training {
+			// include dependence of batch mean and variance on the input
+			rows := float32(err.Rows())
+			l.delta.Add(err, l.broadcast(l.gradBeta, l.temp), -1/rows)
+			l.broadcast(l.gradGamma, l.temp).MulElem(l.temp, l.xhat)
+			l.delta.Add(l.delta, l.temp, -1/rows)
+		} else {
+			l.delta.Copy(err, nil)
+		}
+		l.delta.MulElem(l.delta, l.scale)
+		l.delta.MulElem(l.delta, l.broadcast(l.weights.Col(0, 1), l.temp))
+		if l.deriv != nil {
+			l.delta.MulElem(l.delta, l.deriv)
+		}
+	}
+	return l.delta
+}
@@ -0,0 +1,55 @@
+package network
+
+import (
+	"github.com/jnb666/deepthought/blas"
+	"github.com/jnb666/deepthought/vec"
+	"math/rand"
+	"testing"
+)
+
+func TestBatchNorm(t *testing.T) {
+	rand.Seed(1)
+	batch, nin := 20, 5
+	n := New(batch, nil)
+	n.AddBatchNormLayer([]int{nin}, Linear)
+	n.AddQuadraticOutput(nin, Linear)
+	defer n.Release()
+	n.SetRandomWeights()
+	input := randMatrix(batch, nin).Scale(10)
+	// output should have zero mean and unit variance in training mode
+	n.SetTraining(true)
+	output := n.FeedForward(input).Data(blas.ColMajor)
+	for col := 0; col < nin; col++ {
+		stats := new(vec.RunningStat)
+		for _, x := range output[col*batch : (col+1)*batch] {
+			stats.Push(x)
+		}
+		variance := stats.Var / stats.Count
+		t.Logf("column %d: mean=%.4f var=%.4f", col, stats.Mean, variance)
+		if vec.Abs(float32(stats.Mean)) > 1e-4 || vec.Abs(float32(variance)-1) > 1e-3 {
+			t.Error("output is not normalised")
+		}
+	}
+	// inference mode uses running averages which start at zero mean and unit variance
+	n.SetRandomWeights()
+	n.SetTraining(false)
+	output = n.FeedForward(input).Data(blas.RowMajor)
+	for i, x := range input.Data(blas.RowMajor) {
+		if vec.Abs(output[i]-x) > 1e-3 {
+			t.Fatalf("inference mode: expected %g got %g", x, output[i])
+		}
+	}
+}
+
+func TestBatchNormGradient(t *testing.T) {
+	rand.Seed(1)
+	batch := 8
+	n := New(batch, nil)
+	n.AddLayer([]int{6}, 5, Linear)
+	n.AddBatchNormLayer([]int{5}, Linear)
+	n.AddLayer([]int{5}, 3, Sigmoid)
+	n.AddQuadraticOutput(3, Sigmoid)
+	defer n.Release()
+	n.SetTraining(true)
+	checkGradient(t, n, randMatrix(batch, 6), randMatrix(batch, 3), 1e-4)
+}
@@ -0,0 +1,132 @@
+package network
+
+import (
+	"fmt"
+	"github.com/jnb666/deepthought/blas"
+	"strconv"
+	"strings"
+)
+
+// ClassWeights function returns the weight for each of nclass classes given the config setting. This is
+// either a comma separated list of weights, or "auto" to use the inverse of the class frequency in d
+// scaled so that the mean weight over the samples is 1. Returns nil if the setting is blank, or an error
+// if the samples in d do not each have a single class in the range [0, nclass).
+func ClassWeights(setting string, d *Data, nclass int) ([]float32, error) {
+	setting = strings.TrimSpace(setting)
+	if setting == "" {
+		return nil, nil
+	}
+	if err := checkClasses(d, nclass); err != nil {
+		return nil, err
+	}
+	weights := make([]float32, nclass)
+	if setting == "auto" {
+		count := make([]int, nclass)
+		classes := d.Classes.Data(blas.ColMajor)[:d.NumSamples]
+		for _, c := range classes {
+			count[int(c)]++
+		}
+		for i, n := range count {
+			weights[i] = 1
+			if n > 0 {
+				weights[i] = float32(len(classes)) / float32(nclass*n)
+			}
+		}
+		return weights, nil
+	}
+	fields := strings.Split(setting, ",")
+	if len(fields) != nclass {
+		return nil, fmt.Errorf("ClassWeights: expecting %d weights, got %d", nclass, len(fields))
+	}
+	for i, f := range fields {
+		w, err := strconv.ParseFloat(strings.TrimSpace(f), 32)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("ClassWeights: invalid weight %q", f)
+		}
+		weights[i] = float32(w)
+	}
+	return weights, nil
+}
+
+// check that each sample has a single class which can be used to look up its weight
+func checkClasses(d *Data, nclass int) error {
+	if d.Classes == nil || d.Classes.Cols() != 1 {
+		return fmt.Errorf("ClassWeights: class weights need a single class for each sample")
+	}
+	for _, c := range d.Classes.Data(blas.ColMajor)[:d.NumSamples] {
+		if int(c) < 0 || int(c) >= nclass {
+			return fmt.Errorf("ClassWeights: class %g out of range", c)
+		}
+	}
+	return nil
+}
+
+// UpdateClassWeights method sets the class weights from cfg.ClassWeights if the setting has changed.
+// Returns an error and leaves the weights unchanged if the setting is not valid for the training,
+// validation or test set.
+func (n *Network) UpdateClassWeights(cfg *Config, d *Dataset) error {
+	if cfg.ClassWeights == n.classCfg {
+		return nil
+	}
+	weights, err := ClassWeights(cfg.ClassWeights, d.Train, d.NumOutputs)
+	if err != nil {
+		return err
+	}
+	if weights != nil {
+		for _, set := range []*Data{d.Valid, d.Test} {
+			if set != nil {
+				if err = checkClasses(set, d.NumOutputs); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	n.SetClassWeights(weights)
+	n.classCfg = cfg.ClassWeights
+	return nil
+}
+
+// SetClassWeights method sets the weight applied to the cost and back propagated error for samples
+// of each class. If weights is nil then all classes have the same weight.
+func (n *Network) SetClassWeights(weights []float32) {
+	n.classWeights = weights
+	for d, w := range n.weightCache {
+		w.Release()
+		delete(n.weightCache, d)
+	}
+}
+
+// get the weight for each sample in d given the class weights and the sample weights, if any.
+// Returns nil if all samples have the same weight.
+func (n *Network) sampleWeights(d *Data) blas.Matrix {
+	if n.classWeights == nil {
+		return d.Weights
+	}
+	if w, ok := n.weightCache[d]; ok {
+		return w
+	}
+	if d.Classes == nil || d.Classes.Cols() != 1 {
+		panic("class weights need a single class for each sample")
+	}
+	classes := d.Classes.Data(blas.ColMajor)
+	var scale []float32
+	if d.Weights != nil {
+		scale = d.Weights.Data(blas.ColMajor)
+	}
+	data := make([]float32, len(classes))
+	for i, c := range classes {
+		if int(c) < 0 || int(c) >= len(n.classWeights) {
+			panic("class out of range for class weights")
+		}
+		data[i] = n.classWeights[int(c)]
+		if scale != nil {
+			data[i] *= scale[i]
+		}
+	}
+	w := blas.New(len(data), 1).Load(blas.ColMajor, data...)
+	if n.weightCache == nil {
+		n.weightCache = map[*Data]blas.Matrix{}
+	}
+	n.weightCache[d] = w
+	return w
+}
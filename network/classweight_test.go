@@ -0,0 +1,154 @@
+package network
+
+import (
+	"github.com/jnb666/deepthought/blas"
+	"github.com/jnb666/deepthought/vec"
+	"math/rand"
+	"testing"
+)
+
+func TestClassWeights(t *testing.T) {
+	d := &Data{
+		Input:      blas.New(4, 1),
+		Output:     blas.New(4, 2),
+		Classes:    blas.New(4, 1).Load(blas.RowMajor, 0, 0, 0, 1),
+		NumSamples: 4,
+	}
+	defer d.Release()
+	w, err := ClassWeights("auto", d, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("auto:", w)
+	if len(w) != 2 || vec.Abs(w[0]-2.0/3) > 1e-6 || w[1] != 2 {
+		t.Error("wrong auto weights")
+	}
+	if w, err = ClassWeights(" 1, 2.5", d, 2); err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, w, []float32{1, 2.5})
+	if w, err = ClassWeights("", d, 2); w != nil || err != nil {
+		t.Error("expecting nil weights")
+	}
+	for _, setting := range []string{"1", "1,x", "1,-1"} {
+		if _, err = ClassWeights(setting, d, 2); err == nil {
+			t.Errorf("expecting error for %q", setting)
+		}
+		t.Log(err)
+	}
+	// class out of range and multi-label classes are rejected for explicit weights
+	bad := &Data{Classes: blas.New(4, 1).Load(blas.RowMajor, 0, 2, 0, 1), NumSamples: 4}
+	multi := &Data{Classes: blas.New(4, 2).Load(blas.RowMajor, 0, 1, 1, 1, 0, 0, 1, 0), NumSamples: 4}
+	defer bad.Classes.Release()
+	defer multi.Classes.Release()
+	for _, set := range []*Data{bad, multi} {
+		if _, err = ClassWeights("1,2", set, 2); err == nil {
+			t.Error("expecting error for invalid classes")
+		}
+		t.Log(err)
+	}
+	// test set is checked when the weights are updated
+	n := New(4, MaxCol{})
+	n.AddLayer([]int{1}, 2, Linear)
+	n.AddCrossEntropyOutput(2)
+	defer n.Release()
+	if err = n.UpdateClassWeights(&Config{ClassWeights: "1,2"}, &Dataset{Train: d, Test: bad, NumOutputs: 2}); err == nil {
+		t.Error("expecting error for invalid test set classes")
+	}
+	if n.classWeights != nil {
+		t.Error("class weights should not be set after error")
+	}
+}
+
+func TestWeightedCost(t *testing.T) {
+	rand.Seed(1)
+	samples := 6
+	d := &Data{
+		Input:      randMatrix(samples, 3),
+		Output:     blas.New(samples, 2).Load(blas.RowMajor, 1, 0, 0, 1, 1, 0, 1, 0, 0, 1, 1, 0),
+		Classes:    blas.New(samples, 1).Load(blas.RowMajor, 0, 1, 0, 0, 1, 0),
+		Weights:    blas.New(samples, 1).Load(blas.RowMajor, 1, 2, 1, 0.5, 1, 3),
+		NumSamples: samples,
+	}
+	defer d.Release()
+	n := New(samples, MaxCol{})
+	n.AddLayer([]int{3}, 2, Linear)
+	n.AddCrossEntropyOutput(2)
+	defer n.Release()
+	n.SetRandomWeights()
+	out := n.Nodes[1].(*outLayer)
+	// unweighted cost and error for each sample
+	n.FeedForward(d.Input)
+	cost := out.Cost(d.Output).Data(blas.RowMajor)
+	delta := out.BackProp(d.Output).Data(blas.RowMajor)
+	n.SetClassWeights([]float32{1, 4})
+	weights := []float32{1, 8, 1, 0.5, 4, 3}
+	var total float32
+	for i, w := range weights {
+		total += w * cost[i]
+	}
+	hist := vec.New(histBins)
+	m := n.GetError(samples, d, hist, histMax)
+	t.Logf("weighted cost: %.5f", m.Cost)
+	if vec.Abs(m.Cost-total/float32(2*samples)) > 1e-5 {
+		t.Errorf("wrong weighted cost: expecting %.5f", total/float32(2*samples))
+	}
+	// error at output is scaled by the weights
+	out.setWeights(n.sampleWeights(d))
+	n.FeedForward(d.Input)
+	weighted := out.BackProp(d.Output).Data(blas.RowMajor)
+	for i, w := range weights {
+		for j := 0; j < 2; j++ {
+			if vec.Abs(weighted[2*i+j]-w*delta[2*i+j]) > 1e-6 {
+				t.Errorf("wrong weighted delta for sample %d", i)
+			}
+		}
+	}
+}
+
+// generate samples with 2 random inputs where the class is 1 if both inputs are greater than 0.75
+func imbalancedData(samples int) *Data {
+	in := make([]float32, 2*samples)
+	out := make([]float32, 2*samples)
+	class := make([]float32, samples)
+	for i := range class {
+		in[2*i], in[2*i+1] = rand.Float32(), rand.Float32()
+		if in[2*i] > 0.75 && in[2*i+1] > 0.75 {
+			class[i] = 1
+		}
+		out[2*i+int(class[i])] = 1
+	}
+	return &Data{
+		Input:      blas.New(samples, 2).Load(blas.RowMajor, in...),
+		Output:     blas.New(samples, 2).Load(blas.RowMajor, out...),
+		Classes:    blas.New(samples, 1).Load(blas.RowMajor, class...),
+		NumSamples: samples,
+	}
+}
+
+func TestTrainClassWeights(t *testing.T) {
+	rand.Seed(1)
+	d := &Dataset{OutputToClass: MaxCol{}, Train: imbalancedData(200), Test: imbalancedData(100), NumInputs: 2, NumOutputs: 2, MaxSamples: 200}
+	defer d.Release()
+	cfg := &Config{MaxEpoch: 50, LearnRate: 0.05, BatchSize: 10, Sampler: "random", Optimizer: "adam", ClassWeights: "auto"}
+	n := New(cfg.BatchSize, d.OutputToClass)
+	n.AddLayer([]int{2}, 8, Linear)
+	n.AddLayer([]int{8}, 2, Relu)
+	n.AddCrossEntropyOutput(2)
+	defer n.Release()
+	n.SetRandomWeights()
+	s := NewStats()
+	s.StartRun()
+	for s.Epoch < cfg.MaxEpoch {
+		n.Train(s, d, cfg)
+		s.Update(n, d)
+	}
+	t.Log(s)
+	t.Log("class weights:", n.classWeights)
+	if len(n.classWeights) != 2 || n.classWeights[1] < 2*n.classWeights[0] {
+		t.Error("expecting higher weight for minority class")
+	}
+	if s.Test.ClassError.Last() > 0.1 {
+		t.Error("failed to learn weighted data")
+	}
+}
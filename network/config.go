@@ -8,23 +8,40 @@ import (
 )
 
 type Config struct {
-	MaxRuns     int     // number of runs: required
-	MaxEpoch    int     // maximum epoch: required
-	LearnRate   float32 // learning rate eta: required
-	WeightDecay float32 // weight decay epsilon
-	Momentum    float32 // momentum term used in weight updates
-	Threshold   float32 // target cost threshold
-	BatchSize   int     // minibatch size
-	StopAfter   int     // stop after n epochs with no improvement
-	LogEvery    int     // log stats every n epochs
-	Sampler     string  // sampler to use
-	Distortion  float32 // distortion severity
+	MaxRuns      int     // number of runs: required
+	MaxEpoch     int     // maximum epoch: required
+	LearnRate    float32 // learning rate eta: required
+	WeightDecay  float32 // weight decay epsilon
+	Momentum     float32 // momentum term used in weight updates
+	Threshold    float32 // target cost threshold
+	BatchSize    int     // minibatch size
+	StopAfter    int     // stop after n epochs with no improvement
+	LogEvery     int     // log stats every n epochs
+	Sampler      string  // sampler to use
+	Optimizer    string  // optimizer to use: default is sgd
+	LRSchedule   string  // learning rate schedule: default is constant
+	LRDecay      float32 // decay factor for step, exp and plateau schedules
+	LRStep       int     // epochs between steps, cosine restart period or plateau patience
+	LRMin        float32 // minimum learning rate for cosine schedule
+	LRPerBatch   bool    // update learning rate after each batch rather than each epoch
+	Warmup       int     // number of epochs for linear warmup of learning rate
+	WeightInit   string  // default weight initialiser: default is lecun
+	BiasInit     float32 // default initial value for bias weights
+	Distortion   float32 // distortion severity
+	ClassWeights string  // class weights for the cost: comma separated list or auto for inverse frequency
+	TopK         int     // k for top-k accuracy and mean reciprocal rank: 0 to disable
+	ROC          bool    // calculate ROC and precision-recall curves for a binary classifier
 }
 
 func (c *Config) Print() {
 	config.Print(c)
 }
 
+// Initialiser method returns the default weight initialiser from the config.
+func (c *Config) Initialiser() Initialiser {
+	return Initialiser{Weights: c.WeightInit, Bias: c.BiasInit}
+}
+
 // Data sets function lists all the registered models.
 func DataSets() (s []string) {
 	for name := range register {
@@ -34,7 +51,8 @@ func DataSets() (s []string) {
 	return s
 }
 
-// Load function loads a data set, creates the network and returns the default config
+// Load function loads a data set, creates the network and returns the default config.
+// If there is a network architecture file for the model then this is used to build the network.
 func Load(name string, samples int) (cfg *Config, net *Network, d *Dataset, err error) {
 	loader, ok := register[name]
 	if !ok {
@@ -43,18 +61,38 @@ func Load(name string, samples int) (cfg *Config, net *Network, d *Dataset, err
 	}
 	cfg = loader.Config()
 	config.Load(cfg, name)
+	arch, err := LoadArchitecture(name)
+	if err != nil {
+		return
+	}
 	if d, err = loader.Load(samples); err != nil {
 		return
 	}
 	d.Load = loader
-	net = loader.CreateNetwork(cfg, d)
+	if arch != nil {
+		batch := cfg.BatchSize
+		if batch == 0 {
+			batch = d.MaxSamples
+		}
+		if net, err = arch.Build(batch, d); err != nil {
+			d.Release()
+			d = nil
+			return
+		}
+	} else {
+		net = loader.CreateNetwork(cfg, d)
+	}
+	net.SetInitialiser(cfg.Initialiser())
 	return
 }
 
 // Stop criteria function returns a function to check if training is complete.
+// The history of previous costs is kept in the stats so it is saved with a snapshot.
 func StopCriteria(cfg *Config) func(*Stats) (done, failed bool) {
-	prevCost := vec.NewBuffer(cfg.StopAfter)
 	return func(s *Stats) (done, failed bool) {
+		if s.PrevCost == nil {
+			s.PrevCost = vec.NewBuffer(cfg.StopAfter)
+		}
 		var cost float32
 		if s.Valid.Error.Len() > 0 {
 			cost = s.Valid.Error.Last()
@@ -68,9 +106,9 @@ func StopCriteria(cfg *Config) func(*Stats) (done, failed bool) {
 			done = true
 		} else if cfg.StopAfter > 0 {
 			if s.Epoch > cfg.StopAfter {
-				done = cost > prevCost.Max()
+				done = cost > s.PrevCost.Max()
 			}
-			prevCost.Push(cost)
+			s.PrevCost.Push(cost)
 		}
 		if cfg.LogEvery > 0 && (s.Epoch%cfg.LogEvery == 0 || done) {
 			fmt.Println(s)
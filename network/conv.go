@@ -0,0 +1,116 @@
+package network
+
+import (
+	"fmt"
+	"github.com/jnb666/deepthought/blas"
+)
+
+type convLayer struct {
+	dims     []int
+	conv     blas.ConvDims
+	activ    Activation
+	input    blas.Matrix // Z matrix of value at each node [samples, nin]
+	output   blas.Matrix // return value at each node [samples, nout]
+	weights  blas.Matrix // W filter weights with bias in last column [filters, kernel*kernel*channels+1]
+	gradient blas.Matrix // G gradient of weight matrix [filters, kernel*kernel*channels+1]
+	deriv    blas.Matrix // Fp matrix of derivative of activation fn [samples, nin]
+	delta    blas.Matrix // D matrix of errors at each node [samples, nin]
+}
+
+// AddConvLayer method adds a new 2D convolutional layer to the network.
+// inDims are the input dimensions as [height, width] or [height, width, channels].
+// Returns the output dimensions in the same format which can be used to size the next layer.
+// An optional initialiser may be given to override the network default for this layer.
+func (n *Network) AddConvLayer(inDims []int, filters, kernelSize, stride, padding int, a Activation, init ...Initialiser) (outDims []int) {
+	d := convDims(inDims, filters, kernelSize, stride, padding)
+	batch := n.BatchSize
+	nin, nout, nweight := d.InSize(), d.OutSize(), d.KernelSize()+1
+	l := &convLayer{
+		dims:     inDims,
+		conv:     d,
+		activ:    a,
+		input:    blas.New(batch, nin),
+		output:   blas.New(batch, nout),
+		weights:  blas.New(filters, nweight),
+		gradient: blas.New(filters, nweight),
+	}
+	if a.Deriv != nil {
+		l.deriv = blas.New(batch, nin)
+	}
+	if n.Layers > 0 {
+		l.delta = blas.New(batch, nin)
+	}
+	n.addInit(init)
+	n.add(l)
+	return []int{d.OutHeight(), d.OutWidth(), filters}
+}
+
+func convDims(inDims []int, filters, kernelSize, stride, padding int) blas.ConvDims {
+	if len(inDims) < 2 || len(inDims) > 3 {
+		panic(fmt.Sprintf("convolution layer: invalid input dims %v", inDims))
+	}
+	d := blas.ConvDims{
+		Height:   inDims[0],
+		Width:    inDims[1],
+		Channels: 1,
+		Filters:  filters,
+		Kernel:   kernelSize,
+		Stride:   stride,
+		Pad:      padding,
+	}
+	if len(inDims) == 3 {
+		d.Channels = inDims[2]
+	}
+	if d.Stride < 1 || d.OutWidth() < 1 || d.OutHeight() < 1 {
+		panic(fmt.Sprintf("convolution layer: invalid dims %s", d))
+	}
+	return d
+}
+
+func (l *convLayer) Dims() []int {
+	return l.dims
+}
+
+func (l *convLayer) Values() blas.Matrix {
+	return l.input
+}
+
+func (l *convLayer) Release() {
+	l.input.Release()
+	l.output.Release()
+	l.weights.Release()
+	l.gradient.Release()
+	if l.deriv != nil {
+		l.deriv.Release()
+	}
+	if l.delta != nil {
+		l.delta.Release()
+	}
+}
+
+func (l *convLayer) Weights() blas.Matrix { return l.weights }
+
+func (l *convLayer) Gradient() blas.Matrix { return l.gradient }
+
+func (l *convLayer) Cost(t blas.Matrix) blas.Matrix { panic("no cost for convolution layer!") }
+
+func (l *convLayer) FeedForward(in blas.Matrix) blas.Matrix {
+	l.activ.Func.Apply(in, l.input)
+	if l.activ.Deriv != nil {
+		l.activ.Deriv.Apply(in, l.deriv)
+	}
+	return l.output.Conv(l.input, l.weights, l.conv)
+}
+
+func (l *convLayer) BackProp(err blas.Matrix) blas.Matrix {
+	// calculate the gradient
+	l.gradient.ConvGradWeights(err, l.input, l.conv)
+	// propagate error backward
+	if l.delta != nil {
+		l.delta.ConvGradInput(err, l.weights, l.conv)
+		if l.deriv != nil {
+			l.delta.MulElem(l.delta, l.deriv)
+		}
+	}
+	return l.delta
+}
@@ -0,0 +1,91 @@
+package network
+
+import (
+	"github.com/jnb666/deepthought/blas"
+	"math/rand"
+	"testing"
+)
+
+// run back propagation on a single batch and compare the gradients with numerical estimates
+func checkGradient(t *testing.T, n *Network, input, target blas.Matrix, maxError float32) {
+	checkGradientScale(t, n, input, target, maxError, 0.5)
+}
+
+// gradient check with cost scale factor which is 0.5 for quadratic cost as the factor of 2 is dropped
+func checkGradientScale(t *testing.T, n *Network, input, target blas.Matrix, maxError, scale float32) {
+	n.SetRandomWeights()
+	n.CheckGradient(1, maxError, 0, scale)
+	n.FeedForward(input)
+	delta := n.Nodes[n.Layers-1].BackProp(target)
+	for i := n.Layers - 2; i >= 0; i-- {
+		layer := n.Nodes[i]
+		delta = layer.BackProp(delta)
+		if g := layer.Gradient(); g != nil {
+			g.Scale(-1 / float32(input.Rows()))
+		}
+	}
+	if !n.doCheck(input, target) {
+		t.Error("gradient check failed")
+	}
+}
+
+func randMatrix(rows, cols int) blas.Matrix {
+	data := make([]float32, rows*cols)
+	for i := range data {
+		data[i] = rand.Float32()
+	}
+	return blas.New(rows, cols).Load(blas.RowMajor, data...)
+}
+
+func TestConvGradient(t *testing.T) {
+	rand.Seed(1)
+	batch := 4
+	n := New(batch, nil)
+	dims := n.AddConvLayer([]int{6, 6}, 2, 3, 1, 1, Linear)
+	if dims[0] != 6 || dims[1] != 6 || dims[2] != 2 {
+		t.Fatal("wrong output dims", dims)
+	}
+	dims = n.AddConvLayer(dims, 3, 3, 2, 0, Sigmoid)
+	if dims[0] != 2 || dims[1] != 2 || dims[2] != 3 {
+		t.Fatal("wrong output dims", dims)
+	}
+	n.AddLayer(dims, 3, Sigmoid)
+	n.AddQuadraticOutput(3, Sigmoid)
+	defer n.Release()
+	checkGradient(t, n, randMatrix(batch, 36), randMatrix(batch, 3), 1e-4)
+}
+
+func TestPoolGradient(t *testing.T) {
+	rand.Seed(1)
+	batch := 4
+	for _, max := range []bool{true, false} {
+		n := New(batch, nil)
+		dims := n.AddConvLayer([]int{8, 8}, 2, 3, 1, 0, Linear)
+		if max {
+			dims = n.AddMaxPoolLayer(dims, 2, 2, Sigmoid)
+		} else {
+			dims = n.AddAvgPoolLayer(dims, 3, 1, Sigmoid)
+		}
+		t.Log("max pooling:", max, "output dims:", dims)
+		n.AddLayer(dims, 3, Linear)
+		n.AddQuadraticOutput(3, Sigmoid)
+		// allow for the max changing position when weights are perturbed
+		checkGradient(t, n, randMatrix(batch, 64), randMatrix(batch, 3), 1e-3)
+		n.Release()
+	}
+}
+
+func TestNative64Gradient(t *testing.T) {
+	defer Init(blas.Implementation())
+	Init(blas.Native64)
+	rand.Seed(1)
+	batch := 4
+	n := New(batch, nil)
+	dims := n.AddConvLayer([]int{6, 6}, 2, 3, 1, 1, Linear)
+	dims = n.AddAvgPoolLayer(dims, 2, 2, Tanh)
+	n.AddLayer(dims, 3, Sigmoid)
+	n.AddQuadraticOutput(3, Sigmoid)
+	defer n.Release()
+	// only limited by the finite difference approximation and rounding of the cost to float32
+	checkGradient(t, n, randMatrix(batch, 36), randMatrix(batch, 3), 1e-5)
+}
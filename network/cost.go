@@ -0,0 +1,211 @@
+package network
+
+import (
+	"fmt"
+	"github.com/jnb666/deepthought/blas"
+	"math"
+)
+
+// Cost interface type represents the cost function used by an output layer. Func returns the cost for each
+// output given the output value and the target. Deriv returns the derivative of the cost with respect to the
+// output value, or nil if the error at the input to the output activation is simply output - target, as is
+// the case for the cross entropy costs with their matching activation.
+type Cost interface {
+	Name() string
+	Func() blas.BinaryFunction
+	Deriv() blas.BinaryFunction
+}
+
+// CostNames lists the costs which can be used with any output activation.
+var CostNames = []string{"huber", "hinge", "squaredhinge", "logcosh", "mae"}
+
+type costFunc struct {
+	name  string
+	delta float32 // threshold for huber cost
+	fn    blas.BinaryFunction
+	deriv blas.BinaryFunction
+}
+
+func (c costFunc) Name() string { return c.name }
+
+func (c costFunc) Func() blas.BinaryFunction { return c.fn }
+
+func (c costFunc) Deriv() blas.BinaryFunction { return c.deriv }
+
+// new cost given the OpenCL source with inputs x and y which sets z and the equivalent Go functions
+func newCost(name string, delta float32, clFn, clDeriv string, fn, deriv func(x, y float64) float64) costFunc {
+	c := costFunc{name: name, delta: delta}
+	if blas.Implementation() == blas.OpenCL32 {
+		c.fn, c.deriv = blas.NewBinaryCL(clFn), blas.NewBinaryCL(clDeriv)
+	} else if blas.Implementation() == blas.Native64 {
+		c.fn, c.deriv = blas.Binary64(fn), blas.Binary64(deriv)
+	} else {
+		c.fn, c.deriv = binary32(fn), binary32(deriv)
+	}
+	return c
+}
+
+func binary32(fn func(x, y float64) float64) blas.Binary32 {
+	return func(x, y float32) float32 { return float32(fn(float64(x), float64(y))) }
+}
+
+// get cost by name, delta is only used for the huber cost
+func costFunction(name string, delta float32) (Cost, error) {
+	switch name {
+	case "quadratic":
+		return QuadraticCost(), nil
+	case "crossentropy":
+		return CrossEntropyCost(), nil
+	case "binarycrossentropy":
+		return BinaryCrossEntropyCost(), nil
+	case "huber":
+		if delta <= 0 {
+			return nil, fmt.Errorf("huber cost delta must be greater than zero")
+		}
+		return HuberCost(delta), nil
+	case "hinge":
+		return HingeCost(), nil
+	case "squaredhinge":
+		return SquaredHingeCost(), nil
+	case "logcosh":
+		return LogCoshCost(), nil
+	case "mae":
+		return MAECost(), nil
+	}
+	return nil, fmt.Errorf("cost function %q not found", name)
+}
+
+// QuadraticCost function returns the squared error cost. The factor of 2 is dropped from the derivative.
+func QuadraticCost() Cost {
+	c := costFunc{name: "quadratic"}
+	if blas.Implementation() == blas.OpenCL32 {
+		c.fn = blas.NewBinaryCL("float z = (x-y)*(x-y);")
+	} else if blas.Implementation() == blas.Native64 {
+		c.fn = blas.Binary64(func(out, tgt float64) float64 { return (out - tgt) * (out - tgt) })
+	} else {
+		c.fn = blas.Binary32(func(out, tgt float32) float32 { return (out - tgt) * (out - tgt) })
+	}
+	return c
+}
+
+// CrossEntropyCost function returns the cross entropy cost for use with softmax activation.
+func CrossEntropyCost() Cost {
+	c := costFunc{name: "crossentropy"}
+	if blas.Implementation() == blas.OpenCL32 {
+		c.fn = blas.NewBinaryCL("float z = -y * log(max(x, 1e-10f));")
+	} else if blas.Implementation() == blas.Native64 {
+		c.fn = blas.Binary64(func(out, tgt float64) float64 {
+			return -tgt * math.Log(math.Max(out, 1e-300))
+		})
+	} else {
+		c.fn = blas.Binary32(func(out, tgt float32) float32 {
+			if out < 1e-10 {
+				out = 1e-10
+			}
+			return -tgt * float32(math.Log(float64(out)))
+		})
+	}
+	return c
+}
+
+// BinaryCrossEntropyCost function returns the binary cross entropy cost for use with sigmoid activation.
+func BinaryCrossEntropyCost() Cost {
+	c := costFunc{name: "binarycrossentropy"}
+	if blas.Implementation() == blas.OpenCL32 {
+		c.fn = blas.NewBinaryCL("float z = -y * log(max(x, 1e-10f)) - (1.f-y) * log(max(1.f-x, 1e-10f));")
+	} else if blas.Implementation() == blas.Native64 {
+		c.fn = blas.Binary64(func(out, tgt float64) float64 {
+			return -tgt*math.Log(math.Max(out, 1e-300)) - (1-tgt)*math.Log(math.Max(1-out, 1e-300))
+		})
+	} else {
+		c.fn = blas.Binary32(func(out, tgt float32) float32 {
+			x := math.Max(float64(out), 1e-10)
+			x1 := math.Max(1-float64(out), 1e-10)
+			return float32(-float64(tgt)*math.Log(x) - (1-float64(tgt))*math.Log(x1))
+		})
+	}
+	return c
+}
+
+// HuberCost function returns the Huber cost which is quadratic for errors up to delta and linear above.
+func HuberCost(delta float32) Cost {
+	d := float64(delta)
+	return newCost("huber", delta,
+		fmt.Sprintf("float d = %ff; float r = fabs(x-y); float z = r <= d ? 0.5f*r*r : d*(r-0.5f*d);", delta),
+		fmt.Sprintf("float z = clamp(x-y, -%ff, %ff);", delta, delta),
+		func(x, y float64) float64 {
+			if r := math.Abs(x - y); r > d {
+				return d * (r - 0.5*d)
+			}
+			return 0.5 * (x - y) * (x - y)
+		},
+		func(x, y float64) float64 {
+			return math.Max(-d, math.Min(x-y, d))
+		})
+}
+
+// HingeCost function returns the hinge cost max(0, 1-t*x) where the target t is 2*y-1,
+// so that 0 or 1 targets are mapped to -1 or +1.
+func HingeCost() Cost {
+	return newCost("hinge", 0,
+		"float t = 2.f*y-1.f; float z = max(0.f, 1.f-t*x);",
+		"float t = 2.f*y-1.f; float z = t*x < 1.f ? -t : 0.f;",
+		func(x, y float64) float64 {
+			return math.Max(0, 1-(2*y-1)*x)
+		},
+		func(x, y float64) float64 {
+			if t := 2*y - 1; t*x < 1 {
+				return -t
+			}
+			return 0
+		})
+}
+
+// SquaredHingeCost function returns the square of the hinge cost.
+func SquaredHingeCost() Cost {
+	return newCost("squaredhinge", 0,
+		"float t = 2.f*y-1.f; float m = max(0.f, 1.f-t*x); float z = m*m;",
+		"float t = 2.f*y-1.f; float z = -2.f*t*max(0.f, 1.f-t*x);",
+		func(x, y float64) float64 {
+			m := math.Max(0, 1-(2*y-1)*x)
+			return m * m
+		},
+		func(x, y float64) float64 {
+			t := 2*y - 1
+			return -2 * t * math.Max(0, 1-t*x)
+		})
+}
+
+// LogCoshCost function returns the log of the hyperbolic cosine of the error.
+func LogCoshCost() Cost {
+	// log(cosh(r)) = |r| + log(1 + exp(-2|r|)) - log(2) avoids overflow for large errors
+	return newCost("logcosh", 0,
+		"float r = fabs(x-y); float z = r + log(1.f+exp(-2.f*r)) - log(2.f);",
+		"float z = tanh(x-y);",
+		func(x, y float64) float64 {
+			r := math.Abs(x - y)
+			return r + math.Log1p(math.Exp(-2*r)) - math.Ln2
+		},
+		func(x, y float64) float64 {
+			return math.Tanh(x - y)
+		})
+}
+
+// MAECost function returns the absolute error cost.
+func MAECost() Cost {
+	return newCost("mae", 0,
+		"float z = fabs(x-y);",
+		"float z = sign(x-y);",
+		func(x, y float64) float64 {
+			return math.Abs(x - y)
+		},
+		func(x, y float64) float64 {
+			switch {
+			case x > y:
+				return 1
+			case x < y:
+				return -1
+			}
+			return 0
+		})
+}
@@ -0,0 +1,83 @@
+package network
+
+import (
+	"github.com/jnb666/deepthought/blas"
+	"github.com/jnb666/deepthought/vec"
+	"math/rand"
+	"testing"
+)
+
+func TestCostFunctions(t *testing.T) {
+	out := blas.New(1, 4).Load(blas.RowMajor, 0.5, -2, 0.2, 1.5)
+	tgt := blas.New(1, 4).Load(blas.RowMajor, 0, 1, 1, 1)
+	res := blas.New(1, 4)
+	defer out.Release()
+	defer tgt.Release()
+	defer res.Release()
+	tests := []struct {
+		cost        Cost
+		value, grad []float32
+	}{
+		{HuberCost(1), []float32{0.125, 2.5, 0.32, 0.125}, []float32{0.5, -1, -0.8, 0.5}},
+		{HingeCost(), []float32{1.5, 3, 0.8, 0}, []float32{1, -1, -1, 0}},
+		{SquaredHingeCost(), []float32{2.25, 9, 0.64, 0}, []float32{3, -6, -1.6, 0}},
+		{LogCoshCost(), []float32{0.120115, 2.309329, 0.290754, 0.120115}, []float32{0.462117, -0.995055, -0.664037, 0.462117}},
+		{MAECost(), []float32{0.5, 3, 0.8, 0.5}, []float32{1, -1, -1, 1}},
+	}
+	for _, test := range tests {
+		value := test.cost.Func().Apply(out, tgt, res).Data(blas.RowMajor)
+		grad := test.cost.Deriv().Apply(out, tgt, res).Data(blas.RowMajor)
+		t.Logf("%s: cost=%v deriv=%v", test.cost.Name(), value, grad)
+		for i := range value {
+			if vec.Abs(value[i]-test.value[i]) > 1e-5 || vec.Abs(grad[i]-test.grad[i]) > 1e-5 {
+				t.Errorf("%s: wrong value for element %d", test.cost.Name(), i)
+			}
+		}
+	}
+}
+
+func TestCostGradient(t *testing.T) {
+	rand.Seed(1)
+	batch := 4
+	// the squared hinge has a step in its second derivative at the margin which adds to the finite
+	// difference error when an output is within epsilon of it
+	maxError := map[string]float32{"squaredhinge": 5e-4}
+	for _, c := range []Cost{HuberCost(0.5), HingeCost(), SquaredHingeCost(), LogCoshCost(), MAECost()} {
+		max := maxError[c.Name()]
+		if max == 0 {
+			max = 1e-4
+		}
+		for _, a := range []Activation{Linear, Tanh, Softmax} {
+			t.Logf("%s cost with %s activation", c.Name(), a.Name)
+			n := New(batch, nil)
+			n.AddLayer([]int{5}, 3, Sigmoid)
+			n.AddOutput(3, a, c)
+			target := randMatrix(batch, 3)
+			NewThreshold(0.5).Apply(target, target)
+			checkGradientScale(t, n, randMatrix(batch, 5), target, max, 1)
+			n.Release()
+		}
+	}
+}
+
+func TestSaveCostModel(t *testing.T) {
+	n := New(4, nil)
+	n.AddLayer([]int{3}, 2, Linear)
+	n.AddOutput(2, Tanh, HuberCost(0.25))
+	defer n.Release()
+	specs, err := n.specs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n2 := New(4, nil)
+	defer n2.Release()
+	for _, s := range specs {
+		if err = n2.addSpec(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l, ok := n2.Nodes[1].(*outLayer)
+	if !ok || l.cost.Name() != "huber" || l.cost.(costFunc).delta != 0.25 || l.activ.Name != "tanh" {
+		t.Error("output layer not restored")
+	}
+}
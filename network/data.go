@@ -39,20 +39,22 @@ func GetLoader(name string) (l Loader, ok bool) {
 // Dataset type represents a set of test, training and validation data
 type Dataset struct {
 	Load          Loader
-	OutputToClass blas.UnaryFunction
+	OutputToClass blas.UnaryFunction // nil for regression
 	Test          *Data
 	Train         *Data
 	Valid         *Data
 	NumInputs     int
 	NumOutputs    int
 	MaxSamples    int
+	InputDims     []int // dimensions of each input sample if not one dimensional
 }
 
 // Data type represents a set of test or training data.
 type Data struct {
 	Input      blas.Matrix
 	Output     blas.Matrix
-	Classes    blas.Matrix
+	Classes    blas.Matrix // nil for regression
+	Weights    blas.Matrix // optional weight for each sample [samples, 1]
 	NumSamples int
 }
 
@@ -80,11 +82,17 @@ func (d *Dataset) Release() {
 func (d *Data) Release() {
 	d.Input.Release()
 	d.Output.Release()
-	d.Classes.Release()
+	if d.Classes != nil {
+		d.Classes.Release()
+	}
+	if d.Weights != nil {
+		d.Weights.Release()
+	}
 }
 
 // LoadFile function reads a dataset from a text file.
 // samples is maxiumum number of records to load from each dataset if non-zero.
+// If out2class is nil then the classes are not set, as for a regression dataset.
 func LoadFile(filename string, samples int, out2class blas.UnaryFunction) (d *Data, nin, nout int, err error) {
 	var file *os.File
 	if file, err = os.Open(filename); err != nil {
@@ -116,7 +124,10 @@ func LoadFile(filename string, samples int, out2class blas.UnaryFunction) (d *Da
 	// format as matrix
 	d.Input = blas.New(rows, nin).Load(blas.ColMajor, buf...)
 	d.Output = blas.New(rows, nout).Load(blas.ColMajor, buf[rows*nin:]...)
-	d.Classes = blas.New(rows, 1)
-	out2class.Apply(d.Output, d.Classes)
+	if out2class != nil {
+		// multi-label classifiers have one class per output
+		d.Classes = blas.New(rows, nout)
+		out2class.Apply(d.Output, d.Classes)
+	}
 	return
 }
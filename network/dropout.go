@@ -0,0 +1,135 @@
+package network
+
+import (
+	"fmt"
+	"github.com/jnb666/deepthought/blas"
+)
+
+// layers with different behaviour in training and inference mode
+type trainingLayer interface {
+	// if fixed is set then the current random mask is reused
+	setTraining(on, fixed bool)
+}
+
+type dropoutLayer struct {
+	dims     []int
+	rate     float32
+	training bool
+	fixed    bool
+	activ    Activation
+	maskFn   blas.UnaryFunction
+	input    blas.Matrix // Z matrix of value at each node [samples, nin]
+	output   blas.Matrix // return value at each node [samples, nin]
+	mask     blas.Matrix // M scaled dropout mask [samples, nin]
+	deriv    blas.Matrix // Fp matrix of derivative of activation fn [samples, nin]
+	delta    blas.Matrix // D matrix of errors at each node [samples, nin]
+}
+
+// AddDropoutLayer method adds a dropout layer to the network which randomly zeros a fraction rate of
+// its inputs while training. Remaining values are scaled by 1/(1-rate) so no scaling is needed at inference time.
+// Output dimensions are the same as the input dimensions.
+func (n *Network) AddDropoutLayer(dims []int, rate float32, a Activation) {
+	if rate < 0 || rate >= 1 {
+		panic(fmt.Sprintf("dropout layer: rate %g must be in range [0,1)", rate))
+	}
+	batch := n.BatchSize
+	nin := 1
+	for _, n := range dims {
+		nin *= n
+	}
+	l := &dropoutLayer{
+		dims:   dims,
+		rate:   rate,
+		activ:  a,
+		input:  blas.New(batch, nin),
+		output: blas.New(batch, nin),
+		mask:   blas.New(batch, nin),
+	}
+	scale := 1 / (1 - rate)
+	if blas.Implementation() == blas.OpenCL32 {
+		l.maskFn = blas.NewUnaryCL(fmt.Sprintf("float y = x >= %gf ? %gf : 0.f;", rate, scale))
+	} else if blas.Implementation() == blas.Native64 {
+		l.maskFn = blas.Unary64(func(x float64) float64 {
+			if x >= float64(rate) {
+				return float64(scale)
+			}
+			return 0
+		})
+	} else {
+		l.maskFn = blas.Unary32(func(x float32) float32 {
+			if x >= rate {
+				return scale
+			}
+			return 0
+		})
+	}
+	if a.Deriv != nil {
+		l.deriv = blas.New(batch, nin)
+	}
+	if n.Layers > 0 {
+		l.delta = blas.New(batch, nin)
+	}
+	n.add(l)
+}
+
+func (l *dropoutLayer) Dims() []int {
+	return l.dims
+}
+
+func (l *dropoutLayer) Values() blas.Matrix {
+	return l.input
+}
+
+func (l *dropoutLayer) Release() {
+	l.input.Release()
+	l.output.Release()
+	l.mask.Release()
+	if l.deriv != nil {
+		l.deriv.Release()
+	}
+	if l.delta != nil {
+		l.delta.Release()
+	}
+}
+
+func (l *dropoutLayer) Weights() blas.Matrix { return nil }
+
+func (l *dropoutLayer) Gradient() blas.Matrix { return nil }
+
+func (l *dropoutLayer) Cost(t blas.Matrix) blas.Matrix { panic("no cost for dropout layer!") }
+
+func (l *dropoutLayer) setTraining(on, fixed bool) {
+	l.training = on
+	l.fixed = fixed
+}
+
+func (l *dropoutLayer) FeedForward(in blas.Matrix) blas.Matrix {
+	l.activ.Func.Apply(in, l.input)
+	if l.activ.Deriv != nil {
+		l.activ.Deriv.Apply(in, l.deriv)
+	}
+	if !l.training {
+		return l.output.Copy(l.input, nil)
+	}
+	if !l.fixed {
+		l.mask.Reshape(in.Rows(), in.Cols(), false)
+		l.mask.Random(0, 1)
+		l.maskFn.Apply(l.mask, l.mask)
+	}
+	return l.output.MulElem(l.input, l.mask)
+}
+
+func (l *dropoutLayer) BackProp(err blas.Matrix) blas.Matrix {
+	if l.delta == nil {
+		return nil
+	}
+	if l.training {
+		l.delta.MulElem(err, l.mask)
+	} else {
+		l.delta.Copy(err, nil)
+	}
+	if l.deriv != nil {
+		l.delta.MulElem(l.delta, l.deriv)
+	}
+	return l.delta
+}
@@ -0,0 +1,62 @@
+package network
+
+import (
+	"github.com/jnb666/deepthought/blas"
+	"github.com/jnb666/deepthought/vec"
+	"math/rand"
+	"testing"
+)
+
+func TestDropout(t *testing.T) {
+	rand.Seed(1)
+	batch, nin := 50, 40
+	n := New(batch, nil)
+	n.AddDropoutLayer([]int{nin}, 0.25, Linear)
+	n.AddQuadraticOutput(nin, Linear)
+	defer n.Release()
+	input := randMatrix(batch, nin)
+	// no masking in inference mode
+	output := n.FeedForward(input).Data(blas.RowMajor)
+	checkEqual(t, output, input.Data(blas.RowMajor))
+	// inverted dropout in training mode
+	n.SetTraining(true)
+	output = n.FeedForward(input).Data(blas.RowMajor)
+	zeros := 0
+	for i, x := range input.Data(blas.RowMajor) {
+		if output[i] == 0 {
+			zeros++
+		} else if vec.Abs(output[i]-x/0.75) > 1e-5 {
+			t.Fatalf("expected %g got %g", x/0.75, output[i])
+		}
+	}
+	frac := float32(zeros) / float32(batch*nin)
+	t.Logf("dropped fraction = %.3f", frac)
+	if frac < 0.2 || frac > 0.3 {
+		t.Error("wrong fraction of values dropped")
+	}
+	if prev := n.SetTraining(false); !prev {
+		t.Error("expected network to be in training mode")
+	}
+	checkEqual(t, n.FeedForward(input).Data(blas.RowMajor), input.Data(blas.RowMajor))
+}
+
+func TestDropoutGradient(t *testing.T) {
+	rand.Seed(1)
+	batch := 4
+	n := New(batch, nil)
+	n.AddLayer([]int{6}, 8, Linear)
+	n.AddDropoutLayer([]int{8}, 0.5, Sigmoid)
+	n.AddLayer([]int{8}, 3, Linear)
+	n.AddQuadraticOutput(3, Sigmoid)
+	defer n.Release()
+	n.SetTraining(true)
+	checkGradient(t, n, randMatrix(batch, 6), randMatrix(batch, 3), 1e-4)
+}
+
+func checkEqual(t *testing.T, got, expect []float32) {
+	for i := range expect {
+		if got[i] != expect[i] {
+			t.Fatalf("value %d: expected %g got %g", i, expect[i], got[i])
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package network
+
+import (
+	"fmt"
+	"github.com/jnb666/deepthought/blas"
+)
+
+// Embedding layer where each input is an integer index in the range [0, vocab) which selects a row of a
+// learned table. The table is stored as a weight matrix with an unused bias column so that it is treated in
+// the same way as the other layers by the initialisers, weight decay and optimizers.
+type embeddingLayer struct {
+	dims     []int
+	vocab    int
+	size     int
+	input    blas.Matrix // I matrix of input indices [samples, nin]
+	output   blas.Matrix // return value at each node [samples, nin*size]
+	weights  blas.Matrix // W lookup table with bias in last column [vocab, size+1]
+	gradient blas.Matrix // G gradient of lookup table [vocab, size+1]
+}
+
+// AddEmbeddingLayer method adds an embedding layer which maps each of the nin integer coded inputs to a
+// vector of the given size. It must be the first layer in the network. Returns the output dimensions which
+// are [size] if there is one input or [nin, size] otherwise, so a sequence of indices may be fed to a
+// recurrent layer. An optional initialiser may be given to override the network default for this layer.
+func (n *Network) AddEmbeddingLayer(nin, vocab, size int, init ...Initialiser) (outDims []int) {
+	if n.Layers > 0 {
+		panic("embedding layer: must be the first layer")
+	}
+	if nin < 1 || vocab < 1 || size < 1 {
+		panic(fmt.Sprintf("embedding layer: invalid dims inputs=%d vocab=%d size=%d", nin, vocab, size))
+	}
+	batch := n.BatchSize
+	l := &embeddingLayer{
+		dims:     []int{nin},
+		vocab:    vocab,
+		size:     size,
+		input:    blas.New(batch, nin),
+		output:   blas.New(batch, nin*size),
+		weights:  blas.New(vocab, size+1),
+		gradient: blas.New(vocab, size+1),
+	}
+	n.addInit(init)
+	n.add(l)
+	if nin == 1 {
+		return []int{size}
+	}
+	return []int{nin, size}
+}
+
+// check that each input is a valid index into the table
+func (l *embeddingLayer) checkInput(in blas.Matrix) error {
+	for i, x := range in.Data(blas.RowMajor) {
+		if int(x) < 0 || int(x) >= l.vocab {
+			return fmt.Errorf("embedding layer: input %d is %g, expecting an index from 0 to %d", i, x, l.vocab-1)
+		}
+	}
+	return nil
+}
+
+func (l *embeddingLayer) Dims() []int {
+	return l.dims
+}
+
+func (l *embeddingLayer) Values() blas.Matrix {
+	return l.input
+}
+
+func (l *embeddingLayer) Release() {
+	l.input.Release()
+	l.output.Release()
+	l.weights.Release()
+	l.gradient.Release()
+}
+
+func (l *embeddingLayer) Weights() blas.Matrix { return l.weights }
+
+func (l *embeddingLayer) Gradient() blas.Matrix { return l.gradient }
+
+func (l *embeddingLayer) Cost(t blas.Matrix) blas.Matrix { panic("no cost for embedding layer!") }
+
+func (l *embeddingLayer) FeedForward(in blas.Matrix) blas.Matrix {
+	l.input.Copy(in, nil)
+	l.output.Reshape(in.Rows(), l.output.Cols(), false)
+	table := l.weights.Col(0, l.size)
+	for i := 0; i < l.dims[0]; i++ {
+		l.output.Col(i*l.size, (i+1)*l.size).Copy(table, l.input.Col(i, i+1))
+	}
+	return l.output
+}
+
+func (l *embeddingLayer) BackProp(err blas.Matrix) blas.Matrix {
+	// scatter the error back to the table rows which were selected, there is no error for the inputs
+	l.gradient.Set(0)
+	grad := l.gradient.Col(0, l.size)
+	for i := 0; i < l.dims[0]; i++ {
+		grad.AddRows(err.Col(i*l.size, (i+1)*l.size), l.input.Col(i, i+1))
+	}
+	return nil
+}
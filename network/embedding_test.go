@@ -0,0 +1,110 @@
+package network
+
+import (
+	"bytes"
+	"github.com/jnb666/deepthought/blas"
+	"github.com/jnb666/deepthought/vec"
+	"math/rand"
+	"testing"
+)
+
+func randIndex(rows, cols, vocab int) blas.Matrix {
+	data := make([]float32, rows*cols)
+	for i := range data {
+		data[i] = float32(rand.Intn(vocab))
+	}
+	return blas.New(rows, cols).Load(blas.RowMajor, data...)
+}
+
+func TestEmbedding(t *testing.T) {
+	rand.Seed(1)
+	batch, nin, vocab, size := 6, 3, 5, 2
+	n := New(batch, nil)
+	dims := n.AddEmbeddingLayer(nin, vocab, size)
+	if len(dims) != 2 || dims[0] != nin || dims[1] != size {
+		t.Fatal("wrong output dims", dims)
+	}
+	n.AddQuadraticOutput(nin*size, Linear)
+	defer n.Release()
+	n.SetRandomWeights()
+	input := randIndex(batch, nin, vocab)
+	output := n.FeedForward(input).Data(blas.RowMajor)
+	table := n.Nodes[0].Weights().Data(blas.RowMajor)
+	for i, ix := range input.Data(blas.RowMajor) {
+		row := int(ix)
+		checkEqual(t, output[i*size:(i+1)*size], table[row*(size+1):row*(size+1)+size])
+	}
+	// gradient for each table row is the sum of the errors where it was selected
+	delta := randMatrix(batch, nin*size)
+	if n.Nodes[0].BackProp(delta) != nil {
+		t.Error("expected nil input error")
+	}
+	expect := make([]float32, vocab*(size+1))
+	deltaData := delta.Data(blas.RowMajor)
+	for i, ix := range input.Data(blas.RowMajor) {
+		for j := 0; j < size; j++ {
+			expect[int(ix)*(size+1)+j] += deltaData[i*size+j]
+		}
+	}
+	for i, x := range n.Nodes[0].Gradient().Data(blas.RowMajor) {
+		if vec.Abs(x-expect[i]) > 1e-6 {
+			t.Fatalf("gradient %d: expected %g got %g", i, expect[i], x)
+		}
+	}
+}
+
+func TestEmbeddingGradient(t *testing.T) {
+	rand.Seed(1)
+	batch, steps, vocab := 8, 4, 6
+	n := New(batch, nil)
+	dims := n.AddEmbeddingLayer(steps, vocab, 3)
+	dims = n.AddRNNLayer(dims, 4, RecurrentOpts{}, Linear)
+	n.AddLayer(dims, 2, Linear)
+	n.AddQuadraticOutput(2, Sigmoid)
+	defer n.Release()
+	checkGradient(t, n, randIndex(batch, steps, vocab), randMatrix(batch, 2), 1e-4)
+}
+
+func TestSaveEmbeddingModel(t *testing.T) {
+	rand.Seed(1)
+	n := New(4, MaxCol{})
+	n.AddEmbeddingLayer(1, 10, 3)
+	n.AddCrossEntropyOutput(3)
+	defer n.Release()
+	n.SetRandomWeights()
+	input := randIndex(4, 1, 10)
+	expect := n.FeedForward(input).Data(blas.RowMajor)
+	var buf bytes.Buffer
+	if err := n.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	n2, err := LoadModel(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n2.Release()
+	checkEqual(t, n2.FeedForward(input).Data(blas.RowMajor), expect)
+}
+
+func TestEmbeddingCheckInputs(t *testing.T) {
+	n := New(2, nil)
+	n.AddEmbeddingLayer(2, 4, 3)
+	n.AddQuadraticOutput(6, Linear)
+	defer n.Release()
+	d := &Data{Input: blas.New(2, 2).Load(blas.RowMajor, 0, 1, 3, 2)}
+	defer d.Input.Release()
+	if err := n.CheckInputs(d); err != nil {
+		t.Error(err)
+	}
+	bad := &Data{Input: blas.New(2, 2).Load(blas.RowMajor, 0, 4, 1, 2)}
+	defer bad.Input.Release()
+	err := n.CheckInputs(bad)
+	t.Log(err)
+	if err == nil {
+		t.Error("expected error for index out of range")
+	}
+	bad.Input.Load(blas.RowMajor, 0, -1, 1, 2)
+	if n.CheckInputs(bad) == nil {
+		t.Error("expected error for negative index")
+	}
+}
@@ -0,0 +1,136 @@
+package network
+
+import (
+	"github.com/jnb666/deepthought/blas"
+	"math"
+	"math/rand"
+)
+
+var initialisers = map[string]func(data []float32, nin, nout int){
+	"lecun":          lecunInit,
+	"lecun_uniform":  lecunUniformInit,
+	"xavier":         xavierInit,
+	"xavier_uniform": xavierUniformInit,
+	"he":             heInit,
+	"he_uniform":     heUniformInit,
+	"orthogonal":     orthogonalInit,
+}
+
+var InitNames = []string{"lecun", "lecun_uniform", "xavier", "xavier_uniform", "he", "he_uniform", "orthogonal"}
+
+// Initialiser type specifies how the weights for a layer are set at the start of each run.
+// Random values are generated using math/rand so are reproducible from blas.SeedRandom.
+type Initialiser struct {
+	Weights string  // name of weight initialiser: default is lecun
+	Bias    float32 // constant value for bias weights
+}
+
+// set weights matrix w with nout rows and nin+1 columns where the bias is in the last column
+func (i Initialiser) init(w blas.Matrix) {
+	typ := i.Weights
+	if typ == "" {
+		typ = "lecun"
+	}
+	fn, ok := initialisers[typ]
+	if !ok {
+		panic("initialiser of type " + typ + " not found")
+	}
+	nin, nout := w.Cols()-1, w.Rows()
+	data := make([]float32, (nin+1)*nout)
+	fn(data[:nin*nout], nin, nout)
+	for j := nin * nout; j < len(data); j++ {
+		data[j] = i.Bias
+	}
+	w.Load(blas.ColMajor, data...)
+}
+
+func normal(data []float32, stddev float64) {
+	for i := range data {
+		data[i] = float32(rand.NormFloat64() * stddev)
+	}
+}
+
+func uniform(data []float32, limit float64) {
+	for i := range data {
+		data[i] = float32(limit * (2*rand.Float64() - 1))
+	}
+}
+
+// normal distribution with std dev 1/sqrt(nin)
+func lecunInit(data []float32, nin, nout int) {
+	normal(data, math.Sqrt(1/float64(nin)))
+}
+
+// uniform distribution with same variance as lecun
+func lecunUniformInit(data []float32, nin, nout int) {
+	uniform(data, math.Sqrt(3/float64(nin)))
+}
+
+// Glorot normal distribution with std dev sqrt(2/(nin+nout))
+func xavierInit(data []float32, nin, nout int) {
+	normal(data, math.Sqrt(2/float64(nin+nout)))
+}
+
+// Glorot uniform distribution with same variance as xavier
+func xavierUniformInit(data []float32, nin, nout int) {
+	uniform(data, math.Sqrt(6/float64(nin+nout)))
+}
+
+// He / Kaiming normal distribution with std dev sqrt(2/nin) for relu layers
+func heInit(data []float32, nin, nout int) {
+	normal(data, math.Sqrt(2/float64(nin)))
+}
+
+// uniform distribution with same variance as he
+func heUniformInit(data []float32, nin, nout int) {
+	uniform(data, math.Sqrt(6/float64(nin)))
+}
+
+// random orthogonal matrix generated from a normal distribution using Gram-Schmidt.
+// The rows are orthonormal if nout <= nin, else the columns are.
+func orthogonalInit(data []float32, nin, nout int) {
+	// vectors to orthogonalise are rows or columns
+	nvec, size := nout, nin
+	if nout > nin {
+		nvec, size = nin, nout
+	}
+	v := make([][]float64, nvec)
+	for i := range v {
+		v[i] = make([]float64, size)
+		for {
+			for j := range v[i] {
+				v[i][j] = rand.NormFloat64()
+			}
+			for k := 0; k < i; k++ {
+				dot := 0.0
+				for j := range v[i] {
+					dot += v[i][j] * v[k][j]
+				}
+				for j := range v[i] {
+					v[i][j] -= dot * v[k][j]
+				}
+			}
+			norm := 0.0
+			for _, x := range v[i] {
+				norm += x * x
+			}
+			// retry in the unlikely case that the vector is degenerate
+			if norm = math.Sqrt(norm); norm > 1e-6 {
+				for j := range v[i] {
+					v[i][j] /= norm
+				}
+				break
+			}
+		}
+	}
+	// data is in column major order
+	for row := 0; row < nout; row++ {
+		for col := 0; col < nin; col++ {
+			if nout <= nin {
+				data[col*nout+row] = float32(v[row][col])
+			} else {
+				data[col*nout+row] = float32(v[col][row])
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package network
+
+import (
+	"github.com/jnb666/deepthought/blas"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestInitialisers(t *testing.T) {
+	nin, nout := 200, 100
+	for _, name := range InitNames {
+		blas.SeedRandom(42)
+		w := blas.New(nout, nin+1)
+		init := Initialiser{Weights: name, Bias: 0.1}
+		init.init(w)
+		data := w.Data(blas.ColMajor)
+		// check variance of weights and bias values
+		var sum2 float64
+		for _, x := range data[:nin*nout] {
+			sum2 += float64(x) * float64(x)
+		}
+		variance := sum2 / float64(nin*nout)
+		t.Logf("%-14s: variance=%.5f", name, variance)
+		var expect float64
+		switch name {
+		case "lecun", "lecun_uniform":
+			expect = 1 / float64(nin)
+		case "xavier", "xavier_uniform":
+			expect = 2 / float64(nin+nout)
+		case "he", "he_uniform":
+			expect = 2 / float64(nin)
+		case "orthogonal":
+			expect = 1 / float64(nin)
+		}
+		if math.Abs(variance-expect) > 0.05*expect {
+			t.Errorf("%s: expected variance %g got %g", name, expect, variance)
+		}
+		for _, x := range data[nin*nout:] {
+			if x != 0.1 {
+				t.Fatalf("%s: expected bias 0.1 got %g", name, x)
+			}
+		}
+		// same random seed should give the same weights
+		blas.SeedRandom(42)
+		init.init(w)
+		if !reflect.DeepEqual(w.Data(blas.ColMajor), data) {
+			t.Errorf("%s: weights are not reproducible", name)
+		}
+		w.Release()
+	}
+}
+
+func TestOrthogonal(t *testing.T) {
+	for _, size := range [][2]int{{5, 3}, {3, 5}, {4, 4}} {
+		nin, nout := size[0], size[1]
+		w := blas.New(nout, nin+1)
+		Initialiser{Weights: "orthogonal"}.init(w)
+		// W*W' or W'*W should be the identity matrix
+		m := w.Col(0, nin)
+		prod := blas.New(nin, nin)
+		if nout <= nin {
+			prod.Mul(m, m, false, true, false)
+		} else {
+			prod.Mul(m, m, true, false, false)
+		}
+		data := prod.Data(blas.RowMajor)
+		n := prod.Rows()
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				expect := 0.0
+				if i == j {
+					expect = 1
+				}
+				if math.Abs(float64(data[i*n+j])-expect) > 1e-5 {
+					t.Errorf("nin=%d nout=%d: matrix is not orthogonal\n%s", nin, nout, prod)
+					i, j = n, n
+				}
+			}
+		}
+		w.Release()
+		prod.Release()
+	}
+}
+
+func TestLayerInitialiser(t *testing.T) {
+	n := New(10, nil)
+	n.AddLayer([]int{4}, 3, Linear, Initialiser{Weights: "he", Bias: 1})
+	n.AddLayer([]int{3}, 2, Sigmoid)
+	n.AddQuadraticOutput(2, Sigmoid)
+	defer n.Release()
+	n.SetInitialiser(Initialiser{Bias: 2})
+	n.SetRandomWeights()
+	for i, bias := range []float32{1, 2} {
+		w := n.Nodes[i].Weights()
+		for _, x := range w.Col(w.Cols()-1, w.Cols()).Data(blas.ColMajor) {
+			if x != bias {
+				t.Errorf("layer %d: expected bias %g got %g", i, bias, x)
+			}
+		}
+	}
+}
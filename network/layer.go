@@ -1,8 +1,8 @@
 package network
 
 import (
+	"fmt"
 	"github.com/jnb666/deepthought/blas"
-	"math"
 )
 
 // Layer interface type represents one layer in the network.
@@ -10,7 +10,7 @@ type Layer interface {
 	Dims() []int
 	Values() blas.Matrix
 	FeedForward(in blas.Matrix) blas.Matrix
-	BackProp(err blas.Matrix, momentum float32) blas.Matrix
+	BackProp(err blas.Matrix) blas.Matrix
 	Weights() blas.Matrix
 	Gradient() blas.Matrix
 	Cost(t blas.Matrix) blas.Matrix
@@ -18,34 +18,33 @@ type Layer interface {
 }
 
 type layer struct {
-	nlayer    int
-	dims      []int
-	activ     Activation
-	input     blas.Matrix // Z matrix of value at each node [samples, nin+1]
-	output    blas.Matrix // return value at each node [samples, nout]
-	weights   blas.Matrix // W outgoing weight matrix  [nout, nin+1]
-	gradient  blas.Matrix // G gradient of weight matrix [nout, nin+1]
-	gradient2 blas.Matrix // G' gradient of weight matrix [nout, nin+1]
-	deriv     blas.Matrix // Fp matrix of derivative of activation fn [samples, nin]
-	delta     blas.Matrix // D matrix of errors at each node [samples, nin]
+	nlayer   int
+	dims     []int
+	activ    Activation
+	input    blas.Matrix // Z matrix of value at each node [samples, nin+1]
+	output   blas.Matrix // return value at each node [samples, nout]
+	weights  blas.Matrix // W outgoing weight matrix  [nout, nin+1]
+	gradient blas.Matrix // G gradient of weight matrix [nout, nin+1]
+	deriv    blas.Matrix // Fp matrix of derivative of activation fn [samples, nin]
+	delta    blas.Matrix // D matrix of errors at each node [samples, nin]
 }
 
 // AddLayer method adds a new input or hidden layer to the network.
-func (n *Network) AddLayer(dims []int, nout int, a Activation) {
+// An optional initialiser may be given to override the network default for this layer.
+func (n *Network) AddLayer(dims []int, nout int, a Activation, init ...Initialiser) {
 	batch := n.BatchSize
 	nin := 1
 	for _, n := range dims {
 		nin *= n
 	}
 	l := &layer{
-		nlayer:    n.Layers,
-		dims:      dims,
-		activ:     a,
-		input:     blas.New(batch, nin+1),
-		output:    blas.New(batch, nout),
-		weights:   blas.New(nout, nin+1),
-		gradient:  blas.New(nout, nin+1),
-		gradient2: blas.New(nout, nin+1),
+		nlayer:   n.Layers,
+		dims:     dims,
+		activ:    a,
+		input:    blas.New(batch, nin+1),
+		output:   blas.New(batch, nout),
+		weights:  blas.New(nout, nin+1),
+		gradient: blas.New(nout, nin+1),
 	}
 	if a.Deriv != nil {
 		l.deriv = blas.New(batch, nin)
@@ -53,6 +52,7 @@ func (n *Network) AddLayer(dims []int, nout int, a Activation) {
 	if n.Layers > 0 {
 		l.delta = blas.New(nin, batch)
 	}
+	n.addInit(init)
 	n.add(l)
 }
 
@@ -95,46 +95,55 @@ func (l *layer) FeedForward(in blas.Matrix) blas.Matrix {
 	return l.output
 }
 
-func (l *layer) BackProp(err blas.Matrix, momentum float32) blas.Matrix {
+func (l *layer) BackProp(err blas.Matrix) blas.Matrix {
 	// calculate the gradient
-	if momentum == 0 {
-		l.gradient.Mul(err, l.input, true, false, false)
-	} else {
-		l.gradient2.Mul(err, l.input, true, false, false)
-		l.gradient.Add(l.gradient2, l.gradient, momentum)
-	}
+	l.gradient.Mul(err, l.input, true, false, false)
 	// propagate error backward
 	if l.delta != nil {
 		c := l.weights.Cols()
 		l.delta.Mul(l.weights.Col(0, c-1), err, true, true, true)
-		l.delta.MulElem(l.delta, l.deriv)
+		if l.deriv != nil {
+			l.delta.MulElem(l.delta, l.deriv)
+		}
 	}
 	return l.delta
 }
 
 type outLayer struct {
-	activ  Activation
-	dims   []int
-	values blas.Matrix // Z matrix of values at each node [samples, nodes]
-	delta  blas.Matrix // D matrix of errors at each node [samples, nodes]
-	deriv  blas.Matrix // Fp matrix of derivative of activation fn [nin, samples]
-	costs  blas.Matrix // cost for each sample in data set [samples, 1]
-	temp   blas.Matrix
-	cost   blas.BinaryFunction
+	activ    Activation
+	dims     []int
+	batch    int
+	cost     Cost
+	values   blas.Matrix // Z matrix of values at each node [samples, nodes]
+	delta    blas.Matrix // D matrix of errors at each node [samples, nodes]
+	deriv    blas.Matrix // Fp matrix of derivative of activation fn [nin, samples]
+	costs    blas.Matrix // cost for each sample in data set [samples, 1]
+	temp     blas.Matrix
+	sum      blas.Matrix // sum over nodes for softmax derivative [samples, 1]
+	ones     blas.Matrix // row of ones to broadcast sum or weights [1, nodes]
+	weighted bool
+	weights  blas.Matrix // weight for each sample [samples, 1]
+	weights2 blas.Matrix // weights broadcast to each node [samples, nodes]
 }
 
-func newOutLayer(batch, nodes int, a Activation) *outLayer {
+func newOutLayer(batch, nodes int, a Activation, c Cost) *outLayer {
 	l := &outLayer{
 		activ:  a,
 		dims:   []int{nodes},
+		batch:  batch,
+		cost:   c,
 		values: blas.New(batch, nodes),
 		delta:  blas.New(batch, nodes),
 		costs:  blas.New(batch, 1),
 		temp:   blas.New(batch, nodes),
+		ones:   blas.New(1, nodes).Set(1),
 	}
 	if a.Deriv != nil {
 		l.deriv = blas.New(batch, nodes)
 	}
+	if a.Name == "softmax" && c.Deriv() != nil {
+		l.sum = blas.New(batch, 1)
+	}
 	return l
 }
 
@@ -150,9 +159,18 @@ func (l *outLayer) Release() {
 	l.values.Release()
 	l.delta.Release()
 	l.temp.Release()
+	l.costs.Release()
+	l.ones.Release()
 	if l.deriv != nil {
 		l.deriv.Release()
 	}
+	if l.sum != nil {
+		l.sum.Release()
+	}
+	if l.weights != nil {
+		l.weights.Release()
+		l.weights2.Release()
+	}
 }
 
 func (l *outLayer) Weights() blas.Matrix { panic("no weights for output layer!") }
@@ -167,42 +185,81 @@ func (l *outLayer) FeedForward(in blas.Matrix) blas.Matrix {
 	return l.values
 }
 
-func (l *outLayer) BackProp(target blas.Matrix, momentum float32) blas.Matrix {
-	l.delta.Add(l.values, target, -1)
+func (l *outLayer) BackProp(target blas.Matrix) blas.Matrix {
+	if deriv := l.cost.Deriv(); deriv != nil {
+		deriv.Apply(l.values, target, l.delta)
+	} else {
+		l.delta.Add(l.values, target, -1)
+	}
 	if l.activ.Deriv != nil {
 		l.delta.MulElem(l.delta, l.deriv)
+	} else if l.sum != nil {
+		// softmax derivative is not elementwise: delta_j = y_j * (d_j - sum_k d_k * y_k)
+		l.temp.MulElem(l.delta, l.values)
+		l.sum.SumRows(l.temp)
+		l.temp.Mul(l.sum, l.ones, false, false, false)
+		l.delta.Add(l.delta, l.temp, -1)
+		l.delta.MulElem(l.delta, l.values)
+	}
+	if l.weighted {
+		l.delta.MulElem(l.delta, l.weights2)
 	}
 	return l.delta
 }
 
 func (l *outLayer) Cost(target blas.Matrix) blas.Matrix {
-	l.cost.Apply(l.values, target, l.temp)
-	return l.costs.SumRows(l.temp)
+	l.cost.Func().Apply(l.values, target, l.temp)
+	l.costs.SumRows(l.temp)
+	if l.weighted {
+		l.costs.MulElem(l.costs, l.weights)
+	}
+	return l.costs
+}
+
+// set the weight for each sample in the current batch which scales the cost and the error, nil for none
+func (l *outLayer) setWeights(w blas.Matrix) {
+	l.weighted = w != nil
+	if w == nil {
+		return
+	}
+	if l.weights == nil {
+		l.weights = blas.New(l.batch, 1)
+		l.weights2 = blas.New(l.batch, l.dims[0])
+	}
+	l.weights.Copy(w, nil)
+	l.weights2.Mul(l.weights, l.ones, false, false, false)
+}
+
+// AddOutput method appends an output layer with the given activation and cost function to the network.
+// The cost must have a derivative, i.e. it should be one of the costs listed in CostNames.
+func (n *Network) AddOutput(nodes int, a Activation, c Cost) {
+	if c.Deriv() == nil {
+		panic(fmt.Sprintf("output layer: %s cost has no derivative", c.Name()))
+	}
+	n.add(newOutLayer(n.BatchSize, nodes, a, c))
 }
 
 // AddQuadraticOutput method appends a quadratic cost output layer to the network.
 func (n *Network) AddQuadraticOutput(nodes int, a Activation) {
-	layer := newOutLayer(n.BatchSize, nodes, a)
-	if blas.Implementation() == blas.OpenCL32 {
-		layer.cost = blas.NewBinaryCL("float z = (x-y)*(x-y);")
-	} else {
-		layer.cost = blas.Binary32(func(out, tgt float32) float32 { return (out - tgt) * (out - tgt) })
-	}
-	n.add(layer)
+	n.add(newOutLayer(n.BatchSize, nodes, a, QuadraticCost()))
 }
 
 // AddCrossEntropyOutput method appends a cross entropy output layer with softmax activation to the network.
 func (n *Network) AddCrossEntropyOutput(nodes int) {
-	layer := newOutLayer(n.BatchSize, nodes, Softmax)
-	if blas.Implementation() == blas.OpenCL32 {
-		layer.cost = blas.NewBinaryCL("float z = -y * log(max(x, 1e-10f));")
-	} else {
-		layer.cost = blas.Binary32(func(out, tgt float32) float32 {
-			if out < 1e-10 {
-				out = 1e-10
-			}
-			return -tgt * float32(math.Log(float64(out)))
-		})
-	}
+	n.add(newOutLayer(n.BatchSize, nodes, Softmax, CrossEntropyCost()))
+}
+
+// AddBinaryCrossEntropyOutput method appends a binary cross entropy output layer with sigmoid activation
+// to the network for multi-label targets where each output is an independent 0 or 1 label. The network
+// classifier should threshold each output, e.g. NewThreshold(0.5), and the dataset classes should be the
+// target labels.
+func (n *Network) AddBinaryCrossEntropyOutput(nodes int) {
+	// error at the sigmoid input is output - target so the derivative is not needed
+	a := Sigmoid
+	a.Deriv = nil
+	layer := newOutLayer(n.BatchSize, nodes, a, BinaryCrossEntropyCost())
+	// one class value per output
+	n.classes.Release()
+	n.classes = blas.New(n.BatchSize, nodes)
 	n.add(layer)
 }
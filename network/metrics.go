@@ -0,0 +1,380 @@
+package network
+
+import (
+	"fmt"
+	"github.com/jnb666/deepthought/blas"
+	"math"
+	"sort"
+)
+
+// number of points on the ROC and precision-recall curves for recall or false positive rate from 0 to 1
+const curvePoints = 101
+
+// Metrics type has the error measures for a data set which are calculated by GetError.
+type Metrics struct {
+	Cost       float32         // average cost per output
+	ClassError float32         // fraction of samples which are misclassified: classification only
+	MAE        float32         // mean absolute error: regression only
+	RMSE       float32         // root mean squared error: regression only
+	R2         float32         // coefficient of determination: regression only
+	Confusion  ConfusionMatrix // counts of target vs predicted class: single label classification only
+	// single label classification with top-k set
+	TopKAccuracy float32 // fraction of samples where the target is one of the k highest outputs
+	MRR          float32 // mean reciprocal rank of the target, or 0 if it is not in the top k
+	// binary classification with ROC set
+	ROC    []float32 // true positive rate at each false positive rate from 0 to 1 in curvePoints steps
+	PR     []float32 // interpolated precision at each recall from 0 to 1 in curvePoints steps
+	ROCAUC float32   // area under the ROC curve
+	PRAUC  float32   // area under the precision-recall curve as average precision
+	// multi-label only
+	HammingLoss    float32   // fraction of labels which are wrong
+	SubsetAccuracy float32   // fraction of samples with every label correct
+	LabelF1        []float32 // F1 score for each label
+}
+
+// regression error measures accumulated over a number of batches
+type regStats struct {
+	count  float64
+	absErr float64
+	sqErr  float64
+	sum    []float64 // sum of targets for each output
+	sumSq  []float64 // sum of squared targets for each output
+}
+
+func (r *regStats) push(output, target blas.Matrix) {
+	out, tgt := output.Data(blas.RowMajor), target.Data(blas.RowMajor)
+	cols := target.Cols()
+	if r.sum == nil {
+		r.sum, r.sumSq = make([]float64, cols), make([]float64, cols)
+	}
+	for i, y := range tgt {
+		diff := float64(out[i] - y)
+		r.absErr += math.Abs(diff)
+		r.sqErr += diff * diff
+		r.sum[i%cols] += float64(y)
+		r.sumSq[i%cols] += float64(y) * float64(y)
+	}
+	r.count += float64(len(tgt))
+}
+
+// R2 is calculated using the variance of each output about its own mean. It is zero if the targets are constant.
+func (r *regStats) metrics(m *Metrics) {
+	if r.count == 0 {
+		return
+	}
+	m.MAE = float32(r.absErr / r.count)
+	m.RMSE = float32(math.Sqrt(r.sqErr / r.count))
+	rows := r.count / float64(len(r.sum))
+	var total float64
+	for i, sum := range r.sum {
+		total += r.sumSq[i] - sum*sum/rows
+	}
+	if total > 0 {
+		m.R2 = float32(1 - r.sqErr/total)
+	}
+}
+
+// top-k accuracy and reciprocal rank accumulated over a number of batches
+type rankStats struct {
+	samples float64
+	hits    float64
+	rr      float64
+}
+
+// top has the k highest ranked classes for each sample and target the target class
+func (r *rankStats) push(top, target blas.Matrix) {
+	p, t := top.Data(blas.RowMajor), target.Data(blas.RowMajor)
+	k := top.Cols()
+	for row := 0; row < target.Rows(); row++ {
+		for i := 0; i < k; i++ {
+			if p[row*k+i] == t[row] {
+				r.hits++
+				r.rr += 1 / float64(i+1)
+				break
+			}
+		}
+	}
+	r.samples += float64(target.Rows())
+}
+
+func (r *rankStats) metrics(m *Metrics) {
+	if r.samples > 0 {
+		m.TopKAccuracy = float32(r.hits / r.samples)
+		m.MRR = float32(r.rr / r.samples)
+	}
+}
+
+// scores and labels for a binary classifier collected over a number of batches
+type scoreStats struct {
+	scores []float32
+	labels []bool
+}
+
+// the score is the last output column, i.e. the single output or the second of two outputs
+func (s *scoreStats) push(output, target blas.Matrix) {
+	out, t := output.Data(blas.RowMajor), target.Data(blas.RowMajor)
+	cols := output.Cols()
+	for row := 0; row < target.Rows(); row++ {
+		s.scores = append(s.scores, out[row*cols+cols-1])
+		s.labels = append(s.labels, t[row] > 0.5)
+	}
+}
+
+// the curves are not set unless there are both positive and negative samples
+func (s *scoreStats) metrics(m *Metrics) {
+	ix := make([]int, len(s.scores))
+	for i := range ix {
+		ix[i] = i
+	}
+	sort.SliceStable(ix, func(i, j int) bool { return s.scores[ix[i]] > s.scores[ix[j]] })
+	var pos, neg float64
+	for _, label := range s.labels {
+		if label {
+			pos++
+		} else {
+			neg++
+		}
+	}
+	if pos == 0 || neg == 0 {
+		return
+	}
+	// fpr, tpr and precision at each distinct threshold
+	fpr, tpr, prec := []float64{0}, []float64{0}, []float64{1}
+	var tp, fp float64
+	for i, j := range ix {
+		if s.labels[j] {
+			tp++
+		} else {
+			fp++
+		}
+		if i == len(ix)-1 || s.scores[ix[i+1]] != s.scores[j] {
+			fpr, tpr, prec = append(fpr, fp/neg), append(tpr, tp/pos), append(prec, tp/(tp+fp))
+		}
+	}
+	var auc, ap float64
+	for i := 1; i < len(fpr); i++ {
+		auc += (fpr[i] - fpr[i-1]) * (tpr[i] + tpr[i-1]) / 2
+		ap += (tpr[i] - tpr[i-1]) * prec[i]
+	}
+	m.ROCAUC, m.PRAUC = float32(auc), float32(ap)
+	m.ROC, m.PR = make([]float32, curvePoints), make([]float32, curvePoints)
+	for k := range m.ROC {
+		x := float64(k) / (curvePoints - 1)
+		// linear interpolation between the points either side of x on the ROC curve
+		i := sort.Search(len(fpr), func(i int) bool { return fpr[i] >= x })
+		for i < len(fpr)-1 && fpr[i+1] == x {
+			i++
+		}
+		if fpr[i] == x || i == 0 {
+			m.ROC[k] = float32(tpr[i])
+		} else {
+			m.ROC[k] = float32(tpr[i-1] + (tpr[i]-tpr[i-1])*(x-fpr[i-1])/(fpr[i]-fpr[i-1]))
+		}
+		// interpolated precision is the maximum for any recall >= x
+		for i := range tpr {
+			if tpr[i] >= x-1e-9 && float32(prec[i]) > m.PR[k] {
+				m.PR[k] = float32(prec[i])
+			}
+		}
+	}
+}
+
+// multi-label counts accumulated over a number of batches
+type labelStats struct {
+	samples float64
+	exact   float64 // number of samples with all labels correct
+	tp      []float64
+	fp      []float64
+	fn      []float64
+}
+
+func (l *labelStats) push(pred, target blas.Matrix) {
+	p, t := pred.Data(blas.RowMajor), target.Data(blas.RowMajor)
+	cols := target.Cols()
+	if l.tp == nil {
+		l.tp, l.fp, l.fn = make([]float64, cols), make([]float64, cols), make([]float64, cols)
+	}
+	for row := 0; row < target.Rows(); row++ {
+		correct := true
+		for col := 0; col < cols; col++ {
+			i := row*cols + col
+			switch {
+			case p[i] > 0.5 && t[i] > 0.5:
+				l.tp[col]++
+			case p[i] > 0.5:
+				l.fp[col]++
+				correct = false
+			case t[i] > 0.5:
+				l.fn[col]++
+				correct = false
+			}
+		}
+		if correct {
+			l.exact++
+		}
+	}
+	l.samples += float64(target.Rows())
+}
+
+// F1 for each label is 2*TP / (2*TP + FP + FN), or 1 if the label is never present or predicted.
+func (l *labelStats) metrics(m *Metrics) {
+	if l.samples == 0 {
+		return
+	}
+	m.LabelF1 = make([]float32, len(l.tp))
+	var wrong float64
+	for i, tp := range l.tp {
+		wrong += l.fp[i] + l.fn[i]
+		m.LabelF1[i] = 1
+		if total := 2*tp + l.fp[i] + l.fn[i]; total > 0 {
+			m.LabelF1[i] = float32(2 * tp / total)
+		}
+	}
+	m.HammingLoss = float32(wrong / (l.samples * float64(len(l.tp))))
+	m.SubsetAccuracy = float32(l.exact / l.samples)
+	m.ClassError = 1 - m.SubsetAccuracy
+}
+
+// ConfusionMatrix type has the number of samples for each target class (row) and predicted class (column).
+type ConfusionMatrix [][]int
+
+// add the predicted and target classes for a batch, the matrix is extended if there are more classes
+func (c *ConfusionMatrix) push(pred, target blas.Matrix) {
+	p, t := pred.Data(blas.RowMajor), target.Data(blas.RowMajor)
+	for i := 0; i < target.Rows(); i++ {
+		actual, predicted := int(t[i]), int(p[i])
+		if actual < 0 || predicted < 0 {
+			panic(fmt.Sprintf("confusion matrix: invalid class %d predicted %d", actual, predicted))
+		}
+		c.resize(actual + 1)
+		c.resize(predicted + 1)
+		(*c)[actual][predicted]++
+	}
+}
+
+func (c *ConfusionMatrix) resize(n int) {
+	for i := range *c {
+		for len((*c)[i]) < n {
+			(*c)[i] = append((*c)[i], 0)
+		}
+	}
+	for len(*c) < n {
+		*c = append(*c, make([]int, n))
+	}
+}
+
+// Classes method returns the number of classes.
+func (c ConfusionMatrix) Classes() int {
+	return len(c)
+}
+
+// Support method returns the number of samples with the given target class.
+func (c ConfusionMatrix) Support(class int) int {
+	n := 0
+	for _, count := range c[class] {
+		n += count
+	}
+	return n
+}
+
+// number of samples predicted as the given class
+func (c ConfusionMatrix) predicted(class int) int {
+	n := 0
+	for _, row := range c {
+		n += row[class]
+	}
+	return n
+}
+
+// Precision method returns the fraction of samples predicted as the given class which are correct,
+// or 0 if there are none.
+func (c ConfusionMatrix) Precision(class int) float32 {
+	if n := c.predicted(class); n > 0 {
+		return float32(c[class][class]) / float32(n)
+	}
+	return 0
+}
+
+// Recall method returns the fraction of samples of the given class which are predicted correctly,
+// or 0 if there are none.
+func (c ConfusionMatrix) Recall(class int) float32 {
+	if n := c.Support(class); n > 0 {
+		return float32(c[class][class]) / float32(n)
+	}
+	return 0
+}
+
+// F1 method returns the harmonic mean of the precision and recall for the given class.
+func (c ConfusionMatrix) F1(class int) float32 {
+	if n := c.Support(class) + c.predicted(class); n > 0 {
+		return 2 * float32(c[class][class]) / float32(n)
+	}
+	return 0
+}
+
+// Macro method returns the precision, recall and F1 averaged over the classes which occur in the
+// targets or predictions.
+func (c ConfusionMatrix) Macro() (precision, recall, f1 float32) {
+	n := 0
+	for i := range c {
+		if c.Support(i)+c.predicted(i) > 0 {
+			precision += c.Precision(i)
+			recall += c.Recall(i)
+			f1 += c.F1(i)
+			n++
+		}
+	}
+	if n > 0 {
+		precision, recall, f1 = precision/float32(n), recall/float32(n), f1/float32(n)
+	}
+	return
+}
+
+// Micro method returns the precision, recall and F1 from the total counts over all classes.
+// With a single label for each sample these are all equal to the accuracy.
+func (c ConfusionMatrix) Micro() (precision, recall, f1 float32) {
+	correct, total := 0, 0
+	for i, row := range c {
+		correct += row[i]
+		for _, count := range row {
+			total += count
+		}
+	}
+	if total > 0 {
+		precision = float32(correct) / float32(total)
+	}
+	return precision, precision, precision
+}
+
+// Report method returns a table with the precision, recall, F1 and support for each class
+// followed by the macro and micro averages.
+func (c ConfusionMatrix) Report() string {
+	str := "class  precision  recall      f1  support\n"
+	total := 0
+	for i := range c {
+		total += c.Support(i)
+		str += fmt.Sprintf("%5d  %9.4f  %6.4f  %6.4f  %7d\n", i, c.Precision(i), c.Recall(i), c.F1(i), c.Support(i))
+	}
+	p, r, f1 := c.Macro()
+	str += fmt.Sprintf("macro  %9.4f  %6.4f  %6.4f  %7d\n", p, r, f1, total)
+	p, r, f1 = c.Micro()
+	str += fmt.Sprintf("micro  %9.4f  %6.4f  %6.4f  %7d", p, r, f1, total)
+	return str
+}
+
+// String method returns the counts with a row for each target class.
+func (c ConfusionMatrix) String() string {
+	str := ""
+	for i, row := range c {
+		if i > 0 {
+			str += "\n"
+		}
+		for j, count := range row {
+			if j > 0 {
+				str += " "
+			}
+			str += fmt.Sprintf("%6d", count)
+		}
+	}
+	return str
+}
@@ -0,0 +1,322 @@
+package network
+
+import (
+	"github.com/jnb666/deepthought/blas"
+	"github.com/jnb666/deepthought/vec"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestRegressionMetrics(t *testing.T) {
+	output := blas.New(4, 2).Load(blas.RowMajor, 1, 2, 2, 4, 3, 6, 5, 8)
+	target := blas.New(4, 2).Load(blas.RowMajor, 1, 1, 2, 3, 3, 5, 4, 7)
+	defer output.Release()
+	defer target.Release()
+	var m Metrics
+	r := new(regStats)
+	r.push(output.Row(0, 2), target.Row(0, 2))
+	r.push(output.Row(2, 4), target.Row(2, 4))
+	r.metrics(&m)
+	// errors are 0,1,0,1,0,1,1,1 and total variance of targets about the column means is 5 + 20
+	t.Logf("%+v", m)
+	if m.MAE != 0.625 || vec.Abs(m.RMSE-0.790569) > 1e-6 || vec.Abs(m.R2-0.8) > 1e-6 {
+		t.Error("wrong regression metrics")
+	}
+}
+
+// generate samples with y = 2*x1 - x2 + 0.5
+func linearData(samples int) *Data {
+	in := make([]float32, 2*samples)
+	out := make([]float32, samples)
+	for i := range out {
+		in[2*i], in[2*i+1] = rand.Float32(), rand.Float32()
+		out[i] = 2*in[2*i] - in[2*i+1] + 0.5
+	}
+	return &Data{
+		Input:      blas.New(samples, 2).Load(blas.RowMajor, in...),
+		Output:     blas.New(samples, 1).Load(blas.RowMajor, out...),
+		NumSamples: samples,
+	}
+}
+
+func TestRegression(t *testing.T) {
+	rand.Seed(1)
+	d := &Dataset{Train: linearData(100), Test: linearData(50), NumInputs: 2, NumOutputs: 1, MaxSamples: 100}
+	defer d.Release()
+	cfg := &Config{MaxEpoch: 200, LearnRate: 0.05, BatchSize: 10, Sampler: "random", Optimizer: "adam", Threshold: 1e-5}
+	n := New(cfg.BatchSize, d.OutputToClass)
+	n.AddLayer([]int{2}, 1, Linear)
+	n.AddQuadraticOutput(1, Linear)
+	defer n.Release()
+	if !n.Regression() {
+		t.Fatal("expected regression network")
+	}
+	n.SetRandomWeights()
+	s := NewStats()
+	s.StartRun()
+	stop := StopCriteria(cfg)
+	for {
+		n.Train(s, d, cfg)
+		s.Update(n, d)
+		if done, _ := stop(s); done {
+			break
+		}
+	}
+	t.Log(s)
+	if s.Test.ClassError.Len() != 0 || s.Test.RMSE.Len() != s.Epoch {
+		t.Error("expected regression stats only")
+	}
+	if s.Test.RMSE.Last() > 0.01 || s.Test.R2.Last() < 0.999 {
+		t.Error("failed to fit linear data")
+	}
+	status := s.EndRun(false)
+	t.Log(status)
+	if !strings.Contains(status, "RMSE=") || strings.Contains(status, "class error") {
+		t.Error("end of run status should have regression stats")
+	}
+	if history := s.History(); !strings.Contains(history, "R2:") {
+		t.Error("history should have regression stats")
+	}
+}
+
+func TestLabelMetrics(t *testing.T) {
+	pred := blas.New(4, 3).Load(blas.RowMajor, 1, 0, 1, 1, 1, 0, 0, 0, 0, 1, 0, 1)
+	target := blas.New(4, 3).Load(blas.RowMajor, 1, 0, 1, 1, 0, 0, 0, 1, 0, 1, 0, 1)
+	defer pred.Release()
+	defer target.Release()
+	var m Metrics
+	l := new(labelStats)
+	l.push(pred.Row(0, 2), target.Row(0, 2))
+	l.push(pred.Row(2, 4), target.Row(2, 4))
+	l.metrics(&m)
+	// one false positive for label 1 in sample 1 and one false negative in sample 2
+	t.Logf("%+v", m)
+	if vec.Abs(m.HammingLoss-2.0/12) > 1e-6 || m.SubsetAccuracy != 0.5 || m.ClassError != 0.5 {
+		t.Error("wrong multi-label metrics")
+	}
+	checkEqual(t, m.LabelF1, []float32{1, 0, 1})
+}
+
+func TestBinaryCrossEntropyGradient(t *testing.T) {
+	rand.Seed(1)
+	batch := 4
+	n := New(batch, nil)
+	n.AddLayer([]int{5}, 3, Linear)
+	n.AddBinaryCrossEntropyOutput(3)
+	defer n.Release()
+	target := randMatrix(batch, 3)
+	NewThreshold(0.5).Apply(target, target)
+	checkGradientScale(t, n, randMatrix(batch, 5), target, 1e-4, 1)
+}
+
+// generate samples with 4 random input bits and labels x0, x1 or x2, not x3
+func labelData(samples int) *Data {
+	in := make([]float32, 4*samples)
+	out := make([]float32, 3*samples)
+	for i := 0; i < samples; i++ {
+		x := in[4*i : 4*i+4]
+		for j := range x {
+			x[j] = float32(rand.Intn(2))
+		}
+		out[3*i] = x[0]
+		out[3*i+1] = vec.Max(x[1], x[2])
+		out[3*i+2] = 1 - x[3]
+	}
+	output := blas.New(samples, 3).Load(blas.RowMajor, out...)
+	return &Data{
+		Input:      blas.New(samples, 4).Load(blas.RowMajor, in...),
+		Output:     output,
+		Classes:    blas.New(samples, 3).Copy(output, nil),
+		NumSamples: samples,
+	}
+}
+
+func TestMultiLabel(t *testing.T) {
+	rand.Seed(1)
+	d := &Dataset{OutputToClass: NewThreshold(0.5), Train: labelData(200), Test: labelData(100), NumInputs: 4, NumOutputs: 3, MaxSamples: 200}
+	defer d.Release()
+	cfg := &Config{MaxEpoch: 50, LearnRate: 0.05, BatchSize: 10, Sampler: "random", Optimizer: "adam"}
+	n := New(cfg.BatchSize, d.OutputToClass)
+	n.AddLayer([]int{4}, 3, Linear)
+	n.AddBinaryCrossEntropyOutput(3)
+	defer n.Release()
+	if !n.MultiLabel() || n.Regression() {
+		t.Fatal("expected multi-label network")
+	}
+	n.SetRandomWeights()
+	s := NewStats()
+	s.StartRun()
+	for s.Epoch < cfg.MaxEpoch {
+		n.Train(s, d, cfg)
+		s.Update(n, d)
+	}
+	t.Log(s)
+	if s.Test.HammingLoss.Len() != s.Epoch || s.Test.LabelF1.Len() != 3 {
+		t.Fatal("expected multi-label stats")
+	}
+	if s.Test.HammingLoss.Last() > 0.01 || s.Test.SubsetAcc.Last() < 0.97 {
+		t.Error("failed to learn labels")
+	}
+	for i := 0; i < 3; i++ {
+		if _, f1 := s.Test.LabelF1.XY(i); f1 < 0.97 {
+			t.Errorf("label %d: F1 score %.3f too low", i, f1)
+		}
+	}
+	status := s.EndRun(false)
+	t.Log(status)
+	if !strings.Contains(status, "hamming loss=") {
+		t.Error("end of run status should have multi-label stats")
+	}
+}
+
+func TestConfusionMatrix(t *testing.T) {
+	pred := blas.New(8, 1).Load(blas.RowMajor, 0, 0, 1, 1, 2, 0, 2, 1)
+	target := blas.New(8, 1).Load(blas.RowMajor, 0, 0, 1, 2, 2, 1, 2, 1)
+	defer pred.Release()
+	defer target.Release()
+	c := ConfusionMatrix{}
+	c.push(pred.Row(0, 4), target.Row(0, 4))
+	c.push(pred.Row(4, 8), target.Row(4, 8))
+	t.Logf("\n%s\n%s", c, c.Report())
+	expect := ConfusionMatrix{{2, 0, 0}, {1, 2, 0}, {0, 1, 2}}
+	for i := range expect {
+		for j := range expect[i] {
+			if c[i][j] != expect[i][j] {
+				t.Fatal("wrong confusion matrix")
+			}
+		}
+	}
+	checkEqual(t, []float32{c.Precision(0), c.Precision(1), c.Precision(2)}, []float32{2.0 / 3, 2.0 / 3, 1})
+	checkEqual(t, []float32{c.Recall(0), c.Recall(1), c.Recall(2)}, []float32{1, 2.0 / 3, 2.0 / 3})
+	checkEqual(t, []float32{c.F1(0), c.F1(1), c.F1(2)}, []float32{0.8, 2.0 / 3, 0.8})
+	if p, r, f1 := c.Macro(); vec.Abs(p-7.0/9) > 1e-6 || vec.Abs(r-7.0/9) > 1e-6 || vec.Abs(f1-(1.6+2.0/3)/3) > 1e-6 {
+		t.Error("wrong macro averages", p, r, f1)
+	}
+	if p, r, f1 := c.Micro(); p != 0.75 || r != 0.75 || f1 != 0.75 {
+		t.Error("wrong micro averages", p, r, f1)
+	}
+}
+
+func TestClassStats(t *testing.T) {
+	rand.Seed(1)
+	d := &Dataset{OutputToClass: MaxCol{}, Train: imbalancedData(200), Test: imbalancedData(100), NumInputs: 2, NumOutputs: 2, MaxSamples: 200}
+	defer d.Release()
+	cfg := &Config{MaxEpoch: 20, LearnRate: 0.05, BatchSize: 10, Sampler: "random", Optimizer: "adam", TopK: 1}
+	n := New(cfg.BatchSize, d.OutputToClass)
+	n.AddLayer([]int{2}, 8, Linear)
+	n.AddLayer([]int{8}, 2, Relu)
+	n.AddCrossEntropyOutput(2)
+	defer n.Release()
+	n.SetRandomWeights()
+	s := NewStats()
+	s.ClassStats = true
+	s.StartRun()
+	for s.Epoch < cfg.MaxEpoch {
+		n.Train(s, d, cfg)
+		s.Update(n, d)
+	}
+	t.Log(s)
+	c := s.Test.Confusion
+	if c.Classes() != 2 || c.Support(0)+c.Support(1) != 100 {
+		t.Fatal("expecting confusion matrix for test set")
+	}
+	if _, _, f1 := c.Micro(); vec.Abs(f1-(1-s.Test.ClassError.Last())) > 1e-6 {
+		t.Error("micro F1 should equal accuracy")
+	}
+	classError := s.Test.ClassError.Last()
+	if vec.Abs(s.Test.TopKError.Last()-classError) > 1e-6 || vec.Abs(s.Test.MRR.Last()-(1-classError)) > 1e-6 {
+		t.Error("top-1 error should equal classification error")
+	}
+	status := s.EndRun(false)
+	t.Log(status)
+	if !strings.Contains(status, "macro F1=") || !strings.Contains(status, "precision") {
+		t.Error("end of run status should have class stats")
+	}
+}
+
+func TestRankMetrics(t *testing.T) {
+	output := blas.New(4, 4).Load(blas.RowMajor, 0.1, 0.6, 0.2, 0.1, 0.5, 0.1, 0.3, 0.1, 0.2, 0.2, 0.2, 0.4, 0.7, 0.1, 0.1, 0.1)
+	target := blas.New(4, 1).Load(blas.RowMajor, 1, 2, 0, 3)
+	top := blas.New(4, 3)
+	defer output.Release()
+	defer target.Release()
+	defer top.Release()
+	var m Metrics
+	r := new(rankStats)
+	r.push(top.TopK(output, 3), target)
+	r.metrics(&m)
+	// ranks are 1, 2, 2 and not in top 3
+	t.Logf("%+v", m)
+	if m.TopKAccuracy != 0.75 || vec.Abs(m.MRR-0.5) > 1e-6 {
+		t.Error("wrong ranking metrics")
+	}
+}
+
+func TestScoreMetrics(t *testing.T) {
+	output := blas.New(6, 2).Load(blas.RowMajor, 0.1, 0.9, 0.2, 0.8, 0.3, 0.7, 0.4, 0.6, 0.5, 0.5, 0.6, 0.4)
+	target := blas.New(6, 1).Load(blas.RowMajor, 1, 0, 1, 1, 0, 0)
+	defer output.Release()
+	defer target.Release()
+	var m Metrics
+	s := new(scoreStats)
+	s.push(output, target)
+	s.metrics(&m)
+	t.Logf("auc=%.4f ap=%.4f roc=%v pr=%v", m.ROCAUC, m.PRAUC, m.ROC, m.PR)
+	// 7 of the 9 positive, negative pairs are ranked correctly
+	if vec.Abs(m.ROCAUC-7.0/9) > 1e-6 || vec.Abs(m.PRAUC-(1+2.0/3+0.75)/3) > 1e-6 {
+		t.Error("wrong area under curve")
+	}
+	if len(m.ROC) != curvePoints || len(m.PR) != curvePoints {
+		t.Fatal("wrong number of points on curves")
+	}
+	if vec.Abs(m.ROC[0]-1.0/3) > 1e-6 || m.ROC[50] != 1 || m.ROC[100] != 1 {
+		t.Error("wrong ROC curve")
+	}
+	if m.PR[0] != 1 || m.PR[50] != 0.75 || m.PR[100] != 0.75 {
+		t.Error("wrong precision-recall curve")
+	}
+	// tied scores give a single point on the ROC curve
+	m = Metrics{}
+	s = new(scoreStats)
+	output.Set(0.5)
+	s.push(output, target)
+	s.metrics(&m)
+	if vec.Abs(m.ROCAUC-0.5) > 1e-6 || vec.Abs(m.PRAUC-0.5) > 1e-6 || vec.Abs(m.ROC[50]-0.5) > 1e-6 {
+		t.Error("wrong metrics for tied scores")
+	}
+}
+
+func TestROC(t *testing.T) {
+	rand.Seed(1)
+	d := &Dataset{OutputToClass: MaxCol{}, Train: imbalancedData(200), Test: imbalancedData(100), NumInputs: 2, NumOutputs: 2, MaxSamples: 200}
+	defer d.Release()
+	cfg := &Config{MaxEpoch: 10, LearnRate: 0.05, BatchSize: 10, Sampler: "random", Optimizer: "adam", ROC: true}
+	n := New(cfg.BatchSize, d.OutputToClass)
+	n.AddLayer([]int{2}, 8, Linear)
+	n.AddLayer([]int{8}, 2, Relu)
+	n.AddCrossEntropyOutput(2)
+	defer n.Release()
+	n.SetRandomWeights()
+	s := NewStats()
+	s.StartRun()
+	for s.Epoch < cfg.MaxEpoch {
+		n.Train(s, d, cfg)
+		s.Update(n, d)
+	}
+	t.Log(s)
+	if s.Test.ROCAUC.Len() != cfg.MaxEpoch || s.Test.ROC.Len() != curvePoints || s.Test.PR.Len() != curvePoints {
+		t.Fatal("expecting ROC stats for test set")
+	}
+	if auc := s.Test.ROCAUC.Last(); auc < 0.8 || auc > 1 {
+		t.Error("area under ROC curve out of range", auc)
+	}
+	if _, y := s.Test.ROC.XY(curvePoints - 1); y != 1 {
+		t.Error("ROC curve should end at 1, 1")
+	}
+	status := s.EndRun(false)
+	t.Log(status)
+	if !strings.Contains(status, "AUC=") {
+		t.Error("end of run status should have area under curve")
+	}
+}
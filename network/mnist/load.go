@@ -19,6 +19,7 @@ func (Loader) Load(samples int) (*network.Dataset, error) {
 	r := newReader(trainLabels, trainImages)
 	s.Train = r.read(samples, trainMax)
 	s.NumInputs = int(r.image.Width * r.image.Height)
+	s.InputDims = []int{int(r.image.Height), int(r.image.Width)}
 	s.NumOutputs = numOutputs
 	s.MaxSamples = s.Train.NumSamples
 
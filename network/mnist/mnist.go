@@ -24,6 +24,7 @@ const (
 func init() {
 	network.Register("mnist", &Loader{})
 	network.Register("mnist2", Loader2{&Loader{}})
+	network.Register("mnist3", Loader3{&Loader{}})
 }
 
 // classification function
@@ -69,6 +70,7 @@ func (Loader2) Config() *network.Config {
 		LogEvery:   5,
 		Sampler:    "random",
 		Distortion: 1,
+		WeightInit: "he",
 	}
 }
 
@@ -83,6 +85,36 @@ func (Loader2) CreateNetwork(cfg *network.Config, d *network.Dataset) *network.N
 	return net
 }
 
+// Convolutional network configuration
+type Loader3 struct{ *Loader }
+
+func (Loader3) Config() *network.Config {
+	return &network.Config{
+		MaxRuns:    1,
+		MaxEpoch:   30,
+		BatchSize:  100,
+		LearnRate:  0.1,
+		Momentum:   0.9,
+		StopAfter:  5,
+		LogEvery:   1,
+		Sampler:    "random",
+		WeightInit: "he",
+	}
+}
+
+func (Loader3) CreateNetwork(cfg *network.Config, d *network.Dataset) *network.Network {
+	filters, kernel, hiddenNodes := 20, 5, 100
+	fmt.Printf("MNIST DATASET: %dx%d convolution with %d filters and 2x2 max pooling => [%d,%d] layers with cross entropy cost and relu activation\n",
+		kernel, kernel, filters, hiddenNodes, d.NumOutputs)
+	net := network.New(cfg.BatchSize, d.OutputToClass)
+	convOut := net.AddConvLayer(dims(d.NumInputs), filters, kernel, 1, 0, network.Linear)
+	poolOut := net.AddMaxPoolLayer(convOut, 2, 2, network.Relu)
+	net.AddLayer(poolOut, hiddenNodes, network.Linear)
+	net.AddLayer(dims(hiddenNodes), d.NumOutputs, network.Relu)
+	net.AddCrossEntropyOutput(d.NumOutputs)
+	return net
+}
+
 func dims(n int) []int {
 	size := int(math.Sqrt(float64(n)))
 	if size*size == n {
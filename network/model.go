@@ -0,0 +1,292 @@
+package network
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"github.com/jnb666/deepthought/blas"
+	"io"
+)
+
+const (
+	modelHeader  = "deepthought model"
+	modelVersion = 1
+)
+
+// layers which can be saved to a model file
+type savedLayer interface {
+	spec() layerSpec
+}
+
+// layerSpec type has the parameters needed to recreate a layer. Weights are stored in row major order
+// as float32 so that the format does not depend on the matrix implementation.
+type layerSpec struct {
+	Type       string    // layer type
+	Dims       []int     // input dimensions
+	Nodes      int       // number of outputs for dense and output layers
+	Activation string    // activation function name
+	Filters    int       // convolution filters
+	Kernel     int       // convolution kernel size
+	Stride     int       // convolution or pooling stride
+	Pad        int       // convolution padding
+	Size       int       // pooling window size
+	Rate       float32   // dropout rate
+	Sequence   bool      // recurrent layer outputs every step
+	Truncate   int       // recurrent layer back propagation limit
+	Clip       float32   // recurrent layer gradient clipping
+	Vocab      int       // number of rows in embedding table
+	Delta      float32   // huber cost threshold
+	Weights    []float32 // weight matrix
+	Mean       []float32 // batch normalisation running mean
+	Var        []float32 // batch normalisation running variance
+}
+
+type model struct {
+	BatchSize  int
+	Classifier string  // maxcol, threshold, none for a regression network or custom
+	Threshold  float32 // level for threshold classifier
+	Layers     []layerSpec
+}
+
+// Save method writes the network layers and weights to w. The output starts with a text header line
+// with the format version followed by the gob encoded model.
+func (n *Network) Save(w io.Writer) error {
+	layers, err := n.specs()
+	if err != nil {
+		return fmt.Errorf("Save: %s", err)
+	}
+	m := model{BatchSize: n.BatchSize, Layers: layers}
+	switch c := n.out2class.(type) {
+	case nil:
+		m.Classifier = "none"
+	case MaxCol:
+		m.Classifier = "maxcol"
+	case threshold:
+		m.Classifier, m.Threshold = "threshold", c.level
+	default:
+		m.Classifier = "custom"
+	}
+	return writeFile(w, modelHeader, modelVersion, m)
+}
+
+// get spec with the current weights for each layer
+func (n *Network) specs() (specs []layerSpec, err error) {
+	for i, layer := range n.Nodes {
+		l, ok := layer.(savedLayer)
+		if !ok {
+			return nil, fmt.Errorf("layer %d of type %T cannot be saved", i, layer)
+		}
+		s := l.spec()
+		if s.Activation == "" {
+			return nil, fmt.Errorf("layer %d has unnamed activation function", i)
+		}
+		specs = append(specs, s)
+	}
+	return specs, nil
+}
+
+// LoadModel function reads a network saved with Network.Save. The model may be loaded with a different
+// matrix implementation to the one it was saved from. The MaxCol and threshold classifiers are restored,
+// or none for a regression network. Other classifiers cannot be saved so the returned network classifies
+// using the index of the output with the highest value, call SetClassifier to change this.
+func LoadModel(r io.Reader) (*Network, error) {
+	var m model
+	if err := readFile(r, modelHeader, modelVersion, &m); err != nil {
+		return nil, fmt.Errorf("LoadModel: %s", err)
+	}
+	var out2class blas.UnaryFunction
+	switch m.Classifier {
+	case "none":
+	case "threshold":
+		out2class = NewThreshold(m.Threshold)
+	case "maxcol", "custom", "":
+		out2class = MaxCol{}
+	default:
+		return nil, fmt.Errorf("LoadModel: unknown classifier %q", m.Classifier)
+	}
+	n := New(m.BatchSize, out2class)
+	for i, s := range m.Layers {
+		if err := n.addSpec(s); err != nil {
+			n.Release()
+			return nil, fmt.Errorf("LoadModel: layer %d: %s", i, err)
+		}
+	}
+	return n, nil
+}
+
+// write text header line with the version followed by the gob encoded data
+func writeFile(w io.Writer, header string, version int, data interface{}) error {
+	if _, err := fmt.Fprintf(w, "%s %d\n", header, version); err != nil {
+		return err
+	}
+	return gob.NewEncoder(w).Encode(data)
+}
+
+// read data written by writeFile, version must match
+func readFile(r io.Reader, header string, version int, data interface{}) error {
+	br := bufio.NewReader(r)
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("error reading header: %s", err)
+	}
+	var fileVersion int
+	if _, err = fmt.Sscanf(line, header+" %d\n", &fileVersion); err != nil {
+		return fmt.Errorf("invalid header %q", line)
+	}
+	if fileVersion != version {
+		return fmt.Errorf("unsupported version %d", fileVersion)
+	}
+	return gob.NewDecoder(br).Decode(data)
+}
+
+// add a new layer given the spec and load the saved weights
+func (n *Network) addSpec(s layerSpec) error {
+	a, err := activation(s.Activation)
+	if err != nil {
+		return err
+	}
+	switch s.Type {
+	case "dense":
+		n.AddLayer(s.Dims, s.Nodes, a)
+	case "conv":
+		n.AddConvLayer(s.Dims, s.Filters, s.Kernel, s.Stride, s.Pad, a)
+	case "maxpool":
+		n.AddMaxPoolLayer(s.Dims, s.Size, s.Stride, a)
+	case "avgpool":
+		n.AddAvgPoolLayer(s.Dims, s.Size, s.Stride, a)
+	case "dropout":
+		n.AddDropoutLayer(s.Dims, s.Rate, a)
+	case "batchnorm":
+		n.AddBatchNormLayer(s.Dims, a)
+	case "embedding":
+		if len(s.Dims) != 1 {
+			return fmt.Errorf("invalid dims for embedding layer")
+		}
+		n.AddEmbeddingLayer(s.Dims[0], s.Vocab, s.Nodes)
+	case "rnn":
+		n.AddRNNLayer(s.Dims, s.Nodes, RecurrentOpts{Sequence: s.Sequence, Truncate: s.Truncate, Clip: s.Clip}, a)
+	case "lstm":
+		n.AddLSTMLayer(s.Dims, s.Nodes, RecurrentOpts{Sequence: s.Sequence, Truncate: s.Truncate, Clip: s.Clip}, a)
+	case "quadratic":
+		n.AddQuadraticOutput(s.Nodes, a)
+	case "crossentropy":
+		n.AddCrossEntropyOutput(s.Nodes)
+	case "binarycrossentropy":
+		n.AddBinaryCrossEntropyOutput(s.Nodes)
+	default:
+		c, err := costFunction(s.Type, s.Delta)
+		if err != nil {
+			return fmt.Errorf("unknown layer type %q", s.Type)
+		}
+		n.AddOutput(s.Nodes, a, c)
+	}
+	return loadSpec(n.Nodes[n.Layers-1], s)
+}
+
+// load saved weights and batch normalisation stats into an existing layer
+func loadSpec(layer Layer, s layerSpec) error {
+	if s.Weights != nil {
+		w := layer.Weights()
+		if w == nil || w.Rows()*w.Cols() != len(s.Weights) {
+			return fmt.Errorf("wrong number of weights for %s layer", s.Type)
+		}
+		w.Load(blas.RowMajor, s.Weights...)
+		layer.Gradient().Set(0)
+	}
+	if l, ok := layer.(*batchNormLayer); ok {
+		if len(s.Mean) != l.runMean.Rows() || len(s.Var) != l.runVar.Rows() {
+			return fmt.Errorf("wrong size for batch normalisation stats")
+		}
+		l.runMean.Load(blas.RowMajor, s.Mean...)
+		l.runVar.Load(blas.RowMajor, s.Var...)
+	}
+	return nil
+}
+
+func (l *layer) spec() layerSpec {
+	return layerSpec{
+		Type:       "dense",
+		Dims:       l.dims,
+		Nodes:      l.weights.Rows(),
+		Activation: l.activ.Name,
+		Weights:    l.weights.Data(blas.RowMajor),
+	}
+}
+
+func (l *outLayer) spec() layerSpec {
+	s := layerSpec{Type: l.cost.Name(), Nodes: l.dims[0], Activation: l.activ.Name}
+	if c, ok := l.cost.(costFunc); ok {
+		s.Delta = c.delta
+	}
+	return s
+}
+
+func (l *convLayer) spec() layerSpec {
+	return layerSpec{
+		Type:       "conv",
+		Dims:       l.dims,
+		Activation: l.activ.Name,
+		Filters:    l.conv.Filters,
+		Kernel:     l.conv.Kernel,
+		Stride:     l.conv.Stride,
+		Pad:        l.conv.Pad,
+		Weights:    l.weights.Data(blas.RowMajor),
+	}
+}
+
+func (l *poolLayer) spec() layerSpec {
+	s := layerSpec{
+		Type:       "avgpool",
+		Dims:       l.dims,
+		Activation: l.activ.Name,
+		Size:       l.pool.Size,
+		Stride:     l.pool.Stride,
+	}
+	if l.max {
+		s.Type = "maxpool"
+	}
+	return s
+}
+
+func (l *dropoutLayer) spec() layerSpec {
+	return layerSpec{Type: "dropout", Dims: l.dims, Activation: l.activ.Name, Rate: l.rate}
+}
+
+func (l *batchNormLayer) spec() layerSpec {
+	return layerSpec{
+		Type:       "batchnorm",
+		Dims:       l.dims,
+		Activation: l.activ.Name,
+		Weights:    l.weights.Data(blas.RowMajor),
+		Mean:       l.runMean.Data(blas.RowMajor),
+		Var:        l.runVar.Data(blas.RowMajor),
+	}
+}
+
+func (l *recurrentLayer) spec() layerSpec {
+	s := layerSpec{
+		Type:       "rnn",
+		Dims:       l.dims,
+		Nodes:      l.nout,
+		Activation: l.activ.Name,
+		Sequence:   l.Sequence,
+		Truncate:   l.Truncate,
+		Clip:       l.Clip,
+		Weights:    l.weights.Data(blas.RowMajor),
+	}
+	if l.lstm {
+		s.Type = "lstm"
+	}
+	return s
+}
+
+func (l *embeddingLayer) spec() layerSpec {
+	return layerSpec{
+		Type:       "embedding",
+		Dims:       l.dims,
+		Nodes:      l.size,
+		Vocab:      l.vocab,
+		Activation: Linear.Name,
+		Weights:    l.weights.Data(blas.RowMajor),
+	}
+}
@@ -0,0 +1,139 @@
+package network
+
+import (
+	"bytes"
+	"github.com/jnb666/deepthought/blas"
+	"github.com/jnb666/deepthought/vec"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestSaveModel(t *testing.T) {
+	rand.Seed(1)
+	batch := 8
+	n := New(batch, MaxCol{})
+	dims := n.AddConvLayer([]int{8, 8}, 3, 3, 1, 1, Linear)
+	dims = n.AddMaxPoolLayer(dims, 2, 2, Relu)
+	dims = n.AddAvgPoolLayer(dims, 2, 1, Linear)
+	n.AddBatchNormLayer(dims, Linear)
+	n.AddDropoutLayer(dims, 0.2, Tanh)
+	n.AddLayer(dims, 5, Linear)
+	n.AddCrossEntropyOutput(5)
+	defer n.Release()
+	n.SetRandomWeights()
+	input := randMatrix(batch, 64)
+	// update the batch norm running stats
+	n.SetTraining(true)
+	n.FeedForward(input)
+	n.SetTraining(false)
+	expect := n.FeedForward(input).Data(blas.RowMajor)
+	class := n.Classify(n.FeedForward(input)).Data(blas.RowMajor)
+
+	var buf bytes.Buffer
+	if err := n.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("saved %d bytes", buf.Len())
+	n2, err := LoadModel(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n2.Release()
+	if n2.Layers != n.Layers || n2.BatchSize != batch {
+		t.Fatalf("expected %d layers got %d", n.Layers, n2.Layers)
+	}
+	for i, layer := range n2.Nodes[:n2.Layers-1] {
+		if w := layer.Weights(); w != nil {
+			checkEqual(t, w.Data(blas.RowMajor), n.Nodes[i].Weights().Data(blas.RowMajor))
+		}
+	}
+	output := n2.FeedForward(input)
+	checkEqual(t, output.Data(blas.RowMajor), expect)
+	checkEqual(t, n2.Classify(output).Data(blas.RowMajor), class)
+}
+
+func TestLoadModelErrors(t *testing.T) {
+	n := New(4, nil)
+	n.AddLayer([]int{2}, 2, Activation{Func: linear{}})
+	n.AddQuadraticOutput(2, Linear)
+	defer n.Release()
+	if err := n.Save(new(bytes.Buffer)); err == nil {
+		t.Error("expected error saving unnamed activation")
+	}
+	for _, s := range []string{"", "not a model\n", "deepthought model 99\n", "deepthought model 1\ngarbage"} {
+		if _, err := LoadModel(strings.NewReader(s)); err == nil {
+			t.Errorf("expected error loading %q", s)
+		} else {
+			t.Log(err)
+		}
+	}
+}
+
+func TestLoadModelImplementation(t *testing.T) {
+	defer Init(blas.Implementation())
+	rand.Seed(1)
+	batch := 8
+	for _, classifier := range []string{"maxcol", "threshold", "none"} {
+		// save with single precision
+		Init(blas.Native32)
+		var out2class blas.UnaryFunction
+		switch classifier {
+		case "maxcol":
+			out2class = MaxCol{}
+		case "threshold":
+			out2class = NewThreshold(0.5)
+		}
+		input := randMatrix(batch, 4)
+		n := New(batch, out2class)
+		n.AddLayer([]int{4}, 6, Linear)
+		n.AddLayer([]int{6}, 3, Tanh)
+		switch out2class.(type) {
+		case MaxCol:
+			n.AddCrossEntropyOutput(3)
+		case threshold:
+			n.AddBinaryCrossEntropyOutput(3)
+		default:
+			n.AddQuadraticOutput(3, Linear)
+		}
+		n.SetRandomWeights()
+		output := n.FeedForward(input)
+		expect := output.Data(blas.RowMajor)
+		var buf bytes.Buffer
+		if err := n.Save(&buf); err != nil {
+			t.Fatal(err)
+		}
+		var class []float32
+		if out2class != nil {
+			class = n.Classify(output).Data(blas.RowMajor)
+		}
+		data := input.Data(blas.RowMajor)
+		input.Release()
+		n.Release()
+		// load with double precision
+		Init(blas.Native64)
+		n2, err := LoadModel(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		input2 := blas.New(batch, 4).Load(blas.RowMajor, data...)
+		output = n2.FeedForward(input2)
+		for i, x := range output.Data(blas.RowMajor) {
+			if vec.Abs(x-expect[i]) > 1e-5 {
+				t.Fatalf("output %d: expected %g got %g", i, expect[i], x)
+			}
+		}
+		if out2class == nil {
+			if !n2.Regression() {
+				t.Fatal("expecting regression network")
+			}
+			d := &Data{NumSamples: batch, Input: input2, Output: blas.New(batch, 3).Set(0)}
+			t.Logf("regression: %+v", n2.GetError(batch, d, vec.New(histBins), histMax))
+		} else {
+			t.Logf("%s: %v", classifier, class)
+			checkEqual(t, n2.Classify(output).Data(blas.RowMajor), class)
+		}
+		input2.Release()
+		n2.Release()
+	}
+}
@@ -15,7 +15,7 @@ const epsilon = 0.01
 
 // Standard activation functions
 var (
-	Linear  = Activation{linear{}, nil}
+	Linear  = Activation{Func: linear{}, Name: "linear"}
 	Sigmoid Activation
 	Tanh    Activation
 	Relu    Activation
@@ -26,6 +26,7 @@ var (
 type Activation struct {
 	Func  blas.UnaryFunction
 	Deriv blas.UnaryFunction
+	Name  string
 }
 
 // Init function initialises the package and set the matrix implementation.
@@ -47,6 +48,38 @@ func Init(imp blas.Impl) {
 		Softmax = Activation{
 			Func: softmax{fn: blas.NewUnaryCL("float y = exp(x);")},
 		}
+	} else if imp == blas.Native64 {
+		Sigmoid = Activation{
+			Func: blas.Unary64(sigmoid64),
+			Deriv: blas.Unary64(func(x float64) float64 {
+				y := sigmoid64(x)
+				return y * (1 - y)
+			}),
+		}
+		Tanh = Activation{
+			Func: blas.Unary64(math.Tanh),
+			Deriv: blas.Unary64(func(x float64) float64 {
+				y := math.Tanh(x)
+				return 1 - y*y
+			}),
+		}
+		Relu = Activation{
+			Func: blas.Unary64(func(x float64) float64 {
+				if x >= 0 {
+					return x
+				}
+				return 0
+			}),
+			Deriv: blas.Unary64(func(x float64) float64 {
+				if x >= 0 {
+					return 1
+				}
+				return 0
+			}),
+		}
+		Softmax = Activation{
+			Func: softmax{fn: blas.Unary64(math.Exp)},
+		}
 	} else {
 		Sigmoid = Activation{
 			Func: blas.Unary32(sigmoid),
@@ -82,12 +115,27 @@ func Init(imp blas.Impl) {
 			})},
 		}
 	}
+	Sigmoid.Name, Tanh.Name, Relu.Name, Softmax.Name = "sigmoid", "tanh", "relu", "softmax"
+}
+
+// get standard activation function by name
+func activation(name string) (Activation, error) {
+	for _, a := range []Activation{Linear, Sigmoid, Tanh, Relu, Softmax} {
+		if a.Name == name {
+			return a, nil
+		}
+	}
+	return Activation{}, fmt.Errorf("activation function %q not found", name)
 }
 
 func sigmoid(x float32) float32 {
 	return float32(1 / (1 + math.Exp(-float64(x))))
 }
 
+func sigmoid64(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
 func tanh(x float32) float32 {
 	return float32(math.Tanh(float64(x)))
 }
@@ -109,6 +157,12 @@ type Network struct {
 	Layers       int
 	BatchSize    int
 	Verbose      bool
+	training     bool
+	optimizer    Optimizer
+	init         Initialiser
+	layerInit    map[int]Initialiser
+	optType      string
+	optMomentum  float32
 	classes      blas.Matrix
 	out2class    blas.UnaryFunction
 	checkEvery   int
@@ -118,10 +172,20 @@ type Network struct {
 	input        blas.Matrix
 	rawInput     blas.Matrix
 	output       blas.Matrix
+	weights      blas.Matrix
 	errorHist    blas.Matrix
+	classWeights []float32
+	classCfg     string
+	weightCache  map[*Data]blas.Matrix
+	checked      map[*Data]bool
+	topK         int
+	topClasses   blas.Matrix
+	roc          bool
 }
 
 // New function initialises a new network, samples is the maximum number of samples, i.e. minibatch size.
+// out2class converts the network output to a class. If it is nil then this is a regression network and
+// the outputs are compared with the targets directly.
 func New(samples int, out2class blas.UnaryFunction) *Network {
 	return &Network{
 		BatchSize: samples,
@@ -136,6 +200,21 @@ func (n *Network) add(l Layer) {
 	n.Layers++
 }
 
+// set initialiser for the next layer to be added if it is given
+func (n *Network) addInit(init []Initialiser) {
+	if len(init) > 0 {
+		if n.layerInit == nil {
+			n.layerInit = map[int]Initialiser{}
+		}
+		n.layerInit[n.Layers] = init[0]
+	}
+}
+
+// SetInitialiser method sets the default weight initialiser for layers which do not have their own.
+func (n *Network) SetInitialiser(init Initialiser) {
+	n.init = init
+}
+
 // Release method frees up any resources used by the network.
 func (n *Network) Release() {
 	fmt.Println("release resources")
@@ -143,6 +222,9 @@ func (n *Network) Release() {
 		layer.Release()
 	}
 	n.classes.Release()
+	if n.optimizer != nil {
+		n.optimizer.Release()
+	}
 	if n.input != nil {
 		n.input.Release()
 	}
@@ -152,33 +234,88 @@ func (n *Network) Release() {
 	if n.rawInput != nil {
 		n.rawInput.Release()
 	}
+	if n.weights != nil {
+		n.weights.Release()
+	}
+	n.SetClassWeights(nil)
+	n.SetTopK(0)
+	n.checked = nil
+}
+
+// CheckInputs method returns an error if the inputs in d are not valid for the first layer, i.e. if an index
+// for an embedding layer is out of range. The inputs are only read back from the device the first time that
+// d is checked. Train and GetError call this for each data set and panic if the inputs are not valid.
+func (n *Network) CheckInputs(d *Data) error {
+	l, ok := n.Nodes[0].(*embeddingLayer)
+	if !ok || d == nil || n.checked[d] {
+		return nil
+	}
+	if err := l.checkInput(d.Input); err != nil {
+		return err
+	}
+	if n.checked == nil {
+		n.checked = map[*Data]bool{}
+	}
+	n.checked[d] = true
+	return nil
 }
 
 // String method returns a printable representation of the network.
 func (n *Network) String() string {
-	str := make([]string, len(n.Nodes))
+	var str []string
 	for i, layer := range n.Nodes[:n.Layers-1] {
-		str[i] = fmt.Sprintf("== Layer %d ==\n%s", i, layer.Weights())
+		if w := layer.Weights(); w != nil {
+			str = append(str, fmt.Sprintf("== Layer %d ==\n%s", i, w))
+		}
 	}
 	return strings.Join(str, "\n")
 }
 
 // SetRandomWeights method initalises the weights to random values and sets the gradients to zero.
-// Uses a normal distribution with mean zero and std dev 1/sqrt(num_inputs) for the weights.
-// Bias weights are left at zero.
+// Uses the initialiser for the layer if set, else the network default. If neither is set then a normal
+// distribution with mean zero and std dev 1/sqrt(num_inputs) is used for the weights and bias weights are zero.
+// Layers without weights, such as pooling layers, are skipped and batch normalisation layers are reset to
+// unit scale and zero shift. Any optimizer state is cleared.
 func (n *Network) SetRandomWeights() {
-	for _, layer := range n.Nodes[:n.Layers-1] {
+	if n.optimizer != nil {
+		n.optimizer.Release()
+	}
+	for i, layer := range n.Nodes[:n.Layers-1] {
 		w := layer.Weights()
-		nin, nout := w.Cols()-1, w.Rows()
-		data := make([]float32, (nin+1)*nout)
-		for i := range data[:nin*nout] {
-			data[i] = float32(rand.NormFloat64() / math.Sqrt(float64(nin)))
+		if w == nil {
+			continue
 		}
-		w.Load(blas.ColMajor, data...)
+		if l, ok := layer.(weightInitLayer); ok {
+			l.initWeights()
+			continue
+		}
+		init, ok := n.layerInit[i]
+		if !ok {
+			init = n.init
+		}
+		init.init(w)
 		layer.Gradient().Set(0)
 	}
 }
 
+// SetTraining method switches the network between training mode, where dropout masks are applied,
+// and inference mode. Returns the previous setting.
+func (n *Network) SetTraining(on bool) bool {
+	prev := n.training
+	n.training = on
+	for _, layer := range n.Nodes {
+		if l, ok := layer.(trainingLayer); ok {
+			l.setTraining(on, false)
+		}
+	}
+	return prev
+}
+
+// Training method returns true if the network is in training mode.
+func (n *Network) Training() bool {
+	return n.training
+}
+
 // FeedForward method calculates output from the network given input
 func (n *Network) FeedForward(m blas.Matrix) blas.Matrix {
 	for _, layer := range n.Nodes {
@@ -187,18 +324,125 @@ func (n *Network) FeedForward(m blas.Matrix) blas.Matrix {
 	return m
 }
 
-// Classify method returns a column vector with classified output
+// MaxCol type is a classification function which returns the index of the output with the highest value.
+type MaxCol struct{}
+
+func (MaxCol) Apply(out, class blas.Matrix) blas.Matrix { return class.MaxCol(out) }
+
+// threshold classifier which keeps the level so that it can be saved with the model
+type threshold struct {
+	blas.UnaryFunction
+	level float32
+}
+
+// NewThreshold function returns a classification function for multi-label or single output binary
+// classifiers which sets each class value to 1 if the output is greater than level, else 0.
+func NewThreshold(level float32) blas.UnaryFunction {
+	if blas.Implementation() == blas.OpenCL32 {
+		return threshold{blas.NewUnaryCL(fmt.Sprintf("float y = x > %ff ? 1.f : 0.f;", level)), level}
+	} else if blas.Implementation() == blas.Native64 {
+		return threshold{blas.Unary64(func(x float64) float64 {
+			if x > float64(level) {
+				return 1
+			}
+			return 0
+		}), level}
+	}
+	return threshold{blas.Unary32(func(x float32) float32 {
+		if x > level {
+			return 1
+		}
+		return 0
+	}), level}
+}
+
+// MultiLabel method returns true if the network has a binary cross entropy output layer.
+func (n *Network) MultiLabel() bool {
+	l, ok := n.Nodes[n.Layers-1].(*outLayer)
+	return ok && l.cost.Name() == "binarycrossentropy"
+}
+
+// SetClassifier method sets the function used to convert the network output to a class.
+// Set to nil for a regression network.
+func (n *Network) SetClassifier(out2class blas.UnaryFunction) {
+	n.out2class = out2class
+}
+
+// Regression method returns true if there is no classifier function set.
+func (n *Network) Regression() bool {
+	return n.out2class == nil
+}
+
+// SetTopK method sets k for the top-k accuracy and mean reciprocal rank which are calculated by GetError
+// for a single label classifier. Set to 0 to disable. Train sets this from the config.
+func (n *Network) SetTopK(k int) {
+	if k != n.topK && n.topClasses != nil {
+		n.topClasses.Release()
+		n.topClasses = nil
+	}
+	n.topK = k
+}
+
+// TopK method returns k for the top-k accuracy, or 0 if it is not set.
+func (n *Network) TopK() int {
+	return n.topK
+}
+
+// SetROC method enables calculation of the ROC and precision-recall curves and the area under each
+// by GetError for a binary classifier with one or two outputs. Train sets this from the config.
+func (n *Network) SetROC(on bool) {
+	n.roc = on
+}
+
+// ROC method returns true if the ROC and precision-recall curves are enabled.
+func (n *Network) ROC() bool {
+	return n.roc
+}
+
+// Classify method returns a column vector with classified output.
+// The output should be generated with the network in inference mode.
 func (n *Network) Classify(output blas.Matrix) blas.Matrix {
+	if n.out2class == nil {
+		panic("Classify: no classifier set for regression network")
+	}
 	n.out2class.Apply(output, n.classes)
 	return n.classes
 }
 
-// GetError method calculates the error and classification error given a set of inputs and target outputs.
+// GetError method calculates the error measures given a set of inputs and target outputs.
+// The classification error is calculated if the network has a classifier, else the regression measures.
+// For a multi-label network a sample is misclassified if any of its labels is wrong and the multi-label
+// measures are also calculated. The cost is scaled by the class and sample weights, if set.
+// For a single label classifier the top-k accuracy and mean reciprocal rank are calculated if k is set.
+// For a binary classifier the ROC and precision-recall curves are calculated if enabled using the last
+// output as the score for the positive class.
 // samples parameter is the maximum number of samples to check.
-func (n *Network) GetError(samples int, d *Data, hist *vec.Vector, hmax float32) (totalErr, classErr float32) {
+func (n *Network) GetError(samples int, d *Data, hist *vec.Vector, hmax float32) (m Metrics) {
+	if err := n.CheckInputs(d); err != nil {
+		panic(err)
+	}
+	defer n.SetTraining(n.SetTraining(false))
+	out := n.Nodes[n.Layers-1].(*outLayer)
+	weights := n.sampleWeights(d)
+	out.setWeights(nil)
+	defer out.setWeights(nil)
 	totalError := new(vec.RunningStat)
 	classError := new(vec.RunningStat)
+	reg := new(regStats)
+	labels := new(labelStats)
+	confusion := ConfusionMatrix{}
+	ranks := new(rankStats)
+	scores := new(scoreStats)
+	multiLabel := n.MultiLabel()
 	cols := d.Output.Cols()
+	topK := n.topK
+	if topK > cols {
+		topK = cols
+	}
+	if topK > 0 && n.topClasses == nil {
+		n.topClasses = blas.New(n.BatchSize, n.topK)
+	}
+	roc := n.roc && cols <= 2
 	rows := n.BatchSize
 	if rows > samples {
 		rows = samples
@@ -207,14 +451,31 @@ func (n *Network) GetError(samples int, d *Data, hist *vec.Vector, hmax float32)
 	for ix := 0; ix < samples; ix += rows {
 		// get cost per sample
 		output := n.FeedForward(d.Input.Row(ix, ix+rows))
-		cost := n.Nodes[n.Layers-1].Cost(d.Output.Row(ix, ix+rows))
+		if weights != nil {
+			out.setWeights(weights.Row(ix, ix+rows))
+		}
+		cost := out.Cost(d.Output.Row(ix, ix+rows))
 		n.errorHist.Histogram(cost, histBins, histMin, hmax)
 		// average error over dataset
 		totalError.Push(cost.Sum() / float32(rows*cols))
-		// get classification error
-		n.out2class.Apply(output, n.classes)
-		n.classes.Cmp(n.classes, d.Classes.Row(ix, ix+rows), epsilon)
-		classError.Push(n.classes.Sum() / float32(rows))
+		if n.out2class == nil {
+			reg.push(output, d.Output.Row(ix, ix+rows))
+		} else if multiLabel {
+			n.out2class.Apply(output, n.classes)
+			labels.push(n.classes, d.Classes.Row(ix, ix+rows))
+		} else {
+			if topK > 0 {
+				ranks.push(n.topClasses.TopK(output, topK), d.Classes.Row(ix, ix+rows))
+			}
+			if roc {
+				scores.push(output, d.Classes.Row(ix, ix+rows))
+			}
+			// get classification error
+			n.out2class.Apply(output, n.classes)
+			confusion.push(n.classes, d.Classes.Row(ix, ix+rows))
+			n.classes.Cmp(n.classes, d.Classes.Row(ix, ix+rows), epsilon)
+			classError.Push(n.classes.Sum() / float32(rows))
+		}
 		if n.Verbose {
 			fmt.Printf("\rtest batch: %d/%d        ", ix+rows, samples)
 		}
@@ -223,7 +484,17 @@ func (n *Network) GetError(samples int, d *Data, hist *vec.Vector, hmax float32)
 		fmt.Print("\r")
 	}
 	hist.Set(0, hmax/histBins, n.errorHist.Data(blas.ColMajor))
-	return float32(totalError.Mean), float32(classError.Mean)
+	m.Cost, m.ClassError = float32(totalError.Mean), float32(classError.Mean)
+	reg.metrics(&m)
+	labels.metrics(&m)
+	ranks.metrics(&m)
+	if roc {
+		scores.metrics(&m)
+	}
+	if confusion.Classes() > 0 {
+		m.Confusion = confusion
+	}
+	return m
 }
 
 // CheckGradient method enables gradient check every nepochs runs with max error of maxError.
@@ -240,8 +511,18 @@ func (n *Network) doCheck(input, target blas.Matrix) (ok bool) {
 	output := n.Nodes[n.Layers-1]
 	rows := float32(input.Rows())
 	ok = true
+	// reuse the same dropout masks for each evaluation
+	for _, layer := range n.Nodes {
+		if l, ok := layer.(trainingLayer); ok {
+			l.setTraining(n.training, true)
+			defer l.setTraining(n.training, false)
+		}
+	}
 	for nlayer, layer := range n.Nodes[:n.Layers-1] {
 		weight := layer.Weights()
+		if weight == nil {
+			continue
+		}
 		weightData := weight.Data(blas.RowMajor)
 		gradient := layer.Gradient()
 		gradData := gradient.Data(blas.RowMajor)
@@ -291,43 +572,87 @@ func (n *Network) doCheck(input, target blas.Matrix) (ok bool) {
 	return
 }
 
-// Train step method performs one training step. eta is the learning rate, lambda is the weight decay.
-func (n *Network) TrainStep(epoch, batch, samples int, eta, lambda, momentum float32) {
+// Train step method performs one training step. eta is the learning rate, lambda is the weight decay
+// and opt is used to update the weights. The output error is scaled by the sample weights for the batch
+// if they have been set by Train.
+func (n *Network) TrainStep(epoch, batch, samples int, eta, lambda float32, opt Optimizer) {
 	n.FeedForward(n.input)
 	// back propagate error and scale gradient
-	delta := n.Nodes[n.Layers-1].BackProp(n.output, momentum)
+	delta := n.Nodes[n.Layers-1].BackProp(n.output)
 	batchSize := float32(n.input.Rows())
 	for i := n.Layers - 2; i >= 0; i-- {
 		layer := n.Nodes[i]
-		delta = layer.BackProp(delta, momentum)
-		layer.Gradient().Scale(-eta / batchSize)
+		delta = layer.BackProp(delta)
+		if g := layer.Gradient(); g != nil {
+			g.Scale(-1 / batchSize)
+		}
 	}
 	// optionally check gradients
 	if batch == 0 && n.checkEvery > 0 && epoch%n.checkEvery == 0 {
 		n.doCheck(n.input, n.output)
 	}
+	// limit size of gradients for layers which support clipping
+	for _, layer := range n.Nodes[:n.Layers-1] {
+		if l, ok := layer.(clippedLayer); ok {
+			l.clipGradient()
+		}
+	}
 	// update weights
 	weightScale := 1 - eta*lambda/float32(samples)
-	for _, layer := range n.Nodes[:n.Layers-1] {
+	for i, layer := range n.Nodes[:n.Layers-1] {
 		w := layer.Weights()
+		if w == nil {
+			continue
+		}
 		if lambda != 0 {
 			w.Col(0, w.Cols()-1).Scale(weightScale)
 		}
-		w.Add(w, layer.Gradient(), 1)
+		opt.Update(i, w, layer.Gradient(), eta)
 	}
 }
 
+// get the optimizer, a new one is created if the config has changed
+func (n *Network) getOptimizer(cfg *Config) Optimizer {
+	if n.optimizer == nil || n.optType != cfg.Optimizer || n.optMomentum != cfg.Momentum {
+		if n.optimizer != nil {
+			n.optimizer.Release()
+		}
+		n.optimizer = NewOptimizer(cfg.Optimizer, cfg.Momentum)
+		n.optType, n.optMomentum = cfg.Optimizer, cfg.Momentum
+	}
+	return n.optimizer
+}
+
 // Train method trains the network on the given training set for one epoch.
+// The class weights, top-k and ROC settings are updated from the config if they have changed.
+// Train panics if the class weights setting is invalid so call UpdateClassWeights first to check it.
 func (n *Network) Train(s *Stats, d *Dataset, cfg *Config) {
 	if n.input == nil {
 		n.rawInput = blas.New(n.BatchSize, d.Train.Input.Cols())
 		n.input = blas.New(n.BatchSize, d.Train.Input.Cols())
 		n.output = blas.New(n.BatchSize, d.Train.Output.Cols())
 	}
+	if err := n.UpdateClassWeights(cfg, d); err != nil {
+		panic(err)
+	}
+	if err := n.CheckInputs(d.Train); err != nil {
+		panic(err)
+	}
+	n.SetTopK(cfg.TopK)
+	n.SetROC(cfg.ROC)
+	out := n.Nodes[n.Layers-1].(*outLayer)
+	weights := n.sampleWeights(d.Train)
+	if weights != nil && n.weights == nil {
+		n.weights = blas.New(n.BatchSize, 1)
+	}
+	defer n.SetTraining(n.SetTraining(true))
 	s.Epoch++
 	s.StartEpoch = time.Now()
 	smp := NewSampler(cfg.Sampler).Init(d.Train.NumSamples, n.BatchSize)
+	opt := n.getOptimizer(cfg)
+	batches := (d.Train.NumSamples + n.BatchSize - 1) / n.BatchSize
 	batch := 0
+	var eta float32
 	for {
 		smp.Sample(d.Train.Input, n.rawInput)
 		if cfg.Distortion > 0 {
@@ -337,7 +662,14 @@ func (n *Network) Train(s *Stats, d *Dataset, cfg *Config) {
 			n.input = n.rawInput
 		}
 		smp.Sample(d.Train.Output, n.output)
-		n.TrainStep(s.Epoch, batch, d.Train.NumSamples, cfg.LearnRate, cfg.WeightDecay, cfg.Momentum)
+		if weights != nil {
+			smp.Sample(weights, n.weights)
+			out.setWeights(n.weights)
+		} else {
+			out.setWeights(nil)
+		}
+		eta = LearnRate(cfg, s, s.Epoch, float32(batch+1)/float32(batches))
+		n.TrainStep(s.Epoch, batch, d.Train.NumSamples, eta, cfg.WeightDecay, opt)
 		batch++
 		if n.Verbose {
 			fmt.Printf("\rtrain batch: %d/%d        ", batch, d.Train.NumSamples/n.BatchSize)
@@ -347,4 +679,5 @@ func (n *Network) Train(s *Stats, d *Dataset, cfg *Config) {
 		}
 	}
 	smp.Release()
+	s.LearnRate.Push(eta, 0)
 }
@@ -2,8 +2,11 @@ package network_test
 
 import (
 	"github.com/jnb666/deepthought/blas"
+	"github.com/jnb666/deepthought/config"
 	"github.com/jnb666/deepthought/network"
 	_ "github.com/jnb666/deepthought/network/iris"
+	"io/ioutil"
+	"os"
 	"testing"
 )
 
@@ -60,3 +63,37 @@ func TestTrain(t *testing.T) {
 	}
 	t.Log(net)
 }
+
+func TestLoadArchitecture(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deepthought")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(prev string) { config.ConfigDir = prev }(config.ConfigDir)
+	config.ConfigDir = dir
+	arch := `{"Layers": [{"Type": "dense", "Nodes": 6}, {"Type": "dense", "Activation": "tanh"}],
+		"Output": {"Cost": "crossentropy"}}`
+	if err = ioutil.WriteFile(dir+"/iris.net", []byte(arch), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, net, d, err := network.Load("iris", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer net.Release()
+	if net.Layers != 3 || net.Nodes[1].Dims()[0] != 6 {
+		t.Fatal("network not built from architecture file")
+	}
+	if err = ioutil.WriteFile(dir+"/iris.net", []byte(`{"Layers": [{"Type": "dense", "Nodes": 6}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, d2, err := network.Load("iris", 0); err == nil {
+		t.Error("expected error loading invalid architecture")
+	} else if d2 != nil {
+		t.Error("dataset should be released on error")
+	} else {
+		t.Log(err)
+	}
+	d.Release()
+}
@@ -0,0 +1,325 @@
+package network
+
+import (
+	"fmt"
+	"github.com/jnb666/deepthought/blas"
+	"github.com/jnb666/deepthought/onnx"
+	"io"
+	"os"
+	"strings"
+)
+
+var onnxActivations = map[string]string{
+	"sigmoid": "Sigmoid",
+	"tanh":    "Tanh",
+	"relu":    "Relu",
+	"softmax": "Softmax",
+}
+
+// ExportONNX method writes the network to w as an ONNX model for use with other inference engines.
+// The graph has a single input named "input" with shape [N, inputs] and output named "output".
+// Dropout layers are omitted and batch normalisation uses the running mean and variance.
+// Convolution, pooling and recurrent layers are not currently supported.
+func (n *Network) ExportONNX(w io.Writer) error {
+	m, err := n.onnxModel()
+	if err != nil {
+		return fmt.Errorf("ExportONNX: %s", err)
+	}
+	return m.Write(w)
+}
+
+func (n *Network) onnxModel() (*onnx.Model, error) {
+	specs, err := n.specs()
+	if err != nil {
+		return nil, err
+	}
+	g := &onnx.Graph{Name: "deepthought"}
+	x := "input"
+	// add a new node which takes x as the first input and set x to its output
+	addNode := func(op, name string, inputs []string, attrs ...*onnx.Attribute) {
+		g.Nodes = append(g.Nodes, onnx.NewNode(op, name, append([]string{x}, inputs...), []string{name}, attrs...))
+		x = name
+	}
+	for i, s := range specs {
+		if op, ok := onnxActivations[s.Activation]; ok {
+			name := fmt.Sprintf("%s%d", s.Activation, i)
+			if op == "Softmax" {
+				addNode(op, name, nil, onnx.IntAttr("axis", 1))
+			} else {
+				addNode(op, name, nil)
+			}
+		}
+		switch s.Type {
+		case "dense":
+			// weights have the bias in the last column
+			nin := int64(len(s.Weights)/s.Nodes - 1)
+			weight, bias := make([]float32, 0, int64(s.Nodes)*nin), make([]float32, 0, s.Nodes)
+			for j := 0; j < s.Nodes; j++ {
+				row := s.Weights[j*int(nin+1) : (j+1)*int(nin+1)]
+				weight = append(weight, row[:nin]...)
+				bias = append(bias, row[nin])
+			}
+			name := fmt.Sprintf("dense%d", i)
+			g.Initializers = append(g.Initializers,
+				onnx.NewTensor(name+"_W", weight, int64(s.Nodes), nin),
+				onnx.NewTensor(name+"_B", bias, int64(s.Nodes)))
+			addNode("Gemm", name, []string{name + "_W", name + "_B"}, onnx.IntAttr("transB", 1))
+		case "batchnorm":
+			nin := len(s.Mean)
+			scale, shift := make([]float32, nin), make([]float32, nin)
+			for j := range scale {
+				scale[j], shift[j] = s.Weights[2*j], s.Weights[2*j+1]
+			}
+			name := fmt.Sprintf("batchnorm%d", i)
+			g.Initializers = append(g.Initializers,
+				onnx.NewTensor(name+"_scale", scale, int64(nin)),
+				onnx.NewTensor(name+"_B", shift, int64(nin)),
+				onnx.NewTensor(name+"_mean", s.Mean, int64(nin)),
+				onnx.NewTensor(name+"_var", s.Var, int64(nin)))
+			addNode("BatchNormalization", name, []string{name + "_scale", name + "_B", name + "_mean", name + "_var"},
+				onnx.FloatAttr("epsilon", bnEpsilon))
+		case "dropout":
+		default:
+			// the output layer only applies the activation
+			if i < len(specs)-1 {
+				return nil, fmt.Errorf("%s layer is not supported", s.Type)
+			}
+		}
+	}
+	// rename the output of the final node
+	if len(g.Nodes) == 0 {
+		addNode("Identity", "output", nil)
+	}
+	g.Nodes[len(g.Nodes)-1].Outputs[0] = "output"
+	nin := 1
+	for _, d := range specs[0].Dims {
+		nin *= d
+	}
+	nout := specs[len(specs)-1].Nodes
+	g.Inputs = []*onnx.ValueInfo{
+		{Name: "input", ElemType: onnx.Float, Dims: []int64{-1, int64(nin)}, Params: []string{"N", ""}},
+	}
+	g.Outputs = []*onnx.ValueInfo{
+		{Name: "output", ElemType: onnx.Float, Dims: []int64{-1, int64(nout)}, Params: []string{"N", ""}},
+	}
+	return onnx.NewModel(g), nil
+}
+
+// linear layer or activation function parsed from an ONNX graph
+type onnxOp struct {
+	activ   string
+	weights []float32 // [nout, nin+1] with bias in last column
+	nin     int
+	nout    int
+}
+
+// ImportONNX function reads a feed forward network from an ONNX model. The graph should be a chain of
+// Gemm or MatMul and Add nodes with optional elementwise Relu, Sigmoid, Tanh or Softmax activations.
+// A final softmax activation is converted to a cross entropy output layer, otherwise a quadratic output
+// layer is used. The network classifies using the index of the output with the highest value.
+func ImportONNX(r io.Reader, batchSize int) (*Network, error) {
+	m, err := onnx.Read(r)
+	if err != nil {
+		return nil, fmt.Errorf("ImportONNX: %s", err)
+	}
+	ops, err := parseONNX(m.Graph)
+	if err != nil {
+		return nil, fmt.Errorf("ImportONNX: %s", err)
+	}
+	n := New(batchSize, MaxCol{})
+	activ := Linear
+	nout := 0
+	for _, op := range ops {
+		if op.weights == nil {
+			if activ.Name != "linear" {
+				n.Release()
+				return nil, fmt.Errorf("ImportONNX: %s activation cannot follow %s", op.activ, activ.Name)
+			}
+			activ, _ = activation(op.activ)
+			continue
+		}
+		if nout > 0 && op.nin != nout {
+			n.Release()
+			return nil, fmt.Errorf("ImportONNX: layer %d has %d inputs, expecting %d", n.Layers, op.nin, nout)
+		}
+		n.AddLayer([]int{op.nin}, op.nout, activ)
+		n.Nodes[n.Layers-1].Weights().Load(blas.RowMajor, op.weights...)
+		activ, nout = Linear, op.nout
+	}
+	if nout == 0 {
+		n.Release()
+		return nil, fmt.Errorf("ImportONNX: no Gemm or MatMul nodes found")
+	}
+	if activ.Name == "softmax" {
+		n.AddCrossEntropyOutput(nout)
+	} else {
+		n.AddQuadraticOutput(nout, activ)
+	}
+	return n, nil
+}
+
+// ImportONNXFile function imports a network from an ONNX file to use with dataset d in place of the network
+// base which was created for the dataset. The batch size and input dimensions are taken from base and the
+// number of inputs and outputs are checked against the dataset. The dataset classification function is used.
+func ImportONNXFile(file string, d *Dataset, base *Network) (*Network, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	n, err := ImportONNX(f, base.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	nin := n.Nodes[0].Weights().Cols() - 1
+	nout := n.Nodes[n.Layers-1].Dims()[0]
+	if nin != d.NumInputs || nout != d.NumOutputs {
+		n.Release()
+		return nil, fmt.Errorf("ImportONNXFile: model has %d inputs and %d outputs, dataset has %d and %d",
+			nin, nout, d.NumInputs, d.NumOutputs)
+	}
+	if dims := base.Nodes[0].Dims(); len(dims) > 1 {
+		n.Nodes[0].(*layer).dims = dims
+	}
+	n.SetClassifier(d.OutputToClass)
+	return n, nil
+}
+
+// convert graph nodes to a list of layers and activations
+func parseONNX(g *onnx.Graph) (ops []onnxOp, err error) {
+	var unsupported []string
+	found := map[string]bool{}
+	for _, node := range g.Nodes {
+		switch node.OpType {
+		case "Gemm", "MatMul", "Add", "Relu", "Sigmoid", "Tanh", "Softmax", "Identity", "Dropout":
+		default:
+			if !found[node.OpType] {
+				unsupported = append(unsupported, node.OpType)
+				found[node.OpType] = true
+			}
+		}
+	}
+	if len(unsupported) > 0 {
+		return nil, fmt.Errorf("unsupported node types: %s", strings.Join(unsupported, ", "))
+	}
+	if len(g.Inputs) == 0 {
+		return nil, fmt.Errorf("graph has no inputs")
+	}
+	// the input is the first graph input which is not an initializer
+	x := ""
+	for _, v := range g.Inputs {
+		if g.Initializer(v.Name) == nil {
+			x = v.Name
+			break
+		}
+	}
+	for _, node := range g.Nodes {
+		// bias may be either input of an Add node
+		in, bias := 0, 1
+		if node.OpType == "Add" && len(node.Inputs) == 2 && node.Inputs[1] == x {
+			in, bias = 1, 0
+		}
+		if len(node.Inputs) == 0 || node.Inputs[in] != x || len(node.Outputs) == 0 {
+			return nil, fmt.Errorf("%s node %s: graph must be a single chain of nodes", node.OpType, node.Name)
+		}
+		x = node.Outputs[0]
+		switch node.OpType {
+		case "Gemm":
+			var op onnxOp
+			if op, err = gemmWeights(g, node); err != nil {
+				return nil, err
+			}
+			ops = append(ops, op)
+		case "MatMul":
+			var op onnxOp
+			if op, err = matMulWeights(g, node); err != nil {
+				return nil, err
+			}
+			ops = append(ops, op)
+		case "Add":
+			if len(ops) == 0 || ops[len(ops)-1].weights == nil {
+				return nil, fmt.Errorf("Add node %s must follow MatMul or Gemm", node.Name)
+			}
+			if err = setBias(g, node, bias, 1, &ops[len(ops)-1]); err != nil {
+				return nil, err
+			}
+		case "Relu", "Sigmoid", "Tanh":
+			ops = append(ops, onnxOp{activ: strings.ToLower(node.OpType)})
+		case "Softmax":
+			if axis := node.AttrInt("axis", -1); axis != -1 && axis != 1 {
+				return nil, fmt.Errorf("Softmax node %s: axis %d is not supported", node.Name, axis)
+			}
+			ops = append(ops, onnxOp{activ: "softmax"})
+		}
+	}
+	return ops, nil
+}
+
+// get 2d constant tensor from graph initializers
+func onnxMatrix(g *onnx.Graph, node *onnx.Node, i int) (*onnx.Tensor, error) {
+	if i >= len(node.Inputs) {
+		return nil, fmt.Errorf("%s node %s: missing input %d", node.OpType, node.Name, i)
+	}
+	t := g.Initializer(node.Inputs[i])
+	if t == nil || len(t.Dims) != 2 || len(t.Data) != t.Size() {
+		return nil, fmt.Errorf("%s node %s: input %s must be a 2d float initializer", node.OpType, node.Name, node.Inputs[i])
+	}
+	return t, nil
+}
+
+// weights from Gemm node with Y = alpha*X*B + beta*C
+func gemmWeights(g *onnx.Graph, node *onnx.Node) (op onnxOp, err error) {
+	if node.AttrInt("transA", 0) != 0 {
+		return op, fmt.Errorf("Gemm node %s: transA is not supported", node.Name)
+	}
+	b, err := onnxMatrix(g, node, 1)
+	if err != nil {
+		return op, err
+	}
+	op = newOnnxOp(b, node.AttrInt("transB", 0) != 0, node.AttrFloat("alpha", 1))
+	if len(node.Inputs) > 2 && node.Inputs[2] != "" {
+		err = setBias(g, node, 2, node.AttrFloat("beta", 1), &op)
+	}
+	return op, err
+}
+
+// weights from MatMul node with Y = X*B
+func matMulWeights(g *onnx.Graph, node *onnx.Node) (op onnxOp, err error) {
+	b, err := onnxMatrix(g, node, 1)
+	if err != nil {
+		return op, err
+	}
+	return newOnnxOp(b, false, 1), nil
+}
+
+// set layer weights from matrix b with shape [nin, nout] or [nout, nin] if transposed
+func newOnnxOp(b *onnx.Tensor, trans bool, scale float32) onnxOp {
+	nin, nout := int(b.Dims[0]), int(b.Dims[1])
+	if trans {
+		nin, nout = nout, nin
+	}
+	op := onnxOp{nin: nin, nout: nout, weights: make([]float32, nout*(nin+1))}
+	for i := 0; i < nin; i++ {
+		for j := 0; j < nout; j++ {
+			ix := i*nout + j
+			if trans {
+				ix = j*nin + i
+			}
+			op.weights[j*(nin+1)+i] = scale * b.Data[ix]
+		}
+	}
+	return op
+}
+
+// add bias from node input i which should be a vector of size nout
+func setBias(g *onnx.Graph, node *onnx.Node, i int, scale float32, op *onnxOp) error {
+	t := g.Initializer(node.Inputs[i])
+	if t == nil || len(t.Data) != t.Size() || (t.Size() != op.nout && t.Size() != 1) {
+		return fmt.Errorf("%s node %s: bias %s must be a float initializer of size %d", node.OpType, node.Name,
+			node.Inputs[i], op.nout)
+	}
+	for j := 0; j < op.nout; j++ {
+		op.weights[j*(op.nin+1)+op.nin] += scale * t.Data[j%t.Size()]
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package network
+
+import (
+	"bytes"
+	"github.com/jnb666/deepthought/blas"
+	"github.com/jnb666/deepthought/onnx"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportONNX(t *testing.T) {
+	rand.Seed(1)
+	batch, nin := 6, 5
+	n := New(batch, MaxCol{})
+	n.AddLayer([]int{nin}, 8, Linear)
+	n.AddBatchNormLayer([]int{8}, Relu)
+	n.AddDropoutLayer([]int{8}, 0.5, Tanh)
+	n.AddLayer([]int{8}, 4, Sigmoid)
+	n.AddCrossEntropyOutput(4)
+	defer n.Release()
+	n.SetRandomWeights()
+	input := randMatrix(batch, nin)
+	// update the batch norm running stats
+	n.SetTraining(true)
+	n.FeedForward(input)
+	n.SetTraining(false)
+	expect := n.FeedForward(input).Data(blas.RowMajor)
+
+	var buf bytes.Buffer
+	if err := n.ExportONNX(&buf); err != nil {
+		t.Fatal(err)
+	}
+	m, err := onnx.Unmarshal(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("exported %d bytes: ir version %d opset %d", buf.Len(), m.IRVersion, m.Opset)
+	var ops []string
+	for _, node := range m.Graph.Nodes {
+		t.Logf("%-20s %-12s %v => %v", node.OpType, node.Name, node.Inputs, node.Outputs)
+		ops = append(ops, node.OpType)
+	}
+	expectOps := []string{"Gemm", "Relu", "BatchNormalization", "Tanh", "Sigmoid", "Gemm", "Softmax"}
+	if !reflect.DeepEqual(ops, expectOps) {
+		t.Fatalf("expected nodes %v got %v", expectOps, ops)
+	}
+	if w := m.Graph.Initializer("dense0_W"); w == nil || !reflect.DeepEqual(w.Dims, []int64{8, int64(nin)}) {
+		t.Error("dense0_W initializer has wrong shape")
+	}
+	in, out := m.Graph.Inputs[0], m.Graph.Outputs[0]
+	if in.Name != "input" || in.Dims[1] != int64(nin) || out.Name != "output" || out.Dims[1] != 4 {
+		t.Errorf("wrong graph inputs or outputs: %+v %+v", in, out)
+	}
+	res, err := m.Graph.Run(map[string]*onnx.Tensor{
+		"input": onnx.NewTensor("input", input.Data(blas.RowMajor), int64(batch), int64(nin)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := res["output"].Data
+	for i := range expect {
+		if d := output[i] - expect[i]; d < -1e-5 || d > 1e-5 {
+			t.Fatalf("output mismatch:\n%v\n%v", output, expect)
+		}
+	}
+}
+
+func TestExportONNXUnsupported(t *testing.T) {
+	n := New(2, nil)
+	dims := n.AddConvLayer([]int{4, 4}, 2, 3, 1, 1, Linear)
+	n.AddLayer(dims, 2, Relu)
+	n.AddQuadraticOutput(2, Linear)
+	defer n.Release()
+	if err := n.ExportONNX(new(bytes.Buffer)); err == nil {
+		t.Error("expected error exporting convolution layer")
+	}
+}
+
+func TestImportONNX(t *testing.T) {
+	rand.Seed(1)
+	batch, nin := 6, 5
+	n := New(batch, MaxCol{})
+	n.AddLayer([]int{nin}, 8, Linear)
+	n.AddLayer([]int{8}, 4, Relu)
+	n.AddCrossEntropyOutput(4)
+	defer n.Release()
+	n.SetRandomWeights()
+	input := randMatrix(batch, nin)
+	expect := n.FeedForward(input).Data(blas.RowMajor)
+	var buf bytes.Buffer
+	if err := n.ExportONNX(&buf); err != nil {
+		t.Fatal(err)
+	}
+	n2, err := ImportONNX(&buf, batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n2.Release()
+	if n2.Layers != 3 {
+		t.Fatalf("expected 3 layers got %d", n2.Layers)
+	}
+	checkEqual(t, n2.FeedForward(input).Data(blas.RowMajor), expect)
+}
+
+func TestImportMatMul(t *testing.T) {
+	// y = sigmoid(relu(x*W + b)*W2) with bias as the first input to Add
+	g := &onnx.Graph{
+		Nodes: []*onnx.Node{
+			onnx.NewNode("MatMul", "matmul", []string{"x", "W"}, []string{"y"}),
+			onnx.NewNode("Add", "add", []string{"b", "y"}, []string{"z"}),
+			onnx.NewNode("Relu", "relu", []string{"z"}, []string{"r"}),
+			onnx.NewNode("Gemm", "gemm", []string{"r", "W2"}, []string{"g"}, onnx.FloatAttr("alpha", 0.5)),
+			onnx.NewNode("Sigmoid", "sigmoid", []string{"g"}, []string{"out"}),
+		},
+		Initializers: []*onnx.Tensor{
+			onnx.NewTensor("W", []float32{1, -1, 2, 0.5, -3, 1}, 3, 2),
+			onnx.NewTensor("b", []float32{0.5, -0.5}, 2),
+			onnx.NewTensor("W2", []float32{1, 2, -1, 3}, 2, 2),
+		},
+		Inputs:  []*onnx.ValueInfo{{Name: "x", ElemType: onnx.Float, Dims: []int64{-1, 3}, Params: []string{"N", ""}}},
+		Outputs: []*onnx.ValueInfo{{Name: "out", ElemType: onnx.Float, Dims: []int64{-1, 2}, Params: []string{"N", ""}}},
+	}
+	data := []float32{1, 0, 0, 0, 1, 1, 0.5, -1, 2, 1, 1, 1}
+	res, err := g.Run(map[string]*onnx.Tensor{"x": onnx.NewTensor("x", data, 4, 3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := ImportONNX(bytes.NewReader(onnx.NewModel(g).Marshal()), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Release()
+	input := blas.New(4, 3).Load(blas.RowMajor, data...)
+	output := n.FeedForward(input).Data(blas.RowMajor)
+	for i, x := range res["out"].Data {
+		if d := output[i] - x; d < -1e-5 || d > 1e-5 {
+			t.Fatalf("output mismatch:\n%v\n%v", output, res["out"].Data)
+		}
+	}
+}
+
+func TestImportUnsupported(t *testing.T) {
+	g := &onnx.Graph{
+		Nodes: []*onnx.Node{
+			onnx.NewNode("Conv", "conv", []string{"x", "W"}, []string{"y"}),
+			onnx.NewNode("Relu", "relu", []string{"y"}, []string{"z"}),
+			onnx.NewNode("MaxPool", "pool", []string{"z"}, []string{"p"}),
+			onnx.NewNode("Conv", "conv2", []string{"p", "W"}, []string{"out"}),
+		},
+		Inputs: []*onnx.ValueInfo{{Name: "x"}},
+	}
+	_, err := ImportONNX(bytes.NewReader(onnx.NewModel(g).Marshal()), 4)
+	if err == nil || !strings.Contains(err.Error(), "unsupported node types: Conv, MaxPool") {
+		t.Error("expected error listing unsupported nodes, got", err)
+	}
+}
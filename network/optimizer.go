@@ -0,0 +1,251 @@
+package network
+
+import (
+	"github.com/jnb666/deepthought/blas"
+	"math"
+)
+
+const (
+	optEpsilon = 1e-8  // added to denominator to avoid divide by zero
+	rmsDecay   = 0.9   // decay rate for RMSProp moving average
+	adamBeta1  = 0.9   // decay rate for Adam first moment estimate
+	adamBeta2  = 0.999 // decay rate for Adam second moment estimate
+)
+
+var optimizers = map[string]func(momentum float32) Optimizer{
+	"sgd":      func(m float32) Optimizer { return &sgdOptimizer{momentum: m, state: newOptState(1)} },
+	"nesterov": func(m float32) Optimizer { return &sgdOptimizer{momentum: m, nesterov: true, state: newOptState(2)} },
+	"adagrad":  func(m float32) Optimizer { return &adaGradOptimizer{ratio: newRatio(), state: newOptState(2)} },
+	"rmsprop":  func(m float32) Optimizer { return &rmsPropOptimizer{ratio: newRatio(), state: newOptState(2)} },
+	"adam":     func(m float32) Optimizer { return &adamOptimizer{ratio: newRatio(), state: newOptState(3)} },
+}
+
+var OptimizerNames = []string{"sgd", "nesterov", "adagrad", "rmsprop", "adam"}
+
+// Optimizer interface is used to update the weights at each training step.
+type Optimizer interface {
+	// Update method updates the weights for layer with index id. The gradient is the negative of the
+	// derivative of the cost averaged over the batch and eta is the learning rate.
+	Update(id int, weights, gradient blas.Matrix, eta float32)
+	Release()
+}
+
+// NewOptimizer function creates a new optimizer of the given type. Default is sgd.
+// The momentum parameter is only used by the sgd and nesterov optimizers.
+func NewOptimizer(typ string, momentum float32) Optimizer {
+	if typ == "" {
+		typ = "sgd"
+	}
+	if fn, ok := optimizers[typ]; ok {
+		return fn(momentum)
+	}
+	panic("optimizer of type " + typ + " not found")
+}
+
+// per layer state with n matrices the same size as the weights which are initialised to zero,
+// the last matrix may be used as work space
+type optState struct {
+	n      int
+	values map[int][]blas.Matrix
+}
+
+func newOptState(n int) *optState {
+	return &optState{n: n, values: map[int][]blas.Matrix{}}
+}
+
+func (s *optState) get(id int, weights blas.Matrix) []blas.Matrix {
+	m, ok := s.values[id]
+	if !ok {
+		m = make([]blas.Matrix, s.n)
+		for i := range m {
+			m[i] = blas.New(weights.Rows(), weights.Cols()).Set(0)
+		}
+		s.values[id] = m
+	}
+	return m
+}
+
+func (s *optState) Release() {
+	for _, m := range s.values {
+		for _, v := range m {
+			v.Release()
+		}
+	}
+	s.values = map[int][]blas.Matrix{}
+}
+
+// optimizers which can save and restore their state
+type savedOptimizer interface {
+	save() optSnapshot
+	load(s optSnapshot, weights func(id int) blas.Matrix)
+}
+
+// optSnapshot has the saved state for each layer in row major order with the number of steps for Adam
+type optSnapshot struct {
+	Values map[int][][]float32
+	Steps  map[int]int
+}
+
+func (s *optState) save() map[int][][]float32 {
+	values := map[int][][]float32{}
+	for id, m := range s.values {
+		for _, v := range m {
+			values[id] = append(values[id], v.Data(blas.RowMajor))
+		}
+	}
+	return values
+}
+
+// restore the saved state, weights is used to get the size of the matrices for each layer
+func (s *optState) load(values map[int][][]float32, weights func(id int) blas.Matrix) {
+	s.Release()
+	for id, data := range values {
+		m := s.get(id, weights(id))
+		for i := range m {
+			m[i].Load(blas.RowMajor, data[i]...)
+		}
+	}
+}
+
+// function to calculate x / (sqrt(y) + epsilon)
+func newRatio() blas.BinaryFunction {
+	if blas.Implementation() == blas.OpenCL32 {
+		return blas.NewBinaryCL("float z = x / (sqrt(y) + 1e-8f);")
+	} else if blas.Implementation() == blas.Native64 {
+		return blas.Binary64(func(x, y float64) float64 { return x / (math.Sqrt(y) + optEpsilon) })
+	}
+	return blas.Binary32(func(x, y float32) float32 { return x / (float32(math.Sqrt(float64(y))) + optEpsilon) })
+}
+
+// stochastic gradient descent with classical or Nesterov momentum
+type sgdOptimizer struct {
+	momentum float32
+	nesterov bool
+	state    *optState
+}
+
+func (o *sgdOptimizer) Update(id int, w, g blas.Matrix, eta float32) {
+	if o.momentum == 0 {
+		w.Add(w, g, eta)
+		return
+	}
+	s := o.state.get(id, w)
+	v := s[0]
+	if o.nesterov {
+		// w += -momentum*v_prev + (1+momentum)*v
+		s[1].Copy(v, nil)
+		v.Scale(o.momentum).Add(v, g, eta)
+		w.Add(w, s[1], -o.momentum)
+		w.Add(w, v, 1+o.momentum)
+	} else {
+		v.Scale(o.momentum).Add(v, g, eta)
+		w.Add(w, v, 1)
+	}
+}
+
+func (o *sgdOptimizer) Release() {
+	o.state.Release()
+}
+
+func (o *sgdOptimizer) save() optSnapshot {
+	return optSnapshot{Values: o.state.save()}
+}
+
+func (o *sgdOptimizer) load(s optSnapshot, weights func(id int) blas.Matrix) {
+	o.state.load(s.Values, weights)
+}
+
+// AdaGrad scales the learning rate by the inverse root of the sum of squared gradients
+type adaGradOptimizer struct {
+	ratio blas.BinaryFunction
+	state *optState
+}
+
+func (o *adaGradOptimizer) Update(id int, w, g blas.Matrix, eta float32) {
+	s := o.state.get(id, w)
+	h, step := s[0], s[1]
+	h.Add(h, step.MulElem(g, g), 1)
+	o.ratio.Apply(g, h, step)
+	w.Add(w, step, eta)
+}
+
+func (o *adaGradOptimizer) Release() {
+	o.state.Release()
+}
+
+func (o *adaGradOptimizer) save() optSnapshot {
+	return optSnapshot{Values: o.state.save()}
+}
+
+func (o *adaGradOptimizer) load(s optSnapshot, weights func(id int) blas.Matrix) {
+	o.state.load(s.Values, weights)
+}
+
+// RMSProp scales the learning rate by the inverse root of a moving average of squared gradients
+type rmsPropOptimizer struct {
+	ratio blas.BinaryFunction
+	state *optState
+}
+
+func (o *rmsPropOptimizer) Update(id int, w, g blas.Matrix, eta float32) {
+	s := o.state.get(id, w)
+	h, step := s[0], s[1]
+	h.Scale(rmsDecay).Add(h, step.MulElem(g, g), 1-rmsDecay)
+	o.ratio.Apply(g, h, step)
+	w.Add(w, step, eta)
+}
+
+func (o *rmsPropOptimizer) Release() {
+	o.state.Release()
+}
+
+func (o *rmsPropOptimizer) save() optSnapshot {
+	return optSnapshot{Values: o.state.save()}
+}
+
+func (o *rmsPropOptimizer) load(s optSnapshot, weights func(id int) blas.Matrix) {
+	o.state.load(s.Values, weights)
+}
+
+// Adam uses bias corrected moving averages of the gradient and squared gradient
+type adamOptimizer struct {
+	ratio blas.BinaryFunction
+	state *optState
+	steps map[int]int
+}
+
+func (o *adamOptimizer) Update(id int, w, g blas.Matrix, eta float32) {
+	if o.steps == nil {
+		o.steps = map[int]int{}
+	}
+	o.steps[id]++
+	t := float64(o.steps[id])
+	s := o.state.get(id, w)
+	m, v, step := s[0], s[1], s[2]
+	m.Scale(adamBeta1).Add(m, g, 1-adamBeta1)
+	v.Scale(adamBeta2).Add(v, step.MulElem(g, g), 1-adamBeta2)
+	o.ratio.Apply(m, v, step)
+	rate := eta * float32(math.Sqrt(1-math.Pow(adamBeta2, t))/(1-math.Pow(adamBeta1, t)))
+	w.Add(w, step, rate)
+}
+
+func (o *adamOptimizer) Release() {
+	o.state.Release()
+	o.steps = nil
+}
+
+func (o *adamOptimizer) save() optSnapshot {
+	steps := map[int]int{}
+	for id, n := range o.steps {
+		steps[id] = n
+	}
+	return optSnapshot{Values: o.state.save(), Steps: steps}
+}
+
+func (o *adamOptimizer) load(s optSnapshot, weights func(id int) blas.Matrix) {
+	o.state.load(s.Values, weights)
+	o.steps = map[int]int{}
+	for id, n := range s.Steps {
+		o.steps[id] = n
+	}
+}
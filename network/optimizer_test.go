@@ -0,0 +1,33 @@
+package network
+
+import (
+	"github.com/jnb666/deepthought/blas"
+	"testing"
+)
+
+// minimise the quadratic cost 0.5*|w-target|^2 with each optimizer
+func TestOptimizers(t *testing.T) {
+	target := []float32{1, -2, 3, 0.5, -0.25, 4}
+	rate := map[string]float32{"sgd": 0.05, "nesterov": 0.05, "adagrad": 0.5, "rmsprop": 0.05, "adam": 0.05}
+	for _, name := range OptimizerNames {
+		opt := NewOptimizer(name, 0.5)
+		w := blas.New(2, 3).Set(0)
+		tgt := blas.New(2, 3).Load(blas.RowMajor, target...)
+		g := blas.New(2, 3)
+		for step := 0; step < 1000; step++ {
+			// decay the learning rate so that rmsprop and adam settle on the target
+			g.Add(tgt, w, -1)
+			opt.Update(0, w, g, rate[name]/(1+float32(step)/100))
+		}
+		t.Logf("%-8s: %v", name, w.Data(blas.RowMajor))
+		for i, x := range w.Data(blas.RowMajor) {
+			if d := x - target[i]; d < -1e-2 || d > 1e-2 {
+				t.Errorf("%s: expected %g got %g", name, target[i], x)
+			}
+		}
+		opt.Release()
+		w.Release()
+		tgt.Release()
+		g.Release()
+	}
+}
@@ -0,0 +1,124 @@
+package network
+
+import (
+	"fmt"
+	"github.com/jnb666/deepthought/blas"
+)
+
+type poolLayer struct {
+	dims   []int
+	pool   blas.PoolDims
+	max    bool
+	activ  Activation
+	input  blas.Matrix // Z matrix of value at each node [samples, nin]
+	output blas.Matrix // return value at each node [samples, nout]
+	index  blas.Matrix // input column of max value for max pooling [samples, nout]
+	deriv  blas.Matrix // Fp matrix of derivative of activation fn [samples, nin]
+	delta  blas.Matrix // D matrix of errors at each node [samples, nin]
+}
+
+// AddMaxPoolLayer method adds a new max pooling layer to the network with given window size and stride.
+// inDims are the input dimensions as [height, width] or [height, width, channels].
+// Returns the output dimensions in the same format which can be used to size the next layer.
+func (n *Network) AddMaxPoolLayer(inDims []int, size, stride int, a Activation) (outDims []int) {
+	return n.addPoolLayer(inDims, size, stride, a, true)
+}
+
+// AddAvgPoolLayer method adds a new average pooling layer to the network with given window size and stride.
+// inDims are the input dimensions as [height, width] or [height, width, channels].
+// Returns the output dimensions in the same format which can be used to size the next layer.
+func (n *Network) AddAvgPoolLayer(inDims []int, size, stride int, a Activation) (outDims []int) {
+	return n.addPoolLayer(inDims, size, stride, a, false)
+}
+
+func (n *Network) addPoolLayer(inDims []int, size, stride int, a Activation, max bool) []int {
+	d := poolDims(inDims, size, stride)
+	batch := n.BatchSize
+	l := &poolLayer{
+		dims:   inDims,
+		pool:   d,
+		max:    max,
+		activ:  a,
+		input:  blas.New(batch, d.InSize()),
+		output: blas.New(batch, d.OutSize()),
+	}
+	if max {
+		l.index = blas.New(batch, d.OutSize())
+	}
+	if a.Deriv != nil {
+		l.deriv = blas.New(batch, d.InSize())
+	}
+	if n.Layers > 0 {
+		l.delta = blas.New(batch, d.InSize())
+	}
+	n.add(l)
+	return []int{d.OutHeight(), d.OutWidth(), d.Channels}
+}
+
+func poolDims(inDims []int, size, stride int) blas.PoolDims {
+	if len(inDims) < 2 || len(inDims) > 3 {
+		panic(fmt.Sprintf("pooling layer: invalid input dims %v", inDims))
+	}
+	d := blas.PoolDims{Height: inDims[0], Width: inDims[1], Channels: 1, Size: size, Stride: stride}
+	if len(inDims) == 3 {
+		d.Channels = inDims[2]
+	}
+	if d.Size < 1 || d.Stride < 1 || d.OutWidth() < 1 || d.OutHeight() < 1 {
+		panic(fmt.Sprintf("pooling layer: invalid dims %s", d))
+	}
+	return d
+}
+
+func (l *poolLayer) Dims() []int {
+	return l.dims
+}
+
+func (l *poolLayer) Values() blas.Matrix {
+	return l.input
+}
+
+func (l *poolLayer) Release() {
+	l.input.Release()
+	l.output.Release()
+	if l.index != nil {
+		l.index.Release()
+	}
+	if l.deriv != nil {
+		l.deriv.Release()
+	}
+	if l.delta != nil {
+		l.delta.Release()
+	}
+}
+
+func (l *poolLayer) Weights() blas.Matrix { return nil }
+
+func (l *poolLayer) Gradient() blas.Matrix { return nil }
+
+func (l *poolLayer) Cost(t blas.Matrix) blas.Matrix { panic("no cost for pooling layer!") }
+
+func (l *poolLayer) FeedForward(in blas.Matrix) blas.Matrix {
+	l.activ.Func.Apply(in, l.input)
+	if l.activ.Deriv != nil {
+		l.activ.Deriv.Apply(in, l.deriv)
+	}
+	if l.max {
+		return l.output.MaxPool(l.input, l.index, l.pool)
+	}
+	return l.output.AvgPool(l.input, l.pool)
+}
+
+func (l *poolLayer) BackProp(err blas.Matrix) blas.Matrix {
+	if l.delta == nil {
+		return nil
+	}
+	if l.max {
+		l.delta.MaxPoolGrad(err, l.index, l.pool)
+	} else {
+		l.delta.AvgPoolGrad(err, l.pool)
+	}
+	if l.deriv != nil {
+		l.delta.MulElem(l.delta, l.deriv)
+	}
+	return l.delta
+}
@@ -0,0 +1,301 @@
+package network
+
+import (
+	"fmt"
+	"github.com/jnb666/deepthought/blas"
+	"math"
+)
+
+// layers which limit the size of the weight gradient before the weights are updated
+type clippedLayer interface {
+	clipGradient()
+}
+
+// RecurrentOpts type has the options for a recurrent layer.
+type RecurrentOpts struct {
+	Sequence bool    // output the hidden state at every step: default is the last step only
+	Truncate int     // back propagate through at most this many steps: 0 for no limit
+	Clip     float32 // max L2 norm of the weight gradient: 0 for no clipping
+}
+
+// Recurrent layer with either a simple Elman tanh cell or an LSTM cell. Input for each sample is a
+// sequence of steps*features values. The cell for each step is fed with the concatenation of the input
+// for that step, the hidden state from the previous step and a bias of 1, so a single weight matrix
+// of [gates*hidden, features+hidden+1] holds all the parameters. LSTM gates are in order input, forget,
+// cell, output. Gradients are calculated with back propagation through time.
+type recurrentLayer struct {
+	RecurrentOpts
+	dims     []int
+	lstm     bool
+	steps    int
+	nin      int
+	nout     int
+	gates    int
+	activ    Activation
+	input    blas.Matrix // Z matrix of value at each node [samples, steps*nin]
+	deriv    blas.Matrix // Fp matrix of derivative of activation fn [samples, steps*nin]
+	delta    blas.Matrix // D matrix of errors at each node [samples, steps*nin]
+	weights  blas.Matrix // W weight matrix [gates*nout, nin+nout+1]
+	gradient blas.Matrix // G gradient of weight matrix [gates*nout, nin+nout+1]
+	cellIn   blas.Matrix // input, previous hidden state and bias for each step [samples, steps*(nin+nout+1)]
+	gate     blas.Matrix // gate values after activation for each step [samples, steps*gates*nout]
+	gateDiff blas.Matrix // derivative of gate activations [samples, steps*gates*nout]
+	cell     blas.Matrix // LSTM cell state [samples, steps*nout]
+	cellTanh blas.Matrix // tanh of LSTM cell state [samples, steps*nout]
+	cellDiff blas.Matrix // derivative of tanh of LSTM cell state [samples, steps*nout]
+	hidden   blas.Matrix // hidden state output for each step [samples, steps*nout]
+	last     blas.Matrix // hidden state output for the last step [samples, nout]
+	pre      blas.Matrix // gate inputs before activation [samples, gates*nout]
+	dpre     blas.Matrix // error at gate inputs [samples, gates*nout]
+	dcellIn  blas.Matrix // error at cell inputs [samples, nin+nout+1]
+	dh       blas.Matrix // error at hidden state carried back to previous step [samples, nout]
+	dc       blas.Matrix // error at cell state carried back to previous step [samples, nout]
+	temp     blas.Matrix // [samples, nout]
+	gradTemp blas.Matrix // [gates*nout, nin+nout+1]
+}
+
+// AddRNNLayer method adds a simple recurrent layer with nout hidden units and tanh activation.
+// dims are the input dimensions as [steps, features]. Returns the output dimensions which are
+// [steps, nout] if opts.Sequence is set else [nout].
+// An optional initialiser may be given to override the network default for this layer.
+func (n *Network) AddRNNLayer(dims []int, nout int, opts RecurrentOpts, a Activation, init ...Initialiser) (outDims []int) {
+	return n.addRecurrent(dims, nout, false, opts, a, init)
+}
+
+// AddLSTMLayer method adds a long short-term memory layer with nout hidden units.
+// dims are the input dimensions as [steps, features]. Returns the output dimensions which are
+// [steps, nout] if opts.Sequence is set else [nout].
+// An optional initialiser may be given to override the network default for this layer.
+func (n *Network) AddLSTMLayer(dims []int, nout int, opts RecurrentOpts, a Activation, init ...Initialiser) (outDims []int) {
+	return n.addRecurrent(dims, nout, true, opts, a, init)
+}
+
+func (n *Network) addRecurrent(dims []int, nout int, lstm bool, opts RecurrentOpts, a Activation, init []Initialiser) []int {
+	if len(dims) != 2 || dims[0] < 1 || dims[1] < 1 {
+		panic(fmt.Sprintf("recurrent layer: invalid input dims %v - should be [steps, features]", dims))
+	}
+	if nout < 1 || opts.Truncate < 0 || opts.Clip < 0 {
+		panic(fmt.Sprintf("recurrent layer: invalid options nodes=%d truncate=%d clip=%g", nout, opts.Truncate, opts.Clip))
+	}
+	batch := n.BatchSize
+	steps, nin := dims[0], dims[1]
+	l := &recurrentLayer{
+		RecurrentOpts: opts,
+		dims:          dims,
+		lstm:          lstm,
+		steps:         steps,
+		nin:           nin,
+		nout:          nout,
+		gates:         1,
+		activ:         a,
+	}
+	if lstm {
+		l.gates = 4
+	}
+	ncell, ngate := nin+nout+1, l.gates*nout
+	l.input = blas.New(batch, steps*nin)
+	l.weights = blas.New(ngate, ncell)
+	l.gradient = blas.New(ngate, ncell)
+	l.cellIn = blas.New(batch, steps*ncell)
+	l.gate = blas.New(batch, steps*ngate)
+	l.gateDiff = blas.New(batch, steps*ngate)
+	l.last = blas.New(batch, nout)
+	l.pre = blas.New(batch, ngate)
+	l.dpre = blas.New(batch, ngate)
+	l.dcellIn = blas.New(batch, ncell)
+	l.dh = blas.New(batch, nout)
+	l.temp = blas.New(batch, nout)
+	l.gradTemp = blas.New(ngate, ncell)
+	if lstm {
+		l.cell = blas.New(batch, steps*nout)
+		l.cellTanh = blas.New(batch, steps*nout)
+		l.cellDiff = blas.New(batch, steps*nout)
+		l.hidden = blas.New(batch, steps*nout)
+		l.dc = blas.New(batch, nout)
+	} else {
+		// hidden state is the output of the tanh gate
+		l.hidden = l.gate
+	}
+	if a.Deriv != nil {
+		l.deriv = blas.New(batch, steps*nin)
+	}
+	if n.Layers > 0 {
+		l.delta = blas.New(batch, steps*nin)
+	}
+	n.addInit(init)
+	n.add(l)
+	if opts.Sequence {
+		return []int{steps, nout}
+	}
+	return []int{nout}
+}
+
+func (l *recurrentLayer) Dims() []int {
+	return l.dims
+}
+
+func (l *recurrentLayer) Values() blas.Matrix {
+	return l.input
+}
+
+func (l *recurrentLayer) Release() {
+	for _, m := range []blas.Matrix{l.input, l.weights, l.gradient, l.cellIn, l.gate, l.gateDiff, l.last,
+		l.pre, l.dpre, l.dcellIn, l.dh, l.temp, l.gradTemp, l.deriv, l.delta} {
+		if m != nil {
+			m.Release()
+		}
+	}
+	if l.lstm {
+		for _, m := range []blas.Matrix{l.cell, l.cellTanh, l.cellDiff, l.hidden, l.dc} {
+			m.Release()
+		}
+	}
+}
+
+func (l *recurrentLayer) Weights() blas.Matrix { return l.weights }
+
+func (l *recurrentLayer) Gradient() blas.Matrix { return l.gradient }
+
+func (l *recurrentLayer) Cost(t blas.Matrix) blas.Matrix { panic("no cost for recurrent layer!") }
+
+// view on columns for step t of matrix with width columns per step
+func step(m blas.Matrix, t, width int) blas.Matrix {
+	return m.Col(t*width, (t+1)*width)
+}
+
+// set the number of rows for all of the per sample matrices
+func (l *recurrentLayer) setRows(rows int) {
+	for _, m := range []blas.Matrix{l.cellIn, l.gate, l.gateDiff, l.last, l.dpre, l.dh, l.temp, l.deriv, l.delta} {
+		if m != nil {
+			m.Reshape(rows, m.Cols(), false)
+		}
+	}
+	if l.lstm {
+		for _, m := range []blas.Matrix{l.cell, l.cellTanh, l.cellDiff, l.hidden, l.dc} {
+			m.Reshape(rows, m.Cols(), false)
+		}
+	}
+}
+
+func (l *recurrentLayer) FeedForward(in blas.Matrix) blas.Matrix {
+	l.activ.Func.Apply(in, l.input)
+	if l.activ.Deriv != nil {
+		l.activ.Deriv.Apply(in, l.deriv)
+	}
+	l.setRows(in.Rows())
+	nin, nout, ncell, ngate := l.nin, l.nout, l.nin+l.nout+1, l.gates*l.nout
+	for t := 0; t < l.steps; t++ {
+		// cell input is [x(t), h(t-1), 1]
+		base := t * ncell
+		l.cellIn.Col(base, base+nin).Copy(step(l.input, t, nin), nil)
+		if t == 0 {
+			l.cellIn.Col(base+nin, base+nin+nout).Set(0)
+		} else {
+			l.cellIn.Col(base+nin, base+nin+nout).Copy(step(l.hidden, t-1, nout), nil)
+		}
+		l.cellIn.Col(base+nin+nout, base+ncell).Set(1)
+		l.pre.Mul(step(l.cellIn, t, ncell), l.weights, false, true, false)
+		if !l.lstm {
+			Tanh.Func.Apply(l.pre, step(l.gate, t, nout))
+			Tanh.Deriv.Apply(l.pre, step(l.gateDiff, t, nout))
+			continue
+		}
+		base = t * ngate
+		for g, fn := range []Activation{Sigmoid, Sigmoid, Tanh, Sigmoid} {
+			pre := l.pre.Col(g*nout, (g+1)*nout)
+			fn.Func.Apply(pre, l.gate.Col(base+g*nout, base+(g+1)*nout))
+			fn.Deriv.Apply(pre, l.gateDiff.Col(base+g*nout, base+(g+1)*nout))
+		}
+		i, f, g, o := l.lstmGates(l.gate, t)
+		// c(t) = f*c(t-1) + i*g and h(t) = o*tanh(c(t))
+		c := step(l.cell, t, nout)
+		c.MulElem(i, g)
+		if t > 0 {
+			c.Add(c, l.temp.MulElem(f, step(l.cell, t-1, nout)), 1)
+		}
+		Tanh.Func.Apply(c, step(l.cellTanh, t, nout))
+		Tanh.Deriv.Apply(c, step(l.cellDiff, t, nout))
+		step(l.hidden, t, nout).MulElem(o, step(l.cellTanh, t, nout))
+	}
+	if l.Sequence {
+		return l.hidden
+	}
+	return l.last.Copy(step(l.hidden, l.steps-1, nout), nil)
+}
+
+// views on the input, forget, cell and output gates for step t
+func (l *recurrentLayer) lstmGates(m blas.Matrix, t int) (i, f, g, o blas.Matrix) {
+	base, nout := 4*t*l.nout, l.nout
+	return m.Col(base, base+nout), m.Col(base+nout, base+2*nout), m.Col(base+2*nout, base+3*nout),
+		m.Col(base+3*nout, base+4*nout)
+}
+
+func (l *recurrentLayer) BackProp(err blas.Matrix) blas.Matrix {
+	nin, nout, ncell := l.nin, l.nout, l.nin+l.nout+1
+	l.gradient.Set(0)
+	l.dh.Set(0)
+	if l.lstm {
+		l.dc.Set(0)
+	}
+	for t := l.steps - 1; t >= 0; t-- {
+		// error at h(t) is from the output plus that carried back from the next step
+		if l.Sequence {
+			l.dh.Add(l.dh, step(err, t, nout), 1)
+		} else if t == l.steps-1 {
+			l.dh.Add(l.dh, err, 1)
+		}
+		if l.lstm {
+			l.lstmBackProp(t)
+		} else {
+			l.dpre.MulElem(l.dh, step(l.gateDiff, t, nout))
+		}
+		cellIn := step(l.cellIn, t, ncell)
+		l.gradient.Add(l.gradient, l.gradTemp.Mul(l.dpre, cellIn, true, false, false), 1)
+		l.dcellIn.Mul(l.dpre, l.weights, false, false, false)
+		if l.delta != nil {
+			step(l.delta, t, nin).Copy(l.dcellIn.Col(0, nin), nil)
+		}
+		l.dh.Copy(l.dcellIn.Col(nin, nin+nout), nil)
+		// truncated back propagation stops the error flowing back past every Truncate steps from the end
+		if l.Truncate > 0 && (l.steps-t)%l.Truncate == 0 {
+			l.dh.Set(0)
+			if l.lstm {
+				l.dc.Set(0)
+			}
+		}
+	}
+	if l.delta != nil && l.deriv != nil {
+		l.delta.MulElem(l.delta, l.deriv)
+	}
+	return l.delta
+}
+
+// get error at the gate inputs for step t given the error at the hidden state, updates the cell state error
+func (l *recurrentLayer) lstmBackProp(t int) {
+	nout := l.nout
+	i, f, g, o := l.lstmGates(l.gate, t)
+	di, df, dg, do := l.lstmGates(l.dpre, 0)
+	fi, ff, fg, fo := l.lstmGates(l.gateDiff, t)
+	// dc(t) = dc(t+1)*f(t+1) + dh(t)*o*tanh'(c(t))
+	l.dc.Add(l.dc, l.temp.MulElem(l.dh, o).MulElem(l.temp, step(l.cellDiff, t, nout)), 1)
+	do.MulElem(l.dh, step(l.cellTanh, t, nout)).MulElem(do, fo)
+	di.MulElem(l.dc, g).MulElem(di, fi)
+	dg.MulElem(l.dc, i).MulElem(dg, fg)
+	if t > 0 {
+		df.MulElem(l.dc, step(l.cell, t-1, nout)).MulElem(df, ff)
+	} else {
+		df.Set(0)
+	}
+	l.dc.MulElem(l.dc, f)
+}
+
+// scale the gradient down if the L2 norm is greater than the clip value
+func (l *recurrentLayer) clipGradient() {
+	if l.Clip <= 0 {
+		return
+	}
+	norm := float32(math.Sqrt(float64(l.gradTemp.MulElem(l.gradient, l.gradient).Sum())))
+	if norm > l.Clip {
+		l.gradient.Scale(l.Clip / norm)
+	}
+}
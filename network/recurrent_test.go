@@ -0,0 +1,162 @@
+package network
+
+import (
+	"bytes"
+	"github.com/jnb666/deepthought/blas"
+	"math/rand"
+	"testing"
+)
+
+func TestRecurrentGradient(t *testing.T) {
+	rand.Seed(1)
+	batch, steps, nin := 4, 4, 3
+	for _, lstm := range []bool{false, true} {
+		for _, seq := range []bool{false, true} {
+			t.Log("lstm:", lstm, "sequence:", seq)
+			n := New(batch, nil)
+			n.AddLayer([]int{steps * nin}, steps*nin, Linear)
+			opts := RecurrentOpts{Sequence: seq}
+			var dims []int
+			if lstm {
+				dims = n.AddLSTMLayer([]int{steps, nin}, 5, opts, Sigmoid)
+			} else {
+				dims = n.AddRNNLayer([]int{steps, nin}, 5, opts, Sigmoid)
+			}
+			if seq && (len(dims) != 2 || dims[0] != steps || dims[1] != 5) || !seq && (len(dims) != 1 || dims[0] != 5) {
+				t.Fatal("wrong output dims", dims)
+			}
+			n.AddLayer(dims, 3, Linear)
+			n.AddQuadraticOutput(3, Sigmoid)
+			checkGradient(t, n, randMatrix(batch, steps*nin), randMatrix(batch, 3), 1e-3)
+			n.Release()
+		}
+	}
+}
+
+func TestRecurrentTruncate(t *testing.T) {
+	rand.Seed(1)
+	batch, steps, nin := 4, 5, 2
+	for _, lstm := range []bool{false, true} {
+		n := New(batch, nil)
+		n.AddLayer([]int{steps * nin}, steps*nin, Linear)
+		opts := RecurrentOpts{Truncate: 2, Clip: 0.01}
+		if lstm {
+			n.AddLSTMLayer([]int{steps, nin}, 3, opts, Linear)
+		} else {
+			n.AddRNNLayer([]int{steps, nin}, 3, opts, Linear)
+		}
+		n.AddQuadraticOutput(3, Linear)
+		n.SetRandomWeights()
+		n.FeedForward(randMatrix(batch, steps*nin))
+		delta := n.Nodes[2].BackProp(randMatrix(batch, 3))
+		l := n.Nodes[1].(*recurrentLayer)
+		delta = l.BackProp(delta)
+		// error should only reach the last two steps
+		for i, x := range delta.Data(blas.RowMajor) {
+			col := i % (steps * nin)
+			if zero := col < (steps-2)*nin; zero != (x == 0) {
+				t.Fatalf("lstm=%v: unexpected delta %g at column %d", lstm, x, col)
+			}
+		}
+		l.clipGradient()
+		g := l.gradient.Data(blas.RowMajor)
+		var sum float32
+		for _, x := range g {
+			sum += x * x
+		}
+		t.Logf("lstm=%v: clipped gradient norm squared = %g", lstm, sum)
+		if sum > 1.01e-4 {
+			t.Error("gradient not clipped")
+		}
+		n.Release()
+	}
+}
+
+func TestSaveRecurrentModel(t *testing.T) {
+	rand.Seed(1)
+	batch := 4
+	n := New(batch, MaxCol{})
+	dims := n.AddLSTMLayer([]int{3, 2}, 4, RecurrentOpts{Sequence: true, Truncate: 2}, Linear)
+	dims = n.AddRNNLayer(dims, 3, RecurrentOpts{Clip: 1}, Tanh)
+	n.AddCrossEntropyOutput(3)
+	defer n.Release()
+	n.SetRandomWeights()
+	input := randMatrix(batch, 6)
+	expect := n.FeedForward(input).Data(blas.RowMajor)
+	var buf bytes.Buffer
+	if err := n.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	n2, err := LoadModel(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n2.Release()
+	l1, l2 := n2.Nodes[0].(*recurrentLayer), n2.Nodes[1].(*recurrentLayer)
+	if !l1.lstm || !l1.Sequence || l1.Truncate != 2 || l2.lstm || l2.Clip != 1 {
+		t.Error("recurrent options not restored")
+	}
+	checkEqual(t, n2.FeedForward(input).Data(blas.RowMajor), expect)
+}
+
+// generate sequences of random bits where the class is given by the first bit
+func sequenceData(samples, steps int) *Data {
+	in := make([]float32, samples*steps)
+	out := make([]float32, samples*2)
+	class := make([]float32, samples)
+	for i := 0; i < samples; i++ {
+		for j := 0; j < steps; j++ {
+			in[i*steps+j] = float32(rand.Intn(2))
+		}
+		class[i] = in[i*steps]
+		out[2*i+int(class[i])] = 1
+	}
+	return &Data{
+		Input:      blas.New(samples, steps).Load(blas.RowMajor, in...),
+		Output:     blas.New(samples, 2).Load(blas.RowMajor, out...),
+		Classes:    blas.New(samples, 1).Load(blas.RowMajor, class...),
+		NumSamples: samples,
+	}
+}
+
+func TestRecurrentTrain(t *testing.T) {
+	rand.Seed(1)
+	steps := 6
+	d := &Dataset{
+		OutputToClass: MaxCol{},
+		Train:         sequenceData(200, steps),
+		Test:          sequenceData(100, steps),
+		NumInputs:     steps,
+		NumOutputs:    2,
+		MaxSamples:    200,
+	}
+	defer d.Release()
+	cfg := &Config{MaxEpoch: 100, LearnRate: 0.05, BatchSize: 10, Optimizer: "adam", Sampler: "random"}
+	for _, lstm := range []bool{false, true} {
+		n := New(cfg.BatchSize, d.OutputToClass)
+		opts := RecurrentOpts{Clip: 5}
+		if lstm {
+			n.AddLSTMLayer([]int{steps, 1}, 8, opts, Linear)
+		} else {
+			n.AddRNNLayer([]int{steps, 1}, 8, opts, Linear)
+		}
+		n.AddLayer([]int{8}, 2, Linear)
+		n.AddCrossEntropyOutput(2)
+		n.SetRandomWeights()
+		s := NewStats()
+		s.StartRun()
+		for s.Epoch < cfg.MaxEpoch {
+			n.Train(s, d, cfg)
+			s.Update(n, d)
+			if _, err := s.Test.ClassError.XY(s.Test.ClassError.Len() - 1); err == 0 {
+				break
+			}
+		}
+		_, err := s.Test.ClassError.XY(s.Test.ClassError.Len() - 1)
+		t.Logf("lstm=%v: epoch %d test error %.3f", lstm, s.Epoch, err)
+		if err > 0.02 {
+			t.Error("failed to learn sequence")
+		}
+		n.Release()
+	}
+}
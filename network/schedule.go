@@ -0,0 +1,100 @@
+package network
+
+import (
+	"math"
+)
+
+const plateauThreshold = 1e-4 // minimum relative improvement in cost for plateau schedule
+
+var schedules = map[string]func(cfg *Config, s *Stats, t float64) float64{
+	"constant": constantRate,
+	"step":     stepRate,
+	"exp":      expRate,
+	"cosine":   cosineRate,
+	"plateau":  plateauRate,
+}
+
+var ScheduleNames = []string{"constant", "step", "exp", "cosine", "plateau"}
+
+// LearnRate function returns the learning rate to use given the config and training history.
+// epoch is the current epoch starting from 1 and frac is the fraction of the epoch completed including
+// the current batch, which is only used if cfg.LRPerBatch is set. Default schedule is constant.
+func LearnRate(cfg *Config, s *Stats, epoch int, frac float32) float32 {
+	t := float64(epoch - 1)
+	if cfg.LRPerBatch {
+		t += float64(frac)
+	}
+	typ := cfg.LRSchedule
+	if typ == "" {
+		typ = "constant"
+	}
+	fn, ok := schedules[typ]
+	if !ok {
+		panic("learning rate schedule of type " + typ + " not found")
+	}
+	rate := fn(cfg, s, t)
+	// linear warmup over first cfg.Warmup epochs
+	if cfg.Warmup > 0 && t < float64(cfg.Warmup) {
+		if cfg.LRPerBatch {
+			rate *= t / float64(cfg.Warmup)
+		} else {
+			rate *= (t + 1) / float64(cfg.Warmup)
+		}
+	}
+	return float32(rate)
+}
+
+// fixed learning rate
+func constantRate(cfg *Config, s *Stats, t float64) float64 {
+	return float64(cfg.LearnRate)
+}
+
+// multiply by LRDecay every LRStep epochs
+func stepRate(cfg *Config, s *Stats, t float64) float64 {
+	step := math.Max(float64(cfg.LRStep), 1)
+	return float64(cfg.LearnRate) * math.Pow(float64(cfg.LRDecay), math.Floor(t/step))
+}
+
+// multiply by LRDecay every epoch
+func expRate(cfg *Config, s *Stats, t float64) float64 {
+	return float64(cfg.LearnRate) * math.Pow(float64(cfg.LRDecay), t)
+}
+
+// cosine annealing from LearnRate to LRMin, restarting every LRStep epochs if this is set
+func cosineRate(cfg *Config, s *Stats, t float64) float64 {
+	period := float64(cfg.LRStep)
+	if period <= 0 {
+		period = float64(cfg.MaxEpoch)
+	}
+	t = math.Mod(t, period)
+	max, min := float64(cfg.LearnRate), float64(cfg.LRMin)
+	return min + (max-min)*0.5*(1+math.Cos(math.Pi*t/period))
+}
+
+// multiply by LRDecay if the validation error has not improved for LRStep epochs.
+// Uses the training error if there is no validation set.
+func plateauRate(cfg *Config, s *Stats, t float64) float64 {
+	rate := float64(cfg.LearnRate)
+	if s == nil {
+		return rate
+	}
+	hist := s.Valid.Error
+	if hist.Len() == 0 {
+		hist = s.Train.Error
+	}
+	patience := cfg.LRStep
+	if patience < 1 {
+		patience = 1
+	}
+	best, wait := float32(math.MaxFloat32), 0
+	for i := 0; i < hist.Len(); i++ {
+		_, cost := hist.XY(i)
+		if cost < best-plateauThreshold*best {
+			best, wait = cost, 0
+		} else if wait++; wait >= patience {
+			rate *= float64(cfg.LRDecay)
+			wait = 0
+		}
+	}
+	return rate
+}
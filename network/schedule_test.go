@@ -0,0 +1,54 @@
+package network
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSchedules(t *testing.T) {
+	cfg := &Config{LearnRate: 1, LRDecay: 0.5, LRStep: 2, MaxEpoch: 10, LRMin: 0.1}
+	expect := map[string][]float64{
+		"constant": {1, 1, 1, 1, 1},
+		"step":     {1, 1, 0.5, 0.5, 0.25},
+		"exp":      {1, 0.5, 0.25, 0.125, 0.0625},
+		"cosine":   {1, 0.55, 1, 0.55, 1},
+	}
+	for name, rates := range expect {
+		cfg.LRSchedule = name
+		for i, rate := range rates {
+			if r := LearnRate(cfg, nil, i+1, 0); math.Abs(float64(r)-rate) > 1e-6 {
+				t.Errorf("%s epoch %d: expected %g got %g", name, i+1, rate, r)
+			}
+		}
+	}
+	// cosine without restarts anneals over MaxEpoch
+	cfg.LRSchedule, cfg.LRStep = "cosine", 0
+	if r := LearnRate(cfg, nil, 6, 0); math.Abs(float64(r)-0.55) > 1e-6 {
+		t.Errorf("cosine: expected 0.55 got %g", r)
+	}
+	// linear warmup
+	cfg.LRSchedule, cfg.Warmup = "constant", 4
+	if r := LearnRate(cfg, nil, 2, 0); r != 0.5 {
+		t.Errorf("warmup: expected 0.5 got %g", r)
+	}
+	cfg.LRPerBatch = true
+	if r := LearnRate(cfg, nil, 2, 0.5); r != 0.375 {
+		t.Errorf("warmup per batch: expected 0.375 got %g", r)
+	}
+	if r := LearnRate(cfg, nil, 1, 0.1); math.Abs(float64(r)-0.025) > 1e-6 {
+		t.Errorf("warmup first batch: expected 0.025 got %g", r)
+	}
+	// reduce on plateau driven by validation error
+	cfg = &Config{LearnRate: 1, LRDecay: 0.1, LRStep: 2, LRSchedule: "plateau"}
+	s := NewStats()
+	for _, cost := range []float32{1, 0.5, 0.6, 0.55, 0.4, 0.45} {
+		s.Valid.Error.Push(cost, 0)
+	}
+	if r := LearnRate(cfg, s, 7, 0); math.Abs(float64(r)-0.1) > 1e-6 {
+		t.Errorf("plateau: expected 0.1 got %g", r)
+	}
+	s.Valid.Error.Push(0.41, 0)
+	if r := LearnRate(cfg, s, 8, 0); math.Abs(float64(r)-0.01) > 1e-6 {
+		t.Errorf("plateau: expected 0.01 got %g", r)
+	}
+}
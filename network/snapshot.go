@@ -0,0 +1,92 @@
+package network
+
+import (
+	"fmt"
+	"github.com/jnb666/deepthought/blas"
+	"io"
+)
+
+const (
+	snapshotHeader  = "deepthought snapshot"
+	snapshotVersion = 1
+)
+
+// Snapshot type has the training state at the end of an epoch so that a run can be resumed.
+// The random number generators are reseeded at the start of each epoch using SeedEpoch so their
+// state is given by the seed, run and epoch.
+type Snapshot struct {
+	Seed  int64  // random seed set at the start of training
+	Run   int    // index of the current run from zero
+	Stats *Stats // stats including the current epoch and stop criteria history
+}
+
+type snapshot struct {
+	Snapshot
+	Layers    []layerSpec
+	Optimizer string
+	Momentum  float32
+	OptState  *optSnapshot
+}
+
+// SeedEpoch function reseeds the random number generators at the start of each epoch.
+// Epoch zero is used for initialising the weights at the start of the run.
+func SeedEpoch(seed int64, run, epoch int) {
+	// mix the bits so that nearby seeds give unrelated sequences
+	x := uint64(seed) + uint64(run)<<32 + uint64(epoch)
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	x ^= x >> 31
+	if x == 0 {
+		x = 1
+	}
+	blas.SeedRandom(int64(x >> 1))
+}
+
+// SaveSnapshot function writes the current state of the network, optimizer and stats to w.
+func SaveSnapshot(w io.Writer, n *Network, s *Stats, seed int64, run int) error {
+	layers, err := n.specs()
+	if err != nil {
+		return fmt.Errorf("SaveSnapshot: %s", err)
+	}
+	snap := snapshot{Snapshot: Snapshot{Seed: seed, Run: run, Stats: s}, Layers: layers}
+	if n.optimizer != nil {
+		opt, ok := n.optimizer.(savedOptimizer)
+		if !ok {
+			return fmt.Errorf("SaveSnapshot: optimizer of type %T cannot be saved", n.optimizer)
+		}
+		state := opt.save()
+		snap.Optimizer, snap.Momentum, snap.OptState = n.optType, n.optMomentum, &state
+	}
+	return writeFile(w, snapshotHeader, snapshotVersion, snap)
+}
+
+// LoadSnapshot function restores the weights and optimizer state from a snapshot into a network
+// with the same layers as the one it was saved from. Returns the snapshot with the saved stats.
+func LoadSnapshot(r io.Reader, n *Network) (*Snapshot, error) {
+	// fields which are not in the file are left with their default values
+	snap := &snapshot{Snapshot: Snapshot{Stats: NewStats()}}
+	if err := readFile(r, snapshotHeader, snapshotVersion, snap); err != nil {
+		return nil, fmt.Errorf("LoadSnapshot: %s", err)
+	}
+	if len(snap.Layers) != n.Layers {
+		return nil, fmt.Errorf("LoadSnapshot: expected %d layers, got %d", n.Layers, len(snap.Layers))
+	}
+	for i, layer := range n.Nodes {
+		if l, ok := layer.(savedLayer); !ok || l.spec().Type != snap.Layers[i].Type {
+			return nil, fmt.Errorf("LoadSnapshot: layer %d is not of type %s", i, snap.Layers[i].Type)
+		}
+		if err := loadSpec(layer, snap.Layers[i]); err != nil {
+			return nil, fmt.Errorf("LoadSnapshot: layer %d: %s", i, err)
+		}
+	}
+	if n.optimizer != nil {
+		n.optimizer.Release()
+		n.optimizer = nil
+	}
+	if snap.OptState != nil {
+		n.optimizer = NewOptimizer(snap.Optimizer, snap.Momentum)
+		n.optType, n.optMomentum = snap.Optimizer, snap.Momentum
+		n.optimizer.(savedOptimizer).load(*snap.OptState, func(id int) blas.Matrix { return n.Nodes[id].Weights() })
+	}
+	return &snap.Snapshot, nil
+}
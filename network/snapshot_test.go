@@ -0,0 +1,72 @@
+package network_test
+
+import (
+	"bytes"
+	"github.com/jnb666/deepthought/network"
+	"testing"
+)
+
+// train for given number of epochs starting from epoch in stats, snapshot is saved after the epoch given by save
+func trainEpochs(t *testing.T, net *network.Network, s *network.Stats, d *network.Dataset, cfg *network.Config,
+	epochs, save int, snap *bytes.Buffer) {
+	stop := network.StopCriteria(cfg)
+	for s.Epoch < epochs {
+		network.SeedEpoch(1, 0, s.Epoch+1)
+		net.Train(s, d, cfg)
+		s.Update(net, d)
+		if done, _ := stop(s); done {
+			t.Fatal("stopped early at epoch", s.Epoch)
+		}
+		if s.Epoch == save {
+			if err := network.SaveSnapshot(snap, net, s, 1, 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	cfg, net, d, err := network.Load("iris", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Release()
+	cfg.Sampler, cfg.Optimizer, cfg.LearnRate = "random", "adam", 0.02
+	cfg.StopAfter, cfg.Threshold = 3, 0
+	network.SeedEpoch(1, 0, 0)
+	net.SetRandomWeights()
+	s := network.NewStats()
+	s.StartRun()
+	var snap bytes.Buffer
+	trainEpochs(t, net, s, d, cfg, 10, 5, &snap)
+	expect := s.Valid.Error
+	net.Release()
+
+	// resume from snapshot in a new network
+	_, net, d2, err := network.Load("iris", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d2.Release()
+	defer net.Release()
+	res, err := network.LoadSnapshot(&snap, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Seed != 1 || res.Run != 0 || res.Stats.Epoch != 5 {
+		t.Fatalf("wrong snapshot state: seed=%d run=%d epoch=%d", res.Seed, res.Run, res.Stats.Epoch)
+	}
+	trainEpochs(t, net, res.Stats, d2, cfg, 10, -1, nil)
+	got := res.Stats.Valid.Error
+	if got.Len() != expect.Len() {
+		t.Fatalf("expected %d epochs got %d", expect.Len(), got.Len())
+	}
+	for i := 0; i < got.Len(); i++ {
+		_, y1 := expect.XY(i)
+		_, y2 := got.XY(i)
+		t.Logf("epoch %2d: %.6f %.6f", i+1, y1, y2)
+		if y1 != y2 {
+			t.Errorf("epoch %d: expected error %g got %g", i+1, y1, y2)
+		}
+	}
+}
@@ -24,37 +24,78 @@ type Stats struct {
 	Test       *StatsData
 	Train      *StatsData
 	Valid      *StatsData
+	LearnRate  *vec.Vector
 	RunTime    *vec.RunningStat
 	RegError   *vec.RunningStat
 	ClsError   *vec.RunningStat
+	MAError    *vec.RunningStat // regression only
+	RMSError   *vec.RunningStat // regression only
+	R2Score    *vec.RunningStat // regression only
+	PrevCost   *vec.Buffer      // recent costs used by the stop criteria
+	ClassStats bool             // print per class precision, recall and F1 in String and EndRun
 }
 
-// StatsData stores vectors with the errors and classification errors
+// StatsData stores vectors with the errors and classification errors.
+// For a regression network the mean absolute error, root mean squared error and R squared are stored
+// instead of the classification error. For a multi-label network the Hamming loss and subset accuracy
+// are also stored along with the F1 score for each label from the latest epoch. For a single label
+// classifier the confusion matrix from the latest epoch is stored along with the top-k error and mean
+// reciprocal rank if k is set on the network. For a binary classifier with ROC enabled the area under the
+// ROC and precision-recall curves are stored along with the curves from the latest epoch.
 type StatsData struct {
-	Error      *vec.Vector
-	ClassError *vec.Vector
-	ErrorHist  *vec.Vector
-	HistMax    float32
+	Error       *vec.Vector
+	ClassError  *vec.Vector
+	MAE         *vec.Vector
+	RMSE        *vec.Vector
+	R2          *vec.Vector
+	HammingLoss *vec.Vector
+	SubsetAcc   *vec.Vector
+	LabelF1     *vec.Vector
+	TopKError   *vec.Vector
+	MRR         *vec.Vector
+	ROCAUC      *vec.Vector
+	PRAUC       *vec.Vector
+	ROC         *vec.Vector
+	PR          *vec.Vector
+	Confusion   ConfusionMatrix
+	ErrorHist   *vec.Vector
+	HistMax     float32
 }
 
 // NewStats function returns a new stats struct.
 func NewStats() *Stats {
 	return &Stats{
-		Test:     newStatsData(),
-		Train:    newStatsData(),
-		Valid:    newStatsData(),
-		RunTime:  &vec.RunningStat{},
-		RegError: &vec.RunningStat{},
-		ClsError: &vec.RunningStat{},
+		Test:      newStatsData(),
+		Train:     newStatsData(),
+		Valid:     newStatsData(),
+		LearnRate: vec.New(0),
+		RunTime:   &vec.RunningStat{},
+		RegError:  &vec.RunningStat{},
+		ClsError:  &vec.RunningStat{},
+		MAError:   &vec.RunningStat{},
+		RMSError:  &vec.RunningStat{},
+		R2Score:   &vec.RunningStat{},
 	}
 }
 
 func newStatsData() *StatsData {
 	return &StatsData{
-		Error:      vec.New(0),
-		ClassError: vec.New(0),
-		ErrorHist:  vec.New(histBins),
-		HistMax:    histMax,
+		Error:       vec.New(0),
+		ClassError:  vec.New(0),
+		MAE:         vec.New(0),
+		RMSE:        vec.New(0),
+		R2:          vec.New(0),
+		HammingLoss: vec.New(0),
+		SubsetAcc:   vec.New(0),
+		LabelF1:     vec.New(0),
+		TopKError:   vec.New(0),
+		MRR:         vec.New(0),
+		ROCAUC:      vec.New(0),
+		PRAUC:       vec.New(0),
+		ROC:         vec.New(0),
+		PR:          vec.New(0),
+		ErrorHist:   vec.New(histBins),
+		HistMax:     histMax,
 	}
 }
 
@@ -67,9 +108,14 @@ func (s *Stats) Reset() {
 	s.Test.clear(true)
 	s.Train.clear(true)
 	s.Valid.clear(true)
+	s.LearnRate.Clear(true)
 	s.RunTime.Clear()
 	s.RegError.Clear()
 	s.ClsError.Clear()
+	s.MAError.Clear()
+	s.RMSError.Clear()
+	s.R2Score.Clear()
+	s.PrevCost = nil
 }
 
 // StartRun method resets the stats vectors for this run and starts the timer.
@@ -79,11 +125,26 @@ func (s *Stats) StartRun() {
 	s.Test.clear(false)
 	s.Train.clear(false)
 	s.Valid.clear(false)
+	s.LearnRate.Clear(false)
+	s.PrevCost = nil
 }
 
 func (d *StatsData) clear(reset bool) {
 	d.Error.Clear(reset)
 	d.ClassError.Clear(reset)
+	d.MAE.Clear(reset)
+	d.RMSE.Clear(reset)
+	d.R2.Clear(reset)
+	d.HammingLoss.Clear(reset)
+	d.SubsetAcc.Clear(reset)
+	d.LabelF1.Clear(reset)
+	d.TopKError.Clear(reset)
+	d.MRR.Clear(reset)
+	d.ROCAUC.Clear(reset)
+	d.PRAUC.Clear(reset)
+	d.ROC.Clear(reset)
+	d.PR.Clear(reset)
+	d.Confusion = nil
 	d.ErrorHist.Clear(reset)
 	d.HistMax = histMax
 }
@@ -96,7 +157,13 @@ func (s *Stats) EndRun(failed bool) string {
 		test = s.Train
 	}
 	s.RegError.Push(test.Error.Last())
-	s.ClsError.Push(test.ClassError.Last())
+	if test.regression() {
+		s.MAError.Push(test.MAE.Last())
+		s.RMSError.Push(test.RMSE.Last())
+		s.R2Score.Push(test.R2.Last())
+	} else {
+		s.ClsError.Push(test.ClassError.Last())
+	}
 	var status string
 	if failed {
 		status = "**FAILED **"
@@ -105,8 +172,26 @@ func (s *Stats) EndRun(failed bool) string {
 		s.RunSuccess++
 	}
 	s.Runs++
-	status += fmt.Sprintf("  epochs=%d  run time=%.2fs  reg error=%.4f  class error=%.2f%%",
-		s.Epoch, s.TotalTime.Seconds(), test.Error.Last(), 100*test.ClassError.Last())
+	status += fmt.Sprintf("  epochs=%d  run time=%.2fs  reg error=%.4f", s.Epoch, s.TotalTime.Seconds(), test.Error.Last())
+	if test.regression() {
+		status += fmt.Sprintf("  MAE=%.4f  RMSE=%.4f  R2=%.4f", test.MAE.Last(), test.RMSE.Last(), test.R2.Last())
+	} else {
+		status += fmt.Sprintf("  class error=%.2f%%", 100*test.ClassError.Last())
+	}
+	if test.multiLabel() {
+		status += fmt.Sprintf("  hamming loss=%.4f  mean F1=%.4f", test.HammingLoss.Last(), test.meanF1())
+	}
+	if test.TopKError.Len() > 0 {
+		status += fmt.Sprintf("  top-k error=%.2f%%  MRR=%.4f", 100*test.TopKError.Last(), test.MRR.Last())
+	}
+	if test.ROCAUC.Len() > 0 {
+		status += fmt.Sprintf("  AUC=%.4f  PR AUC=%.4f", test.ROCAUC.Last(), test.PRAUC.Last())
+	}
+	if s.ClassStats && test.Confusion != nil {
+		_, _, macro := test.Confusion.Macro()
+		_, _, micro := test.Confusion.Micro()
+		status += fmt.Sprintf("  macro F1=%.4f  micro F1=%.4f\n%s", macro, micro, test.Confusion.Report())
+	}
 	return status
 }
 
@@ -123,18 +208,64 @@ func (s *Stats) String() string {
 		str += fmt.Sprint("   test ", s.Test)
 	}
 	str += fmt.Sprintf("   time %dms", s.EpochTime.Nanoseconds()/1e6)
+	if s.ClassStats {
+		names := []string{"train", "valid", "test"}
+		for i, set := range []*StatsData{s.Train, s.Valid, s.Test} {
+			if set.Confusion != nil {
+				str += fmt.Sprintf("\n== %s ==\n%s", names[i], set.Confusion.Report())
+			}
+		}
+	}
 	return str
 }
 
 func (d *StatsData) String() string {
+	if d.regression() {
+		return fmt.Sprintf("%.5f mae=%.4f rmse=%.4f r2=%.4f", d.Error.Last(), d.MAE.Last(), d.RMSE.Last(), d.R2.Last())
+	}
+	if d.multiLabel() {
+		return fmt.Sprintf("%.5f %5.2f%% hamming=%.4f f1=%.4f", d.Error.Last(), 100*d.ClassError.Last(),
+			d.HammingLoss.Last(), d.meanF1())
+	}
+	if d.TopKError.Len() > 0 {
+		return fmt.Sprintf("%.5f %5.2f%% top-k %5.2f%% mrr=%.4f", d.Error.Last(), 100*d.ClassError.Last(),
+			100*d.TopKError.Last(), d.MRR.Last())
+	}
+	if d.ROCAUC.Len() > 0 {
+		return fmt.Sprintf("%.5f %5.2f%% auc=%.4f", d.Error.Last(), 100*d.ClassError.Last(), d.ROCAUC.Last())
+	}
 	return fmt.Sprintf("%.5f %5.2f%%", d.Error.Last(), 100*d.ClassError.Last())
 }
 
+// multi-label stats are only set if the network has a binary cross entropy output
+func (d *StatsData) multiLabel() bool {
+	return d.HammingLoss.Len() > 0
+}
+
+// average F1 score over the labels
+func (d *StatsData) meanF1() float32 {
+	var sum float32
+	for i := 0; i < d.LabelF1.Len(); i++ {
+		_, f1 := d.LabelF1.XY(i)
+		sum += f1
+	}
+	return sum / float32(d.LabelF1.Len())
+}
+
+// regression stats are only set if the network has no classifier
+func (d *StatsData) regression() bool {
+	return d.RMSE.Len() > 0
+}
+
 // History method returns historical statistics
 func (s *Stats) History() string {
 	rate := 100 * float64(s.RunSuccess) / float64(s.Runs)
-	return fmt.Sprintf("== success rate: %d / %d %.0f%% ==\nrun time:    %s\nreg error:   %s\nclass error: %s",
-		s.RunSuccess, s.Runs, rate, s.RunTime, s.RegError, s.ClsError)
+	str := fmt.Sprintf("== success rate: %d / %d %.0f%% ==\nrun time:    %s\nreg error:   %s",
+		s.RunSuccess, s.Runs, rate, s.RunTime, s.RegError)
+	if s.RMSError.Count > 0 {
+		return str + fmt.Sprintf("\nMAE:         %s\nRMSE:        %s\nR2:          %s", s.MAError, s.RMSError, s.R2Score)
+	}
+	return str + fmt.Sprintf("\nclass error: %s", s.ClsError)
 }
 
 // Update method calculates the error and updates the stats.
@@ -205,9 +336,38 @@ func (s *StatsData) update(n *Network, d *Data, samples int) int {
 		samples = d.NumSamples
 	}
 	s.ErrorHist.Lock()
-	totalError, classError := n.GetError(samples, d, s.ErrorHist, s.HistMax)
+	m := n.GetError(samples, d, s.ErrorHist, s.HistMax)
 	s.ErrorHist.Unlock()
-	s.Error.Push(totalError, 0)
-	s.ClassError.Push(classError, 0)
+	s.Error.Push(m.Cost, 0)
+	if n.Regression() {
+		s.MAE.Push(m.MAE, 0)
+		s.RMSE.Push(m.RMSE, 0)
+		s.R2.Push(m.R2, 0)
+	} else {
+		s.ClassError.Push(m.ClassError, 0)
+		s.Confusion = m.Confusion
+	}
+	if n.TopK() > 0 && m.Confusion != nil {
+		s.TopKError.Push(1-m.TopKAccuracy, 0)
+		s.MRR.Push(m.MRR, 0)
+	}
+	if m.ROC != nil {
+		s.ROCAUC.Push(m.ROCAUC, 0)
+		s.PRAUC.Push(m.PRAUC, 0)
+		step := 1 / float32(curvePoints-1)
+		s.ROC.Lock()
+		s.ROC.Set(0, step, m.ROC)
+		s.ROC.Unlock()
+		s.PR.Lock()
+		s.PR.Set(0, step, m.PR)
+		s.PR.Unlock()
+	}
+	if n.MultiLabel() && m.LabelF1 != nil {
+		s.HammingLoss.Push(m.HammingLoss, 0)
+		s.SubsetAcc.Push(m.SubsetAccuracy, 0)
+		s.LabelF1.Lock()
+		s.LabelF1.Set(0, 1, m.LabelF1)
+		s.LabelF1.Unlock()
+	}
 	return samples
 }
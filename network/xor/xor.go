@@ -51,17 +51,7 @@ func (Loader) Load(samples int) (s *network.Dataset, err error) {
 	s.Train.Input = blas.New(4, 2).Load(blas.RowMajor, c0, c0, c0, c1, c1, c0, c1, c1)
 	s.Train.Output = blas.New(4, 1).Load(blas.RowMajor, c0, c1, c1, c0)
 	s.Train.Classes = blas.New(4, 1).Load(blas.RowMajor, 0, 1, 1, 0)
-	if blas.Implementation() == blas.OpenCL32 {
-		s.OutputToClass = blas.NewUnaryCL("float y = x > 0.f;")
-	} else {
-		s.OutputToClass = blas.Unary32(func(x float32) float32 {
-			if x > 0 {
-				return 1
-			} else {
-				return 0
-			}
-		})
-	}
+	s.OutputToClass = network.NewThreshold(0)
 	return
 }
 
@@ -0,0 +1,260 @@
+package onnx
+
+import (
+	"fmt"
+	"math"
+)
+
+// operator function takes the node and input tensors and returns the output tensors
+type operator func(n *Node, in []*Tensor) ([]*Tensor, error)
+
+var operators = map[string]operator{
+	"Identity":           identityOp,
+	"Dropout":            identityOp,
+	"Relu":               unaryOp(func(x float64) float64 { return math.Max(x, 0) }),
+	"Sigmoid":            unaryOp(func(x float64) float64 { return 1 / (1 + math.Exp(-x)) }),
+	"Tanh":               unaryOp(math.Tanh),
+	"Exp":                unaryOp(math.Exp),
+	"Softmax":            softmaxOp,
+	"Gemm":               gemmOp,
+	"MatMul":             matMulOp,
+	"Add":                addOp,
+	"BatchNormalization": batchNormOp,
+}
+
+// Run method evaluates the graph with the given named inputs and returns the graph outputs.
+// This is a simple reference implementation which supports the operators used for dense networks.
+func (g *Graph) Run(inputs map[string]*Tensor) (map[string]*Tensor, error) {
+	values := map[string]*Tensor{}
+	for _, t := range g.Initializers {
+		values[t.Name] = t
+	}
+	for name, t := range inputs {
+		values[name] = t
+	}
+	for _, n := range g.Nodes {
+		op, ok := operators[n.OpType]
+		if !ok {
+			return nil, fmt.Errorf("onnx: operator %s is not supported", n.OpType)
+		}
+		in := make([]*Tensor, len(n.Inputs))
+		for i, name := range n.Inputs {
+			if name == "" {
+				continue
+			}
+			if in[i], ok = values[name]; !ok {
+				return nil, fmt.Errorf("onnx: %s node %s: input %s not found", n.OpType, n.Name, name)
+			}
+		}
+		out, err := op(n, in)
+		if err != nil {
+			return nil, fmt.Errorf("onnx: %s node %s: %s", n.OpType, n.Name, err)
+		}
+		for i, name := range n.Outputs {
+			if i < len(out) {
+				values[name] = out[i]
+			}
+		}
+	}
+	res := map[string]*Tensor{}
+	for _, v := range g.Outputs {
+		t, ok := values[v.Name]
+		if !ok {
+			return nil, fmt.Errorf("onnx: output %s not found", v.Name)
+		}
+		res[v.Name] = t
+	}
+	return res, nil
+}
+
+func identityOp(n *Node, in []*Tensor) ([]*Tensor, error) {
+	return []*Tensor{in[0]}, nil
+}
+
+func unaryOp(fn func(float64) float64) operator {
+	return func(n *Node, in []*Tensor) ([]*Tensor, error) {
+		out := NewTensor("", make([]float32, len(in[0].Data)), in[0].Dims...)
+		for i, x := range in[0].Data {
+			out.Data[i] = float32(fn(float64(x)))
+		}
+		return []*Tensor{out}, nil
+	}
+}
+
+func softmaxOp(n *Node, in []*Tensor) ([]*Tensor, error) {
+	x := in[0]
+	axis := int(n.AttrInt("axis", -1))
+	if axis < 0 {
+		axis += len(x.Dims)
+	}
+	if axis < 0 || axis >= len(x.Dims) {
+		return nil, fmt.Errorf("invalid axis %d", axis)
+	}
+	outer, size, inner := 1, int(x.Dims[axis]), 1
+	for i, d := range x.Dims {
+		if i < axis {
+			outer *= int(d)
+		} else if i > axis {
+			inner *= int(d)
+		}
+	}
+	out := NewTensor("", make([]float32, len(x.Data)), x.Dims...)
+	for i := 0; i < outer; i++ {
+		for k := 0; k < inner; k++ {
+			base := i*size*inner + k
+			max := math.Inf(-1)
+			for j := 0; j < size; j++ {
+				max = math.Max(max, float64(x.Data[base+j*inner]))
+			}
+			sum := 0.0
+			for j := 0; j < size; j++ {
+				sum += math.Exp(float64(x.Data[base+j*inner]) - max)
+			}
+			for j := 0; j < size; j++ {
+				out.Data[base+j*inner] = float32(math.Exp(float64(x.Data[base+j*inner])-max) / sum)
+			}
+		}
+	}
+	return []*Tensor{out}, nil
+}
+
+// element i,j of 2d tensor with optional transpose
+func at(t *Tensor, i, j int, trans bool) float64 {
+	if trans {
+		return float64(t.Data[j*int(t.Dims[1])+i])
+	}
+	return float64(t.Data[i*int(t.Dims[1])+j])
+}
+
+// matrix multiply 2d tensors
+func matMul(a, b *Tensor, transA, transB bool) (*Tensor, error) {
+	if len(a.Dims) != 2 || len(b.Dims) != 2 {
+		return nil, fmt.Errorf("only 2d inputs are supported")
+	}
+	m, k := int(a.Dims[0]), int(a.Dims[1])
+	if transA {
+		m, k = k, m
+	}
+	k2, n := int(b.Dims[0]), int(b.Dims[1])
+	if transB {
+		k2, n = n, k2
+	}
+	if k != k2 {
+		return nil, fmt.Errorf("inner dimensions %d and %d do not match", k, k2)
+	}
+	out := NewTensor("", make([]float32, m*n), int64(m), int64(n))
+	for i := 0; i < m; i++ {
+		for j := 0; j < n; j++ {
+			sum := 0.0
+			for l := 0; l < k; l++ {
+				sum += at(a, i, l, transA) * at(b, l, j, transB)
+			}
+			out.Data[i*n+j] = float32(sum)
+		}
+	}
+	return out, nil
+}
+
+func matMulOp(n *Node, in []*Tensor) ([]*Tensor, error) {
+	out, err := matMul(in[0], in[1], false, false)
+	return []*Tensor{out}, err
+}
+
+func gemmOp(n *Node, in []*Tensor) ([]*Tensor, error) {
+	out, err := matMul(in[0], in[1], n.AttrInt("transA", 0) != 0, n.AttrInt("transB", 0) != 0)
+	if err != nil {
+		return nil, err
+	}
+	alpha, beta := n.AttrFloat("alpha", 1), n.AttrFloat("beta", 1)
+	for i := range out.Data {
+		out.Data[i] *= alpha
+	}
+	if len(in) > 2 && in[2] != nil {
+		c, err := broadcast(in[2], out.Dims)
+		if err != nil {
+			return nil, err
+		}
+		for i := range out.Data {
+			out.Data[i] += beta * c[i]
+		}
+	}
+	return []*Tensor{out}, nil
+}
+
+func addOp(n *Node, in []*Tensor) ([]*Tensor, error) {
+	a, b := in[0], in[1]
+	if len(b.Dims) > len(a.Dims) || b.Size() > a.Size() {
+		a, b = b, a
+	}
+	bdata, err := broadcast(b, a.Dims)
+	if err != nil {
+		return nil, err
+	}
+	out := NewTensor("", make([]float32, len(a.Data)), a.Dims...)
+	for i, x := range a.Data {
+		out.Data[i] = x + bdata[i]
+	}
+	return []*Tensor{out}, nil
+}
+
+// unidirectional broadcast of t to the given shape using numpy rules
+func broadcast(t *Tensor, dims []int64) ([]float32, error) {
+	if len(t.Dims) > len(dims) {
+		return nil, fmt.Errorf("cannot broadcast %v to %v", t.Dims, dims)
+	}
+	// pad the shape with leading ones
+	shape := make([]int64, len(dims))
+	for i := range shape {
+		shape[i] = 1
+	}
+	copy(shape[len(dims)-len(t.Dims):], t.Dims)
+	for i, d := range shape {
+		if d != 1 && d != dims[i] {
+			return nil, fmt.Errorf("cannot broadcast %v to %v", t.Dims, dims)
+		}
+	}
+	size := 1
+	for _, d := range dims {
+		size *= int(d)
+	}
+	out := make([]float32, size)
+	index := make([]int64, len(dims))
+	for i := range out {
+		// get the offset into t for this output index
+		offset, stride := 0, 1
+		for j := len(dims) - 1; j >= 0; j-- {
+			if shape[j] != 1 {
+				offset += int(index[j]) * stride
+			}
+			stride *= int(shape[j])
+		}
+		out[i] = t.Data[offset]
+		for j := len(dims) - 1; j >= 0; j-- {
+			if index[j]++; index[j] < dims[j] {
+				break
+			}
+			index[j] = 0
+		}
+	}
+	return out, nil
+}
+
+func batchNormOp(n *Node, in []*Tensor) ([]*Tensor, error) {
+	x := in[0]
+	if len(x.Dims) < 2 {
+		return nil, fmt.Errorf("input must have at least 2 dimensions")
+	}
+	scale, bias, mean, variance := in[1], in[2], in[3], in[4]
+	eps := float64(n.AttrFloat("epsilon", 1e-5))
+	channels, inner := int(x.Dims[1]), 1
+	for _, d := range x.Dims[2:] {
+		inner *= int(d)
+	}
+	out := NewTensor("", make([]float32, len(x.Data)), x.Dims...)
+	for i, val := range x.Data {
+		c := (i / inner) % channels
+		xhat := (float64(val) - float64(mean.Data[c])) / math.Sqrt(float64(variance.Data[c])+eps)
+		out.Data[i] = float32(xhat*float64(scale.Data[c]) + float64(bias.Data[c]))
+	}
+	return []*Tensor{out}, nil
+}
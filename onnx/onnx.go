@@ -0,0 +1,536 @@
+// Package onnx reads and writes the subset of the ONNX model format used to exchange trained networks.
+// It has its own protobuf encoder and decoder so has no external dependencies.
+package onnx
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	IRVersion = 7  // ONNX file format version
+	Opset     = 13 // default operator set version
+)
+
+// Tensor data types
+const (
+	Float = 1
+	Int64 = 7
+)
+
+// Attribute types
+const (
+	AttrFloat  = 1
+	AttrInt    = 2
+	AttrString = 3
+	AttrTensor = 4
+	AttrFloats = 6
+	AttrInts   = 7
+)
+
+// Model type is the top level ONNX ModelProto.
+type Model struct {
+	IRVersion       int64
+	Opset           int64
+	ProducerName    string
+	ProducerVersion string
+	Graph           *Graph
+}
+
+// Graph type has the list of nodes in topological order with constant weights stored as initializers.
+type Graph struct {
+	Name         string
+	Nodes        []*Node
+	Initializers []*Tensor
+	Inputs       []*ValueInfo
+	Outputs      []*ValueInfo
+}
+
+// Node type is a single operator in the graph.
+type Node struct {
+	Name    string
+	OpType  string
+	Inputs  []string
+	Outputs []string
+	Attrs   []*Attribute
+}
+
+// Attribute type is a named operator parameter.
+type Attribute struct {
+	Name   string
+	Type   int
+	F      float32
+	I      int64
+	S      string
+	T      *Tensor
+	Floats []float32
+	Ints   []int64
+}
+
+// Tensor type is a multidimensional array of values in row major order.
+// Integer values are stored in Ints, all other types are converted to float32.
+type Tensor struct {
+	Name     string
+	DataType int
+	Dims     []int64
+	Data     []float32
+	Ints     []int64
+}
+
+// ValueInfo type describes a graph input or output. Dimensions with a value of -1 are variable
+// and have the corresponding name from Params.
+type ValueInfo struct {
+	Name     string
+	ElemType int
+	Dims     []int64
+	Params   []string
+}
+
+// NewModel function creates a new model with the current IR and opset versions.
+func NewModel(g *Graph) *Model {
+	return &Model{IRVersion: IRVersion, Opset: Opset, ProducerName: "deepthought", Graph: g}
+}
+
+// NewNode function creates a new node with the given attributes.
+func NewNode(opType, name string, inputs, outputs []string, attrs ...*Attribute) *Node {
+	return &Node{Name: name, OpType: opType, Inputs: inputs, Outputs: outputs, Attrs: attrs}
+}
+
+// IntAttr function returns a new integer attribute.
+func IntAttr(name string, i int64) *Attribute {
+	return &Attribute{Name: name, Type: AttrInt, I: i}
+}
+
+// FloatAttr function returns a new float attribute.
+func FloatAttr(name string, f float32) *Attribute {
+	return &Attribute{Name: name, Type: AttrFloat, F: f}
+}
+
+// IntsAttr function returns a new integer list attribute.
+func IntsAttr(name string, ints ...int64) *Attribute {
+	return &Attribute{Name: name, Type: AttrInts, Ints: ints}
+}
+
+// NewTensor function creates a new float tensor with the given dimensions.
+func NewTensor(name string, data []float32, dims ...int64) *Tensor {
+	return &Tensor{Name: name, DataType: Float, Dims: dims, Data: data}
+}
+
+// Size method returns the number of elements in the tensor.
+func (t *Tensor) Size() int {
+	n := 1
+	for _, d := range t.Dims {
+		n *= int(d)
+	}
+	return n
+}
+
+// Attr method returns the named attribute or nil if it is not set.
+func (n *Node) Attr(name string) *Attribute {
+	for _, a := range n.Attrs {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// AttrInt method returns the value of an integer attribute or def if it is not set.
+func (n *Node) AttrInt(name string, def int64) int64 {
+	if a := n.Attr(name); a != nil {
+		return a.I
+	}
+	return def
+}
+
+// AttrFloat method returns the value of a float attribute or def if it is not set.
+func (n *Node) AttrFloat(name string, def float32) float32 {
+	if a := n.Attr(name); a != nil {
+		return a.F
+	}
+	return def
+}
+
+// Initializer method returns the named initializer or nil if not found.
+func (g *Graph) Initializer(name string) *Tensor {
+	for _, t := range g.Initializers {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Marshal method returns the model in protobuf binary format.
+func (m *Model) Marshal() []byte {
+	var e encoder
+	m.marshal(&e)
+	return e.buf
+}
+
+// Write method writes the encoded model to w.
+func (m *Model) Write(w io.Writer) error {
+	_, err := w.Write(m.Marshal())
+	return err
+}
+
+// Unmarshal function decodes a model from protobuf binary format.
+func Unmarshal(b []byte) (*Model, error) {
+	m := new(Model)
+	if err := decode(b, m); err != nil {
+		return nil, err
+	}
+	if m.Graph == nil {
+		return nil, fmt.Errorf("onnx: model has no graph")
+	}
+	return m, nil
+}
+
+// Read function reads and decodes a model from r.
+func Read(r io.Reader) (*Model, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return Unmarshal(b)
+}
+
+// field numbers are as defined in onnx.proto
+
+type opsetID struct {
+	domain  string
+	version int64
+}
+
+func (o opsetID) marshal(e *encoder) {
+	e.optString(1, o.domain)
+	e.int(2, o.version)
+}
+
+func (o *opsetID) unmarshal(d *decoder, field, wire int) (err error) {
+	switch field {
+	case 1:
+		o.domain, err = d.string()
+	case 2:
+		var v uint64
+		v, err = d.varint()
+		o.version = int64(v)
+	default:
+		err = d.skip(wire)
+	}
+	return
+}
+
+func (m *Model) marshal(e *encoder) {
+	e.int(1, m.IRVersion)
+	e.optString(2, m.ProducerName)
+	e.optString(3, m.ProducerVersion)
+	if m.Graph != nil {
+		e.message(7, m.Graph)
+	}
+	e.message(8, opsetID{version: m.Opset})
+}
+
+func (m *Model) unmarshal(d *decoder, field, wire int) (err error) {
+	var v uint64
+	switch field {
+	case 1:
+		v, err = d.varint()
+		m.IRVersion = int64(v)
+	case 2:
+		m.ProducerName, err = d.string()
+	case 3:
+		m.ProducerVersion, err = d.string()
+	case 7:
+		m.Graph = new(Graph)
+		err = d.message(m.Graph)
+	case 8:
+		var o opsetID
+		if err = d.message(&o); err == nil && (o.domain == "" || o.domain == "ai.onnx") {
+			m.Opset = o.version
+		}
+	default:
+		err = d.skip(wire)
+	}
+	return
+}
+
+func (g *Graph) marshal(e *encoder) {
+	for _, n := range g.Nodes {
+		e.message(1, n)
+	}
+	e.optString(2, g.Name)
+	for _, t := range g.Initializers {
+		e.message(5, t)
+	}
+	for _, v := range g.Inputs {
+		e.message(11, v)
+	}
+	for _, v := range g.Outputs {
+		e.message(12, v)
+	}
+}
+
+func (g *Graph) unmarshal(d *decoder, field, wire int) (err error) {
+	switch field {
+	case 1:
+		n := new(Node)
+		err = d.message(n)
+		g.Nodes = append(g.Nodes, n)
+	case 2:
+		g.Name, err = d.string()
+	case 5:
+		t := new(Tensor)
+		err = d.message(t)
+		g.Initializers = append(g.Initializers, t)
+	case 11:
+		v := new(ValueInfo)
+		err = d.message(v)
+		g.Inputs = append(g.Inputs, v)
+	case 12:
+		v := new(ValueInfo)
+		err = d.message(v)
+		g.Outputs = append(g.Outputs, v)
+	default:
+		err = d.skip(wire)
+	}
+	return
+}
+
+func (n *Node) marshal(e *encoder) {
+	for _, s := range n.Inputs {
+		e.string(1, s)
+	}
+	for _, s := range n.Outputs {
+		e.string(2, s)
+	}
+	e.optString(3, n.Name)
+	e.string(4, n.OpType)
+	for _, a := range n.Attrs {
+		e.message(5, a)
+	}
+}
+
+func (n *Node) unmarshal(d *decoder, field, wire int) (err error) {
+	var s string
+	switch field {
+	case 1:
+		s, err = d.string()
+		n.Inputs = append(n.Inputs, s)
+	case 2:
+		s, err = d.string()
+		n.Outputs = append(n.Outputs, s)
+	case 3:
+		n.Name, err = d.string()
+	case 4:
+		n.OpType, err = d.string()
+	case 5:
+		a := new(Attribute)
+		err = d.message(a)
+		n.Attrs = append(n.Attrs, a)
+	default:
+		err = d.skip(wire)
+	}
+	return
+}
+
+func (a *Attribute) marshal(e *encoder) {
+	e.string(1, a.Name)
+	switch a.Type {
+	case AttrFloat:
+		e.float(2, a.F)
+	case AttrInt:
+		e.int(3, a.I)
+	case AttrString:
+		e.string(4, a.S)
+	case AttrTensor:
+		e.message(5, a.T)
+	case AttrFloats:
+		e.floats(7, a.Floats)
+	case AttrInts:
+		e.ints(8, a.Ints)
+	}
+	e.int(20, int64(a.Type))
+}
+
+func (a *Attribute) unmarshal(d *decoder, field, wire int) (err error) {
+	var v uint64
+	switch field {
+	case 1:
+		a.Name, err = d.string()
+	case 2:
+		a.F, err = d.float()
+	case 3:
+		v, err = d.varint()
+		a.I = int64(v)
+	case 4:
+		a.S, err = d.string()
+	case 5:
+		a.T = new(Tensor)
+		err = d.message(a.T)
+	case 7:
+		a.Floats, err = d.floats(wire, a.Floats)
+	case 8:
+		a.Ints, err = d.ints(wire, a.Ints)
+	case 20:
+		v, err = d.varint()
+		a.Type = int(v)
+	default:
+		err = d.skip(wire)
+	}
+	return
+}
+
+func (t *Tensor) marshal(e *encoder) {
+	e.ints(1, t.Dims)
+	e.int(2, int64(t.DataType))
+	if t.DataType == Int64 {
+		e.ints(7, t.Ints)
+	} else {
+		e.floats(4, t.Data)
+	}
+	e.optString(8, t.Name)
+}
+
+func (t *Tensor) unmarshal(d *decoder, field, wire int) (err error) {
+	var v uint64
+	var b []byte
+	switch field {
+	case 1:
+		t.Dims, err = d.ints(wire, t.Dims)
+	case 2:
+		v, err = d.varint()
+		t.DataType = int(v)
+	case 4:
+		t.Data, err = d.floats(wire, t.Data)
+	case 7:
+		t.Ints, err = d.ints(wire, t.Ints)
+	case 8:
+		t.Name, err = d.string()
+	case 9:
+		// raw data is only supported for float and int64 types
+		if b, err = d.bytes(); err == nil {
+			if t.DataType == Int64 {
+				for i := 0; i+8 <= len(b); i += 8 {
+					x := uint64(0)
+					for j := 7; j >= 0; j-- {
+						x = x<<8 | uint64(b[i+j])
+					}
+					t.Ints = append(t.Ints, int64(x))
+				}
+			} else {
+				t.Data = appendFloats(t.Data, b)
+			}
+		}
+	default:
+		err = d.skip(wire)
+	}
+	return
+}
+
+// tensor type and shape are nested in TypeProto.Tensor and TensorShapeProto messages
+type (
+	typeProto   struct{ v *ValueInfo }
+	tensorType  struct{ v *ValueInfo }
+	tensorShape struct{ v *ValueInfo }
+	dimension   struct {
+		value int64
+		param string
+	}
+)
+
+func (v *ValueInfo) marshal(e *encoder) {
+	e.string(1, v.Name)
+	e.message(2, typeProto{v})
+}
+
+func (v *ValueInfo) unmarshal(d *decoder, field, wire int) (err error) {
+	switch field {
+	case 1:
+		v.Name, err = d.string()
+	case 2:
+		err = d.message(typeProto{v})
+	default:
+		err = d.skip(wire)
+	}
+	return
+}
+
+func (t typeProto) marshal(e *encoder) {
+	e.message(1, tensorType(t))
+}
+
+func (t typeProto) unmarshal(d *decoder, field, wire int) error {
+	if field == 1 {
+		return d.message(tensorType(t))
+	}
+	return d.skip(wire)
+}
+
+func (t tensorType) marshal(e *encoder) {
+	e.int(1, int64(t.v.ElemType))
+	e.message(2, tensorShape(t))
+}
+
+func (t tensorType) unmarshal(d *decoder, field, wire int) (err error) {
+	switch field {
+	case 1:
+		var v uint64
+		v, err = d.varint()
+		t.v.ElemType = int(v)
+	case 2:
+		err = d.message(tensorShape(t))
+	default:
+		err = d.skip(wire)
+	}
+	return
+}
+
+func (s tensorShape) marshal(e *encoder) {
+	for i, n := range s.v.Dims {
+		dim := dimension{value: n}
+		if n < 0 && i < len(s.v.Params) {
+			dim.param = s.v.Params[i]
+		}
+		e.message(1, dim)
+	}
+}
+
+func (s tensorShape) unmarshal(d *decoder, field, wire int) error {
+	if field != 1 {
+		return d.skip(wire)
+	}
+	dim := &dimension{value: -1}
+	if err := d.message(dim); err != nil {
+		return err
+	}
+	for len(s.v.Params) < len(s.v.Dims) {
+		s.v.Params = append(s.v.Params, "")
+	}
+	s.v.Dims = append(s.v.Dims, dim.value)
+	s.v.Params = append(s.v.Params, dim.param)
+	return nil
+}
+
+func (m dimension) marshal(e *encoder) {
+	if m.value >= 0 {
+		e.int(1, m.value)
+	} else {
+		e.optString(2, m.param)
+	}
+}
+
+func (m *dimension) unmarshal(d *decoder, field, wire int) (err error) {
+	switch field {
+	case 1:
+		var v uint64
+		v, err = d.varint()
+		m.value = int64(v)
+	case 2:
+		m.param, err = d.string()
+	default:
+		err = d.skip(wire)
+	}
+	return
+}
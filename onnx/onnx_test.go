@@ -0,0 +1,90 @@
+package onnx
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	var e encoder
+	e.int(1, 150)
+	e.string(2, "ab")
+	e.floats(4, []float32{1})
+	expect := []byte{0x08, 0x96, 0x01, 0x12, 0x02, 'a', 'b', 0x22, 0x04, 0x00, 0x00, 0x80, 0x3f}
+	if !bytes.Equal(e.buf, expect) {
+		t.Errorf("expected % x got % x", expect, e.buf)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	g := &Graph{
+		Name: "test",
+		Nodes: []*Node{
+			NewNode("Gemm", "gemm", []string{"x", "W", "B"}, []string{"y"}, IntAttr("transB", 1), FloatAttr("alpha", 0.5)),
+			NewNode("Test", "test", []string{"y"}, []string{"z"}, IntsAttr("ints", 1, -2, 3),
+				&Attribute{Name: "floats", Type: AttrFloats, Floats: []float32{1.5, -2}},
+				&Attribute{Name: "str", Type: AttrString, S: "hello"},
+				&Attribute{Name: "tensor", Type: AttrTensor, T: &Tensor{DataType: Int64, Dims: []int64{2}, Ints: []int64{-1, 7}}}),
+		},
+		Initializers: []*Tensor{NewTensor("W", []float32{1, 2, 3, 4, 5, 6}, 2, 3), NewTensor("B", []float32{0.5, -1}, 2)},
+		Inputs:       []*ValueInfo{{Name: "x", ElemType: Float, Dims: []int64{-1, 3}, Params: []string{"N", ""}}},
+		Outputs:      []*ValueInfo{{Name: "z", ElemType: Float, Dims: []int64{4, 2}, Params: []string{"", ""}}},
+	}
+	m := NewModel(g)
+	b := m.Marshal()
+	t.Logf("encoded %d bytes", len(b))
+	m2, err := Unmarshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, m2) {
+		t.Errorf("models differ after round trip:\n%+v\n%+v", m.Graph, m2.Graph)
+	}
+	if _, err := Unmarshal(b[:len(b)-3]); err == nil {
+		t.Error("expected error decoding truncated model")
+	}
+}
+
+func checkData(t *testing.T, got, expect []float32) {
+	if len(got) != len(expect) {
+		t.Fatalf("expected %v got %v", expect, got)
+	}
+	for i := range got {
+		if math.Abs(float64(got[i]-expect[i])) > 1e-5 {
+			t.Fatalf("expected %v got %v", expect, got)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	g := &Graph{
+		Nodes: []*Node{
+			NewNode("Gemm", "gemm", []string{"x", "W", "B"}, []string{"y"}, IntAttr("transB", 1)),
+			NewNode("BatchNormalization", "bn", []string{"y", "scale", "shift", "mean", "var"}, []string{"z"}),
+			NewNode("Relu", "relu", []string{"z"}, []string{"r"}),
+			NewNode("Add", "add", []string{"r", "B"}, []string{"s"}),
+			NewNode("Softmax", "softmax", []string{"s"}, []string{"out"}),
+		},
+		Initializers: []*Tensor{
+			NewTensor("W", []float32{1, 2, 3, -1, 0, 1}, 2, 3),
+			NewTensor("B", []float32{1, -1}, 2),
+			NewTensor("scale", []float32{2, 1}, 2),
+			NewTensor("shift", []float32{0, 1}, 2),
+			NewTensor("mean", []float32{1, 0}, 2),
+			NewTensor("var", []float32{1, 4}, 2),
+		},
+		Outputs: []*ValueInfo{{Name: "out"}},
+	}
+	out, err := g.Run(map[string]*Tensor{"x": NewTensor("x", []float32{1, 0, 1, 0, 1, 0}, 2, 3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// gemm = [[5,-1],[3,-1]], bn = [[8,0.5],[4,0.5]], relu + bias = [[9,-0.5],[5,-0.5]]
+	e1, e2 := 1/(1+math.Exp(-9.5)), 1/(1+math.Exp(-5.5))
+	checkData(t, out["out"].Data, []float32{float32(e1), float32(1 - e1), float32(e2), float32(1 - e2)})
+	if _, err := g.Run(nil); err == nil {
+		t.Error("expected error with missing input")
+	}
+}
@@ -0,0 +1,248 @@
+package onnx
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("onnx: truncated message")
+
+// encoder appends protobuf encoded fields to a buffer
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) varint(v uint64) {
+	for v >= 0x80 {
+		e.buf = append(e.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	e.buf = append(e.buf, byte(v))
+}
+
+func (e *encoder) fixed32(v uint32) {
+	e.buf = append(e.buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func (e *encoder) tag(field, wire int) {
+	e.varint(uint64(field)<<3 | uint64(wire))
+}
+
+func (e *encoder) int(field int, v int64) {
+	e.tag(field, wireVarint)
+	e.varint(uint64(v))
+}
+
+func (e *encoder) float(field int, v float32) {
+	e.tag(field, wireFixed32)
+	e.fixed32(math.Float32bits(v))
+}
+
+func (e *encoder) bytes(field int, b []byte) {
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) string(field int, s string) {
+	e.bytes(field, []byte(s))
+}
+
+// strings are only written if they are not empty
+func (e *encoder) optString(field int, s string) {
+	if s != "" {
+		e.string(field, s)
+	}
+}
+
+// repeated int64 values are written unpacked as per the proto2 default
+func (e *encoder) ints(field int, v []int64) {
+	for _, x := range v {
+		e.int(field, x)
+	}
+}
+
+// repeated floats are written packed
+func (e *encoder) floats(field int, v []float32) {
+	if len(v) == 0 {
+		return
+	}
+	e.tag(field, wireBytes)
+	e.varint(uint64(4 * len(v)))
+	for _, x := range v {
+		e.fixed32(math.Float32bits(x))
+	}
+}
+
+type marshaler interface {
+	marshal(e *encoder)
+}
+
+func (e *encoder) message(field int, m marshaler) {
+	var sub encoder
+	m.marshal(&sub)
+	e.bytes(field, sub.buf)
+}
+
+// decoder reads protobuf fields from a buffer
+type decoder struct {
+	buf []byte
+	pos int
+}
+
+func (d *decoder) more() bool {
+	return d.pos < len(d.buf)
+}
+
+func (d *decoder) varint() (uint64, error) {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if d.pos >= len(d.buf) {
+			return 0, errTruncated
+		}
+		b := d.buf[d.pos]
+		d.pos++
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v, nil
+		}
+	}
+	return 0, errors.New("onnx: invalid varint")
+}
+
+// next returns the field number and wire type of the next field
+func (d *decoder) next() (field, wire int, err error) {
+	v, err := d.varint()
+	return int(v >> 3), int(v & 7), err
+}
+
+func (d *decoder) bytes() ([]byte, error) {
+	n, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(d.buf)-d.pos) < n {
+		return nil, errTruncated
+	}
+	b := d.buf[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+func (d *decoder) string() (string, error) {
+	b, err := d.bytes()
+	return string(b), err
+}
+
+func (d *decoder) fixed32() (uint32, error) {
+	if d.pos+4 > len(d.buf) {
+		return 0, errTruncated
+	}
+	v := binary.LittleEndian.Uint32(d.buf[d.pos:])
+	d.pos += 4
+	return v, nil
+}
+
+func (d *decoder) float() (float32, error) {
+	v, err := d.fixed32()
+	return math.Float32frombits(v), err
+}
+
+// int64 field which may be packed or unpacked
+func (d *decoder) ints(wire int, v []int64) ([]int64, error) {
+	if wire != wireBytes {
+		x, err := d.varint()
+		return append(v, int64(x)), err
+	}
+	b, err := d.bytes()
+	if err != nil {
+		return v, err
+	}
+	sub := decoder{buf: b}
+	for sub.more() {
+		x, err := sub.varint()
+		if err != nil {
+			return v, err
+		}
+		v = append(v, int64(x))
+	}
+	return v, nil
+}
+
+// float field which may be packed or unpacked
+func (d *decoder) floats(wire int, v []float32) ([]float32, error) {
+	if wire != wireBytes {
+		x, err := d.float()
+		return append(v, x), err
+	}
+	b, err := d.bytes()
+	if err != nil {
+		return v, err
+	}
+	return appendFloats(v, b), nil
+}
+
+// append little endian float32 values
+func appendFloats(v []float32, b []byte) []float32 {
+	for i := 0; i+4 <= len(b); i += 4 {
+		v = append(v, math.Float32frombits(binary.LittleEndian.Uint32(b[i:])))
+	}
+	return v
+}
+
+// skip over a field which is not used
+func (d *decoder) skip(wire int) error {
+	var err error
+	switch wire {
+	case wireVarint:
+		_, err = d.varint()
+	case wireFixed64:
+		if d.pos += 8; d.pos > len(d.buf) {
+			err = errTruncated
+		}
+	case wireBytes:
+		_, err = d.bytes()
+	case wireFixed32:
+		_, err = d.fixed32()
+	default:
+		err = errors.New("onnx: invalid wire type")
+	}
+	return err
+}
+
+type unmarshaler interface {
+	unmarshal(d *decoder, field, wire int) error
+}
+
+// decode all the fields in buffer b into m
+func decode(b []byte, m unmarshaler) error {
+	d := &decoder{buf: b}
+	for d.more() {
+		field, wire, err := d.next()
+		if err != nil {
+			return err
+		}
+		if err = m.unmarshal(d, field, wire); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decode a sub message
+func (d *decoder) message(m unmarshaler) error {
+	b, err := d.bytes()
+	if err != nil {
+		return err
+	}
+	return decode(b, m)
+}
@@ -200,7 +200,7 @@ ApplicationWindow {
 					onTextChanged: cfg.set(objectName, text)
 				}
 				Label { 
-					Layout.rowSpan: 8
+					Layout.rowSpan: 17
 				}
 				Label {
 					text: "threshold"
@@ -247,6 +247,86 @@ ApplicationWindow {
 					model: ["uniform", "random"]
 					onActivated: cfg.set(objectName, model[index])
 				}
+				Label {
+					text: "optimizer"
+					anchors.right: optimizer.left; anchors.rightMargin: 10
+				}
+				ComboBox { 
+					id: optimizer; objectName: "Optimizer"
+					model: ["sgd", "nesterov", "adagrad", "rmsprop", "adam"]
+					onActivated: cfg.set(objectName, model[index])
+				}
+				Label {
+					text: "rate schedule"
+					anchors.right: schedule.left; anchors.rightMargin: 10
+				}
+				ComboBox { 
+					id: schedule; objectName: "LRSchedule"
+					model: ["constant", "step", "exp", "cosine", "plateau"]
+					onActivated: cfg.set(objectName, model[index])
+				}
+				Label {
+					text: "rate decay"
+					anchors.right: lrDecay.left; anchors.rightMargin: 10
+				}
+				TextField { 
+					id: lrDecay; objectName: "LRDecay"
+					validator: DoubleValidator{}
+					onTextChanged: cfg.set(objectName, text)
+				}
+				Label {
+					text: "rate step"
+					anchors.right: lrStep.left; anchors.rightMargin: 10
+				}
+				TextField { 
+					id: lrStep; objectName: "LRStep"
+					validator: IntValidator{}
+					onTextChanged: cfg.set(objectName, text)
+				}
+				Label {
+					text: "min rate"
+					anchors.right: minRate.left; anchors.rightMargin: 10
+				}
+				TextField { 
+					id: minRate; objectName: "LRMin"
+					validator: DoubleValidator{}
+					onTextChanged: cfg.set(objectName, text)
+				}
+				Label {
+					text: "rate per batch"
+					anchors.right: perBatch.left; anchors.rightMargin: 10
+				}
+				CheckBox { 
+					id: perBatch; objectName: "LRPerBatch"
+					onClicked: cfg.set(objectName, checked ? "true" : "false")
+				}
+				Label {
+					text: "weight init"
+					anchors.right: weightInit.left; anchors.rightMargin: 10
+				}
+				ComboBox { 
+					id: weightInit; objectName: "WeightInit"
+					model: ["lecun", "lecun_uniform", "xavier", "xavier_uniform", "he", "he_uniform", "orthogonal"]
+					onActivated: cfg.set(objectName, model[index])
+				}
+				Label {
+					text: "bias init"
+					anchors.right: biasInit.left; anchors.rightMargin: 10
+				}
+				TextField { 
+					id: biasInit; objectName: "BiasInit"
+					validator: DoubleValidator{}
+					onTextChanged: cfg.set(objectName, text)
+				}
+				Label {
+					text: "warmup"
+					anchors.right: warmup.left; anchors.rightMargin: 10
+				}
+				TextField { 
+					id: warmup; objectName: "Warmup"
+					validator: IntValidator{}
+					onTextChanged: cfg.set(objectName, text)
+				}
 				Label {
 					text: "distortion"
 					anchors.right: distortion.left; anchors.rightMargin: 10
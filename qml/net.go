@@ -117,14 +117,19 @@ func (n *Network) Distort(on bool, mode int) {
 func (n *Network) Run(offset int) {
 	cfg := n.ctrl.conf.cfg
 	net := n.ctrl.network
-	loader := getLoader(n.ctrl.conf.Model)
 	data := n.ctrl.testData
+	if data.Classes == nil || net.Regression() {
+		// only classification data sets can be viewed
+		return
+	}
+	loader := getLoader(n.ctrl.conf.Model)
 	if n.perPage == 0 {
 		n.perPage = 1
 	}
 	got := 0
 	done := false
 	input := blas.New(1, data.Input.Cols())
+	defer net.SetTraining(net.SetTraining(false))
 	for try := 0; try < data.NumSamples; try++ {
 		n.next = (n.next + data.NumSamples) % data.NumSamples
 		exp := data.Classes.Row(n.next, n.next+1).Data(blas.ColMajor)
@@ -219,7 +224,7 @@ func (n *Network) Paint(paint *qml.Painter) {
 			gl.PushMatrix()
 			gl.Scalef(1/maxSize, 1/maxSize, 1)
 			gl.Translatef(maxSize*(2*xpos+dx-1), 0, 0)
-			n.drawLayer(gl, nx, ny, layer.Values().Data(blas.ColMajor))
+			n.drawLayer(gl, nx, ny, planar(layer.Dims(), layer.Values().Data(blas.ColMajor)))
 			gl.PopMatrix()
 			xpos += dx
 		}
@@ -275,15 +280,36 @@ func (n *Network) drawLayer(gl *GL.GL, nx, ny int, values []float32) {
 	gl.End()
 }
 
+// get size to draw layer, feature maps with multiple channels are drawn side by side
 func dimxy(dims []int) (nx, ny int) {
 	ny = dims[0]
 	nx = 1
 	if len(dims) > 1 {
 		nx = dims[1]
 	}
+	if len(dims) > 2 {
+		nx *= dims[2]
+	}
 	return
 }
 
+// reorder interleaved channel values so each feature map is drawn as a separate image
+func planar(dims []int, values []float32) []float32 {
+	if len(dims) < 3 || dims[2] < 2 {
+		return values
+	}
+	ny, nx, nc := dims[0], dims[1], dims[2]
+	out := make([]float32, nx*ny*nc)
+	for y := 0; y < ny; y++ {
+		for x := 0; x < nx; x++ {
+			for c := 0; c < nc; c++ {
+				out[y*nx*nc+c*nx+x] = values[(y*nx+x)*nc+c]
+			}
+		}
+	}
+	return out
+}
+
 func getLoader(model string) network.Loader {
 	loader, ok := network.GetLoader(model)
 	if !ok {
@@ -28,6 +28,7 @@ type Ctrl struct {
 	ev        chan Event
 	net       qml.Object
 	run       qml.Object
+	models    qml.Object
 	plot      qml.Object
 	runLabel  qml.Object
 	testLabel qml.Object
@@ -51,6 +52,7 @@ func NewCtrl(cfg *network.Config, net *network.Network, testData *network.Data,
 
 func (c *Ctrl) init(root qml.Object) {
 	c.run = root.ObjectByName("runButton")
+	c.models = root.ObjectByName("modelList")
 	c.plot = root.ObjectByName("plotControl")
 	c.runLabel = root.ObjectByName("runLabel")
 	c.runLabel.Set("text", fmt.Sprintf("run: 1/%d", c.conf.cfg.MaxRuns))
@@ -109,6 +111,18 @@ func (c *Ctrl) Select(model string) {
 	}
 }
 
+// Callback to go back to the previous data set if the selected one could not be loaded
+func (c *Ctrl) Restore(model string) {
+	qml.RunMain(func() {
+		c.conf.Model = model
+		for i, name := range c.setNames {
+			if name == model {
+				c.models.Set("currentIndex", i)
+			}
+		}
+	})
+}
+
 // Callback when run is completed
 func (c *Ctrl) Done() {
 	qml.RunMain(func() { c.run.Set("checked", false) })
@@ -162,14 +176,22 @@ func (c *Config) Set(key, value string) {
 
 // Update display of config settings
 func (c *Config) Update() {
+	choices := map[string][]string{
+		"Sampler":    network.SamplerNames,
+		"Optimizer":  network.OptimizerNames,
+		"LRSchedule": network.ScheduleNames,
+		"WeightInit": network.InitNames,
+	}
 	for i, opt := range c.opts {
 		value := config.Get(c.cfg, c.keys[i])
-		if c.keys[i] == "Sampler" {
-			for i, name := range network.SamplerNames {
+		if names, ok := choices[c.keys[i]]; ok {
+			for i, name := range names {
 				if name == value {
 					opt.Set("currentIndex", i)
 				}
 			}
+		} else if value == "true" || value == "false" {
+			opt.Set("checked", value == "true")
 		} else {
 			opt.Set("text", value)
 		}
@@ -2,6 +2,8 @@
 package vec
 
 import (
+	"bytes"
+	"encoding/gob"
 	"fmt"
 	"math"
 	"sync"
@@ -92,6 +94,33 @@ func (v *Vector) Last() float32 {
 	return v.data[len(v.data)-1]
 }
 
+type vectorData struct {
+	Data, Errors []float32
+	Xmin, Xstep  float32
+	Ymin, Ymax   float32
+	DefSize      int
+}
+
+// GobEncode method implements the gob.GobEncoder interface so vectors can be saved.
+func (v *Vector) GobEncode() ([]byte, error) {
+	v.Lock()
+	defer v.Unlock()
+	return encode(vectorData{v.data, v.errors, v.xmin, v.xstep, v.ymin, v.ymax, v.defSize})
+}
+
+// GobDecode method implements the gob.GobDecoder interface.
+func (v *Vector) GobDecode(buf []byte) error {
+	var d vectorData
+	if err := decode(buf, &d); err != nil {
+		return err
+	}
+	v.Lock()
+	v.data, v.errors = append([]float32{}, d.Data...), append([]float32{}, d.Errors...)
+	v.xmin, v.xstep, v.ymin, v.ymax, v.defSize = d.Xmin, d.Xstep, d.Ymin, d.Ymax, d.DefSize
+	v.Unlock()
+	return nil
+}
+
 // Running mean and stddev as per http://www.johndcook.com/blog/standard_deviation/
 type RunningStat struct {
 	Count, Mean float64
@@ -122,6 +151,23 @@ func (s *RunningStat) Push(x32 float32) {
 	}
 }
 
+type runningStatData struct {
+	Count, Mean, Var, StdDev, OldM, OldV float64
+}
+
+// GobEncode method implements the gob.GobEncoder interface so stats can be saved.
+func (s *RunningStat) GobEncode() ([]byte, error) {
+	return encode(runningStatData{s.Count, s.Mean, s.Var, s.StdDev, s.oldM, s.oldV})
+}
+
+// GobDecode method implements the gob.GobDecoder interface.
+func (s *RunningStat) GobDecode(buf []byte) error {
+	var d runningStatData
+	err := decode(buf, &d)
+	s.Count, s.Mean, s.Var, s.StdDev, s.oldM, s.oldV = d.Count, d.Mean, d.Var, d.StdDev, d.OldM, d.OldV
+	return err
+}
+
 func (s *RunningStat) String() string {
 	return fmt.Sprintf("mean = %8.3g  std dev = %8.3g", s.Mean, s.StdDev)
 }
@@ -153,6 +199,24 @@ func (b *Buffer) Len() int {
 	return b.size
 }
 
+type bufferData struct {
+	Data []float32
+	Size int
+}
+
+// GobEncode method implements the gob.GobEncoder interface so buffers can be saved.
+func (b *Buffer) GobEncode() ([]byte, error) {
+	return encode(bufferData{b.data, b.size})
+}
+
+// GobDecode method implements the gob.GobDecoder interface.
+func (b *Buffer) GobDecode(buf []byte) error {
+	var d bufferData
+	err := decode(buf, &d)
+	b.data, b.size = d.Data, d.Size
+	return err
+}
+
 // Max method returns the maximum value.
 func (b *Buffer) Max() float32 {
 	max := float32(-1.0e30)
@@ -164,6 +228,16 @@ func (b *Buffer) Max() float32 {
 	return max
 }
 
+func encode(val interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(val)
+	return buf.Bytes(), err
+}
+
+func decode(buf []byte, val interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(buf)).Decode(val)
+}
+
 // nicenum returns a "nice" number approximately equal to r
 // Rounds the number if round = true. Takes the ceiling if round = false.
 func Nicenum(r32 float32, round bool) float32 {